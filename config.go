@@ -1,15 +1,106 @@
 package groot
 
 import (
+	"context"
+	"io"
 	"os"
+	"time"
 
+	"code.cloudfoundry.org/groot/fetcher/blobcache"
+	"code.cloudfoundry.org/groot/fetcher/layerfetcher/source"
+	"code.cloudfoundry.org/groot/fetcher/signaturepolicy"
+	imgspec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/pkg/errors"
 	yaml "gopkg.in/yaml.v2"
 )
 
 type config struct {
-	LogLevel           string   `yaml:"log_level"`
-	InsecureRegistries []string `yaml:"insecure_registries"`
+	LogLevel             string                    `yaml:"log_level"`
+	LogFormat            string                    `yaml:"log_format"`
+	LogFile              logFileConfig             `yaml:"log_file"`
+	InsecureRegistries   []string                  `yaml:"insecure_registries"`
+	LayerDownloadWorkers int                       `yaml:"layer_download_workers"`
+	StreamBlobs          bool                      `yaml:"stream_blobs"`
+	PullTimeout          time.Duration             `yaml:"pull_timeout"`
+	Retry                retryConfig               `yaml:"retry"`
+	Platform             platformConfig            `yaml:"platform"`
+	AuthFile             string                    `yaml:"auth_file"`
+	CredentialHelpers    map[string]string         `yaml:"credential_helpers"`
+	Registries           map[string]registryConfig `yaml:"registries"`
+	Mirrors              map[string][]string       `yaml:"mirrors"`
+	BlobCache            blobCacheConfig           `yaml:"blob_cache"`
+	Offline              bool                      `yaml:"offline"`
+	GC                   gcConfig                  `yaml:"gc"`
+	ImagePolicy          imagePolicyConfig         `yaml:"image_policy"`
+	Progress             progressConfig            `yaml:"progress"`
+	Metrics              metricsConfig             `yaml:"metrics"`
+	Tracing              tracingConfig             `yaml:"tracing"`
+	// SignaturePolicyFile is a containers-policy.json(5) file that images
+	// have to satisfy before their layers are fetched. Only registry and OCI
+	// layout images can satisfy it. Signatures can only be verified when groot
+	// is built with the containers_image_openpgp build tag, and every pull
+	// fails while a policy is set otherwise.
+	SignaturePolicyFile string `yaml:"signature_policy_file"`
+}
+
+// progressConfig enables the progress stream of pulls, which is written as
+// JSON lines to either a file, which is appended to, or a file descriptor that
+// the caller of groot opened, such as 3
+type progressConfig struct {
+	File string `yaml:"file"`
+	FD   int    `yaml:"fd"`
+}
+
+// gcConfig configures the gc command. Layers used within the grace period are
+// never collected, and nothing is collected while the layers take up no more
+// than the threshold. The grace period defaults to defaultGCGracePeriod, and
+// should be longer than the slowest create, as the layers of a create are not
+// referenced by a bundle until it finishes.
+type gcConfig struct {
+	GracePeriod    *time.Duration `yaml:"grace_period"`
+	ThresholdBytes int64          `yaml:"threshold_bytes"`
+}
+
+const defaultGCGracePeriod = time.Hour
+
+// blobCacheConfig enables the on-disk cache of compressed registry blobs. The
+// cache is disabled when no path is set, and never evicted when no maximum size
+// is set.
+type blobCacheConfig struct {
+	Path         string `yaml:"path"`
+	MaxSizeBytes int64  `yaml:"max_size_bytes"`
+}
+
+// registryConfig configures how to connect to a single registry, keyed by
+// host[:port] in the config file, or docker.io for Docker Hub.
+// MinTLSVersion is only enforced by a pre-flight probe: before the pull, groot
+// opens a TLS connection of its own to the registry, which fails when the
+// registry does not support the version, and which counts towards
+// pull_timeout. The connections of the pull itself are not restricted, but
+// negotiate the highest version both ends support.
+type registryConfig struct {
+	CAFile        string `yaml:"ca_file"`
+	CertFile      string `yaml:"cert_file"`
+	KeyFile       string `yaml:"key_file"`
+	MinTLSVersion string `yaml:"min_tls_version"`
+}
+
+// platformConfig selects the image to pull from multi-architecture images.
+// Unset fields default to the platform groot runs on.
+type platformConfig struct {
+	OS           string `yaml:"os"`
+	Architecture string `yaml:"architecture"`
+	Variant      string `yaml:"variant"`
+}
+
+// retryConfig overrides the default retry policy for registry requests. Unset
+// fields keep their default value.
+type retryConfig struct {
+	Attempts             int           `yaml:"attempts"`
+	InitialBackoff       time.Duration `yaml:"initial_backoff"`
+	MaxBackoff           time.Duration `yaml:"max_backoff"`
+	Jitter               *float64      `yaml:"jitter"`
+	RetryableStatusCodes []int         `yaml:"retryable_status_codes"`
 }
 
 func parseConfig(configFilePath string) (conf config, err error) {
@@ -25,11 +116,11 @@ func parseConfig(configFilePath string) (conf config, err error) {
 
 	contents, err := os.ReadFile(configFilePath)
 	if err != nil {
-		return config{}, errors.Wrap(err, "reading config file")
+		return config{}, invalidArgument(errors.Wrap(err, "reading config file"))
 	}
 
 	if err := yaml.Unmarshal(contents, &conf); err != nil {
-		return config{}, errors.Wrap(err, "parsing config file")
+		return config{}, invalidArgument(errors.Wrap(err, "parsing config file"))
 	}
 
 	return conf, nil
@@ -39,5 +130,115 @@ func applyDefaults(conf config) config {
 	if conf.LogLevel == "" {
 		conf.LogLevel = "info"
 	}
+	if conf.LogFormat == "" {
+		conf.LogFormat = logFormatPretty
+	}
+	if conf.LayerDownloadWorkers <= 0 {
+		conf.LayerDownloadWorkers = 1
+	}
 	return conf
 }
+
+// layerDownloadWorkers returns how many layers should be downloaded in
+// parallel. Streamed blobs are downloaded while the driver unpacks them, and
+// layers are unpacked one at a time, so there is nothing to gain from opening
+// more than one stream at once.
+func (c config) layerDownloadWorkers() int {
+	if c.StreamBlobs {
+		return 1
+	}
+	return c.LayerDownloadWorkers
+}
+
+func (c config) pullContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.PullTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.PullTimeout)
+}
+
+func (c config) retryPolicy() source.RetryPolicy {
+	policy := source.DefaultRetryPolicy()
+	if c.Retry.Attempts > 0 {
+		policy.Attempts = c.Retry.Attempts
+	}
+	if c.Retry.InitialBackoff > 0 {
+		policy.InitialBackoff = c.Retry.InitialBackoff
+	}
+	if c.Retry.MaxBackoff > 0 {
+		policy.MaxBackoff = c.Retry.MaxBackoff
+	}
+	if c.Retry.Jitter != nil {
+		policy.Jitter = *c.Retry.Jitter
+	}
+	if len(c.Retry.RetryableStatusCodes) > 0 {
+		policy.RetryableStatusCodes = c.Retry.RetryableStatusCodes
+	}
+	return policy
+}
+
+func (c config) gcGracePeriod() time.Duration {
+	if c.GC.GracePeriod == nil {
+		return defaultGCGracePeriod
+	}
+	return *c.GC.GracePeriod
+}
+
+func (c config) blobCache() (*blobcache.BlobCache, error) {
+	if c.BlobCache.Path == "" {
+		return nil, nil
+	}
+	return blobcache.New(c.BlobCache.Path, c.BlobCache.MaxSizeBytes)
+}
+
+// signatureVerifier returns nil when no signature policy is configured
+func (c config) signatureVerifier() (source.SignatureVerifier, error) {
+	if c.SignaturePolicyFile == "" {
+		return nil, nil
+	}
+
+	verifier, err := signaturepolicy.New(c.SignaturePolicyFile)
+	if err != nil {
+		return nil, err
+	}
+	return verifier, nil
+}
+
+// progressStream returns nil when the progress stream is disabled
+func (c config) progressStream() (io.WriteCloser, error) {
+	switch {
+	case c.Progress.File != "" && c.Progress.FD != 0:
+		return nil, invalidArgument(errors.New("only one of progress.file and progress.fd can be set"))
+	case c.Progress.File != "":
+		file, err := os.OpenFile(c.Progress.File, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			return nil, errors.Wrap(err, "opening progress file")
+		}
+		return file, nil
+	case c.Progress.FD < 0:
+		return nil, invalidArgument(errors.Errorf("invalid progress.fd %d", c.Progress.FD))
+	case c.Progress.FD == 1 || c.Progress.FD == 2:
+		return nil, invalidArgument(errors.Errorf("progress.fd cannot be %d, groot already writes its output and logs to stdout and stderr", c.Progress.FD))
+	case c.Progress.FD > 0:
+		file := os.NewFile(uintptr(c.Progress.FD), "progress")
+		if _, err := file.Stat(); err != nil {
+			return nil, invalidArgument(errors.Wrapf(err, "progress.fd %d is not open", c.Progress.FD))
+		}
+		return file, nil
+	}
+	return nil, nil
+}
+
+// platform returns the platform given on the command line, if any, or the one
+// from the config file
+func (c config) platform(flagValue string) (imgspec.Platform, error) {
+	if flagValue != "" {
+		return parsePlatform(flagValue)
+	}
+
+	return imgspec.Platform{
+		OS:           c.Platform.OS,
+		Architecture: c.Platform.Architecture,
+		Variant:      c.Platform.Variant,
+	}, nil
+}
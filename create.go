@@ -1,20 +1,31 @@
 package groot
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	"code.cloudfoundry.org/groot/imagepuller"
+	"code.cloudfoundry.org/groot/tracing"
 	runspec "github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
 )
 
-func (g *Groot) Create(handle string, diskLimit int64, excludeImageFromQuota bool) (runspec.Spec, error) {
+func (g *Groot) Create(ctx context.Context, imageURL, handle string, diskLimit int64, excludeImageFromQuota bool) (_ runspec.Spec, err error) {
 	g.Logger = g.Logger.Session("create")
 	g.Logger.Debug("starting")
 	defer g.Logger.Debug("ending")
 
+	ctx, span := tracing.Start(ctx, "groot.Create",
+		attribute.String("groot.image_url", redactURL(imageURL)),
+		attribute.String("groot.handle", handle),
+		attribute.Int64("groot.disk_limit", diskLimit),
+	)
+	defer func() { tracing.End(span, err) }()
+
 	if diskLimit < 0 {
-		return runspec.Spec{}, fmt.Errorf("invalid disk limit: %d", diskLimit)
+		return runspec.Spec{}, invalidArgument(fmt.Errorf("invalid disk limit: %d", diskLimit))
 	}
 
 	imageSpec := imagepuller.ImageSpec{
@@ -22,7 +33,7 @@ func (g *Groot) Create(handle string, diskLimit int64, excludeImageFromQuota boo
 		ExcludeImageFromQuota: excludeImageFromQuota,
 	}
 
-	image, err := g.ImagePuller.Pull(g.Logger, imageSpec)
+	image, err := g.ImagePuller.Pull(ctx, g.Logger, imageSpec)
 	if err != nil {
 		return runspec.Spec{}, errors.Wrap(err, "pulling image")
 	}
@@ -32,25 +43,52 @@ func (g *Groot) Create(handle string, diskLimit int64, excludeImageFromQuota boo
 	if diskLimit != 0 && !excludeImageFromQuota {
 		quota = quota - image.Size
 		if quota <= 0 {
-			return runspec.Spec{}, fmt.Errorf("disk limit %d must be larger than image size %d", diskLimit, image.Size)
+			return runspec.Spec{}, imagepuller.Classify(ErrQuotaExceeded, fmt.Errorf("disk limit %d must be larger than image size %d", diskLimit, image.Size))
 		}
 	}
 
-	bundle, err := g.Driver.Bundle(g.Logger.Session("bundle"), handle, image.ChainIDs, quota)
+	bundle, err := g.bundle(ctx, handle, image.ChainIDs, quota)
 	if err != nil {
-		return runspec.Spec{}, errors.Wrap(err, "creating bundle")
+		return runspec.Spec{}, driverFailure(errors.Wrap(err, "creating bundle"))
 	}
 
-	if len(image.Config.Config.Env) > 0 {
-		if bundle.Process == nil {
-			bundle.Process = &runspec.Process{}
-		}
-
-		bundle.Process.Env = append(bundle.Process.Env, image.Config.Config.Env...)
+	// The user of the image can only be resolved through the files of the
+	// bundle, so the config is applied once the bundle exists, and the bundle
+	// is deleted again when that fails
+	if err := g.applyImageConfig(g.Logger, &bundle, handle, image.Config.Config); err != nil {
+		g.deleteFailedBundle(handle)
+		return runspec.Spec{}, errors.Wrap(err, "applying image config")
 	}
 
-	metadata := ImageMetadata{Size: image.Size}
-	err = g.Driver.WriteMetadata(g.Logger.Session("write-metadata"), handle, metadata)
+	metadata := ImageMetadata{
+		Size:           image.Size,
+		ImageURL:       redactURL(imageURL),
+		ManifestDigest: image.ManifestDigest,
+		ConfigDigest:   image.ConfigDigest,
+		ChainIDs:       image.ChainIDs,
+		Platform:       imagePlatform(image.Config),
+		CreatedAt:      time.Now().UTC(),
+	}
+	return bundle, driverFailure(g.writeMetadata(ctx, handle, metadata))
+}
 
+func (g *Groot) bundle(ctx context.Context, handle string, chainIDs []string, quota int64) (runspec.Spec, error) {
+	_, span := tracing.Start(ctx, "driver.Bundle", attribute.Int64("groot.quota", quota))
+	bundle, err := g.Driver.Bundle(g.Logger.Session("bundle"), handle, chainIDs, quota)
+	tracing.End(span, err)
 	return bundle, err
 }
+
+func (g *Groot) deleteFailedBundle(handle string) {
+	logger := g.Logger.Session("delete-failed-bundle")
+	if err := g.Driver.Delete(logger, handle); err != nil {
+		logger.Error("deleting-bundle-failed", err)
+	}
+}
+
+func (g *Groot) writeMetadata(ctx context.Context, handle string, metadata ImageMetadata) error {
+	_, span := tracing.Start(ctx, "driver.WriteMetadata")
+	err := g.Driver.WriteMetadata(g.Logger.Session("write-metadata"), handle, metadata)
+	tracing.End(span, err)
+	return err
+}
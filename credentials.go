@@ -0,0 +1,133 @@
+package groot
+
+import (
+	"encoding/json"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/containers/image/v5/docker/reference"
+	dockerconfig "github.com/containers/image/v5/pkg/docker/config"
+	"github.com/containers/image/v5/types"
+	"github.com/docker/docker-credential-helpers/client"
+	"github.com/docker/docker-credential-helpers/credentials"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+)
+
+const dockerHubRegistry = "docker.io"
+
+// registryCredentials returns the credentials to pull imageURL with. The
+// credential helper configured for the registry is asked first, then the auth
+// file. When neither has credentials for the registry, the credentials given
+// on the command line are used.
+func registryCredentials(imageURL *url.URL, conf config, dockerConfig DockerConfig) (*types.DockerAuthConfig, error) {
+	registry := imageURL.Host
+	if registry == "" {
+		registry = dockerHubRegistry
+	}
+
+	if helper, ok := conf.CredentialHelpers[registry]; ok {
+		authConfig, err := credentialHelperCredentials(helper, registry)
+		if err != nil {
+			return nil, errors.Wrapf(err, "getting credentials for `%s` from credential helper `%s`", registry, helper)
+		}
+		if authConfig != (types.DockerAuthConfig{}) {
+			return &authConfig, nil
+		}
+	}
+
+	if conf.AuthFile != "" {
+		ref, err := reference.ParseNormalizedNamed(registry + imageURL.Path)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing image reference")
+		}
+
+		authConfig, err := dockerconfig.GetCredentialsForRef(&types.SystemContext{AuthFilePath: conf.AuthFile}, ref)
+		if err != nil {
+			return nil, errors.Wrapf(err, "getting credentials for `%s` from auth file", registry)
+		}
+		if authConfig != (types.DockerAuthConfig{}) {
+			return &authConfig, nil
+		}
+	}
+
+	return &types.DockerAuthConfig{
+		Username:      dockerConfig.Username,
+		Password:      dockerConfig.Password,
+		IdentityToken: dockerConfig.IdentityToken,
+	}, nil
+}
+
+// stdinCredentials is what --credentials-stdin reads
+type stdinCredentials struct {
+	Username      string `json:"username"`
+	Password      string `json:"password"`
+	IdentityToken string `json:"identity_token"`
+	RegistryToken string `json:"registry_token"`
+}
+
+// commandLineDockerConfig returns the credentials given with --username and
+// either --password, --password-file, or --credentials-stdin. The latter two
+// keep secrets out of the command line, where other users of the machine can
+// see them.
+func commandLineDockerConfig(ctx *cli.Context, conf config, stdin io.Reader) (DockerConfig, error) {
+	dockerConfig := DockerConfig{
+		InsecureRegistries: conf.InsecureRegistries,
+		Username:           ctx.String("username"),
+		Password:           ctx.String("password"),
+	}
+
+	passwordSources := 0
+	for _, flag := range []string{"password", "password-file", "credentials-stdin"} {
+		if ctx.IsSet(flag) {
+			passwordSources++
+		}
+	}
+	if passwordSources > 1 {
+		return DockerConfig{}, invalidArgument(errors.New("only one of --password, --password-file and --credentials-stdin can be given"))
+	}
+
+	if passwordFile := ctx.String("password-file"); passwordFile != "" {
+		password, err := os.ReadFile(passwordFile)
+		if err != nil {
+			return DockerConfig{}, invalidArgument(errors.Wrap(err, "reading password file"))
+		}
+		dockerConfig.Password = strings.TrimRight(string(password), "\r\n")
+	}
+
+	if ctx.Bool("credentials-stdin") {
+		if ctx.IsSet("username") {
+			return DockerConfig{}, invalidArgument(errors.New("--username cannot be given with --credentials-stdin"))
+		}
+
+		var creds stdinCredentials
+		if err := json.NewDecoder(stdin).Decode(&creds); err != nil {
+			return DockerConfig{}, invalidArgument(errors.Wrap(err, "reading credentials from stdin"))
+		}
+		dockerConfig.Username = creds.Username
+		dockerConfig.Password = creds.Password
+		dockerConfig.IdentityToken = creds.IdentityToken
+		dockerConfig.RegistryToken = creds.RegistryToken
+	}
+
+	return dockerConfig, nil
+}
+
+// credentialHelperCredentials runs docker-credential-<helper>. Helpers return
+// "<token>" as the username of identity tokens.
+func credentialHelperCredentials(helper, registry string) (types.DockerAuthConfig, error) {
+	creds, err := client.Get(client.NewShellProgramFunc("docker-credential-"+helper), registry)
+	if err != nil {
+		if credentials.IsErrCredentialsNotFoundMessage(err.Error()) {
+			return types.DockerAuthConfig{}, nil
+		}
+		return types.DockerAuthConfig{}, err
+	}
+
+	if creds.Username == "<token>" {
+		return types.DockerAuthConfig{IdentityToken: creds.Secret}, nil
+	}
+	return types.DockerAuthConfig{Username: creds.Username, Password: creds.Secret}, nil
+}
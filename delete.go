@@ -1,9 +1,15 @@
 package groot
 
-func (g *Groot) Delete(handle string) error {
+import "context"
+
+func (g *Groot) Delete(ctx context.Context, handle string) error {
 	g.Logger = g.Logger.Session("delete")
 	g.Logger.Debug("starting")
 	defer g.Logger.Debug("ending")
 
-	return g.Driver.Delete(g.Logger, handle)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return driverFailure(g.Driver.Delete(g.Logger, handle))
 }
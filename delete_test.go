@@ -1,6 +1,8 @@
 package groot_test
 
 import (
+	"context"
+
 	"code.cloudfoundry.org/groot"
 	"code.cloudfoundry.org/groot/grootfakes"
 	"code.cloudfoundry.org/lager/v3/lagertest"
@@ -28,7 +30,7 @@ var _ = Describe("Delete", func() {
 	})
 
 	It("calls driver.Delete() with the expected args", func() {
-		Expect(g.Delete("image")).To(Succeed())
+		Expect(g.Delete(context.Background(), "image")).To(Succeed())
 
 		Expect(driver.DeleteCallCount()).To(Equal(1))
 		_, bundleID := driver.DeleteArgsForCall(0)
@@ -41,7 +43,17 @@ var _ = Describe("Delete", func() {
 		})
 
 		It("returns the error", func() {
-			Expect(g.Delete("image")).To(MatchError(ContainSubstring("failed")))
+			Expect(g.Delete(context.Background(), "image")).To(MatchError(ContainSubstring("failed")))
+		})
+	})
+
+	Context("when the context is cancelled", func() {
+		It("returns the error without calling the driver", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			Expect(g.Delete(ctx, "image")).To(MatchError(context.Canceled))
+			Expect(driver.DeleteCallCount()).To(Equal(0))
 		})
 	})
 })
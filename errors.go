@@ -0,0 +1,108 @@
+package groot
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+
+	"code.cloudfoundry.org/groot/imagepuller"
+)
+
+// Failures are classified as one of these errors, which callers can check for
+// with errors.Is. The command line reports them with the exit codes below.
+var (
+	ErrInvalidArgument     = imagepuller.ErrInvalidArgument
+	ErrQuotaExceeded       = imagepuller.ErrQuotaExceeded
+	ErrDigestMismatch      = imagepuller.ErrDigestMismatch
+	ErrUnauthorized        = imagepuller.ErrUnauthorized
+	ErrImageNotFound       = imagepuller.ErrImageNotFound
+	ErrRegistryUnavailable = imagepuller.ErrRegistryUnavailable
+	ErrDriver              = imagepuller.ErrDriver
+	ErrPolicyRejected      = imagepuller.ErrPolicyRejected
+)
+
+// Exit codes of the groot commands
+const (
+	// ExitCodeFailure is used for failures that are not classified
+	ExitCodeFailure = 1
+	// ExitCodeInvalidArgument is used when the arguments, flags or config
+	// file are invalid
+	ExitCodeInvalidArgument = 2
+	// ExitCodeQuotaExceeded is used when the image does not fit in the disk
+	// limit
+	ExitCodeQuotaExceeded = 3
+	// ExitCodeDigestMismatch is used when a blob does not match its digest or
+	// size in the manifest
+	ExitCodeDigestMismatch = 4
+	// ExitCodeUnauthorized is used when the registry rejects the credentials,
+	// or when there are none and it requires them
+	ExitCodeUnauthorized = 5
+	// ExitCodeImageNotFound is used when the image, its tag, its platform or
+	// any of its blobs do not exist
+	ExitCodeImageNotFound = 6
+	// ExitCodeRegistryUnavailable is used when the registry cannot be reached
+	// or keeps failing after all retries
+	ExitCodeRegistryUnavailable = 7
+	// ExitCodeDriverFailure is used when the driver fails
+	ExitCodeDriverFailure = 8
+	// ExitCodePolicyRejected is used when the image policy does not allow the
+	// image, or the signature policy rejects it
+	ExitCodePolicyRejected = 9
+)
+
+// errorClasses are checked in order, so that a driver failure caused by a
+// layer that could not be read is reported as what made reading it fail
+var errorClasses = []struct {
+	err      error
+	kind     string
+	exitCode int
+}{
+	{ErrInvalidArgument, "invalid_argument", ExitCodeInvalidArgument},
+	{ErrPolicyRejected, "policy_rejected", ExitCodePolicyRejected},
+	{ErrQuotaExceeded, "quota_exceeded", ExitCodeQuotaExceeded},
+	{ErrDigestMismatch, "digest_mismatch", ExitCodeDigestMismatch},
+	{ErrUnauthorized, "unauthorized", ExitCodeUnauthorized},
+	{ErrImageNotFound, "image_not_found", ExitCodeImageNotFound},
+	{ErrRegistryUnavailable, "registry_unavailable", ExitCodeRegistryUnavailable},
+	{ErrDriver, "driver_failure", ExitCodeDriverFailure},
+}
+
+// ExitCode returns the exit code the command line reports err with
+func ExitCode(err error) int {
+	_, exitCode := classifyError(err)
+	return exitCode
+}
+
+func classifyError(err error) (string, int) {
+	for _, class := range errorClasses {
+		if errors.Is(err, class.err) {
+			return class.kind, class.exitCode
+		}
+	}
+	return "failure", ExitCodeFailure
+}
+
+// JSONError is what the command line prints to stderr when a command fails
+// and --json-errors is given
+type JSONError struct {
+	Error    string `json:"error"`
+	Kind     string `json:"kind"`
+	ExitCode int    `json:"exit_code"`
+}
+
+func writeJSONError(w io.Writer, err error) error {
+	kind, exitCode := classifyError(err)
+	return json.NewEncoder(w).Encode(JSONError{Error: err.Error(), Kind: kind, ExitCode: exitCode})
+}
+
+func invalidArgument(err error) error {
+	return imagepuller.Classify(ErrInvalidArgument, err)
+}
+
+func policyRejected(err error) error {
+	return imagepuller.Classify(ErrPolicyRejected, err)
+}
+
+func driverFailure(err error) error {
+	return imagepuller.Classify(ErrDriver, err)
+}
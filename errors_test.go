@@ -0,0 +1,42 @@
+package groot_test
+
+import (
+	"fmt"
+
+	"code.cloudfoundry.org/groot"
+	"code.cloudfoundry.org/groot/imagepuller"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	errors "github.com/pkg/errors"
+)
+
+var _ = Describe("ExitCode", func() {
+	DescribeTable("classified errors",
+		func(kind error, expectedExitCode int) {
+			err := errors.Wrap(imagepuller.Classify(kind, errors.New("failed")), "pulling image")
+			Expect(groot.ExitCode(err)).To(Equal(expectedExitCode))
+		},
+		Entry("invalid argument", groot.ErrInvalidArgument, groot.ExitCodeInvalidArgument),
+		Entry("quota exceeded", groot.ErrQuotaExceeded, groot.ExitCodeQuotaExceeded),
+		Entry("digest mismatch", groot.ErrDigestMismatch, groot.ExitCodeDigestMismatch),
+		Entry("unauthorized", groot.ErrUnauthorized, groot.ExitCodeUnauthorized),
+		Entry("image not found", groot.ErrImageNotFound, groot.ExitCodeImageNotFound),
+		Entry("registry unavailable", groot.ErrRegistryUnavailable, groot.ExitCodeRegistryUnavailable),
+		Entry("driver failure", groot.ErrDriver, groot.ExitCodeDriverFailure),
+		Entry("policy rejected", groot.ErrPolicyRejected, groot.ExitCodePolicyRejected),
+	)
+
+	It("returns the generic exit code for unclassified errors", func() {
+		Expect(groot.ExitCode(errors.New("failed"))).To(Equal(groot.ExitCodeFailure))
+	})
+
+	It("finds the class through fmt wrapping", func() {
+		err := fmt.Errorf("pulling image: %w", imagepuller.Classify(groot.ErrUnauthorized, errors.New("denied")))
+		Expect(groot.ExitCode(err)).To(Equal(groot.ExitCodeUnauthorized))
+	})
+
+	It("prefers the cause of a driver failure", func() {
+		err := imagepuller.Classify(groot.ErrDriver, imagepuller.Classify(groot.ErrDigestMismatch, errors.New("bad layer")))
+		Expect(groot.ExitCode(err)).To(Equal(groot.ExitCodeDigestMismatch))
+	})
+})
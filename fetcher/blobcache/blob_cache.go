@@ -0,0 +1,304 @@
+package blobcache // import "code.cloudfoundry.org/groot/fetcher/blobcache"
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+	"github.com/containers/image/v5/pkg/blobinfocache"
+	"github.com/containers/image/v5/types"
+	digestpkg "github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+)
+
+// BlobCache stores compressed blobs on disk, keyed by their digest, so that
+// they can be shared across groot invocations. When the cache grows beyond its
+// maximum size, the least recently used blobs are evicted.
+//
+// Blobs are written to a temporary file and renamed into place once their
+// digest has been checked, so concurrent invocations never see partial blobs.
+type BlobCache struct {
+	dir           string
+	maxSize       int64
+	blobInfoCache types.BlobInfoCache
+}
+
+// New creates a BlobCache in dir. A maxSize that is not positive means that
+// the cache is never evicted.
+func New(dir string, maxSize int64) (*BlobCache, error) {
+	cache := &BlobCache{dir: dir, maxSize: maxSize}
+	for _, path := range []string{cache.blobsDir(), cache.refsDir(), cache.tempDir()} {
+		if err := os.MkdirAll(path, 0700); err != nil {
+			return nil, errors.Wrap(err, "creating blob cache")
+		}
+	}
+
+	cache.blobInfoCache = blobinfocache.DefaultCache(&types.SystemContext{BlobInfoCacheDir: dir})
+	return cache, nil
+}
+
+// BlobInfoCache returns the containers/image blob info cache, which is kept in
+// the same directory as the blobs
+func (c *BlobCache) BlobInfoCache() types.BlobInfoCache {
+	return c.blobInfoCache
+}
+
+// Get returns the cached blob with the given digest and its size. The blob is
+// checked against its digest first; blobs that do not match are removed and
+// treated as not cached.
+func (c *BlobCache) Get(logger lager.Logger, digest digestpkg.Digest) (io.ReadCloser, int64, bool) {
+	logger = logger.Session("blob-cache-get", lager.Data{"digest": digest})
+
+	if digest.Validate() != nil {
+		return nil, 0, false
+	}
+
+	path := c.blobPath(digest)
+	blob, err := os.Open(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Error("opening-blob-failed", err)
+		}
+		return nil, 0, false
+	}
+
+	if err := verify(blob, digest); err != nil {
+		logger.Error("verifying-blob-failed", err)
+		blob.Close()
+		_ = os.Remove(path)
+		return nil, 0, false
+	}
+
+	size, err := blob.Seek(0, io.SeekCurrent)
+	if err == nil {
+		_, err = blob.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		logger.Error("rewinding-blob-failed", err)
+		blob.Close()
+		return nil, 0, false
+	}
+
+	now := time.Now()
+	if err := os.Chtimes(path, now, now); err != nil {
+		logger.Debug("touching-blob-failed", lager.Data{"error": err.Error()})
+	}
+
+	return blob, size, true
+}
+
+// Has returns whether the cache has the blob with the given digest, without
+// verifying it
+func (c *BlobCache) Has(digest digestpkg.Digest) bool {
+	if digest.Validate() != nil {
+		return false
+	}
+
+	_, err := os.Stat(c.blobPath(digest))
+	return err == nil
+}
+
+// Put adds a blob that is already in memory, such as a manifest, to the cache
+func (c *BlobCache) Put(logger lager.Logger, digest digestpkg.Digest, contents []byte) error {
+	writer, err := c.NewWriter(digest)
+	if err != nil {
+		return err
+	}
+	defer writer.Close()
+
+	if _, err := writer.Write(contents); err != nil {
+		return errors.Wrap(err, "writing blob cache file")
+	}
+	return writer.Commit(logger)
+}
+
+// SetReference records the digest of the manifest that an image reference,
+// such as a tag, pointed to when it was last pulled
+func (c *BlobCache) SetReference(name string, digest digestpkg.Digest) error {
+	file, err := os.CreateTemp(c.tempDir(), "ref-")
+	if err != nil {
+		return errors.Wrap(err, "creating reference file")
+	}
+	defer os.Remove(file.Name())
+
+	_, err = file.WriteString(digest.String())
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.Wrap(err, "writing reference file")
+	}
+
+	return errors.Wrap(os.Rename(file.Name(), c.refPath(name)), "adding reference to cache")
+}
+
+// Reference returns the manifest digest recorded for an image reference
+func (c *BlobCache) Reference(name string) (digestpkg.Digest, bool) {
+	contents, err := os.ReadFile(c.refPath(name))
+	if err != nil {
+		return "", false
+	}
+
+	digest := digestpkg.Digest(contents)
+	if digest.Validate() != nil {
+		return "", false
+	}
+	return digest, true
+}
+
+// NewWriter returns a Writer that adds the blob with the given digest to the
+// cache once it has been written and committed
+func (c *BlobCache) NewWriter(digest digestpkg.Digest) (*Writer, error) {
+	if err := digest.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid blob digest")
+	}
+
+	file, err := os.CreateTemp(c.tempDir(), "blob-")
+	if err != nil {
+		return nil, errors.Wrap(err, "creating blob cache file")
+	}
+
+	return &Writer{
+		cache:    c,
+		file:     file,
+		digest:   digest,
+		digester: digest.Algorithm().Digester(),
+	}, nil
+}
+
+// Evict removes the least recently used blobs until the cache fits in its
+// maximum size
+func (c *BlobCache) Evict(logger lager.Logger) error {
+	if c.maxSize <= 0 {
+		return nil
+	}
+
+	logger = logger.Session("blob-cache-evict", lager.Data{"maxSize": c.maxSize})
+
+	blobs := []blobFile{}
+	var totalSize int64
+	err := filepath.Walk(c.blobsDir(), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.Mode().IsRegular() {
+			blobs = append(blobs, blobFile{path: path, size: info.Size(), lastUsed: info.ModTime()})
+			totalSize += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "listing cached blobs")
+	}
+
+	sort.Slice(blobs, func(i, j int) bool {
+		return blobs[i].lastUsed.Before(blobs[j].lastUsed)
+	})
+
+	for _, blob := range blobs {
+		if totalSize <= c.maxSize {
+			break
+		}
+
+		if err := os.Remove(blob.path); err != nil && !os.IsNotExist(err) {
+			return errors.Wrap(err, "evicting blob")
+		}
+		logger.Debug("evicted-blob", lager.Data{"path": blob.path, "size": blob.size})
+		totalSize -= blob.size
+	}
+
+	return nil
+}
+
+func (c *BlobCache) blobsDir() string {
+	return filepath.Join(c.dir, "blobs")
+}
+
+func (c *BlobCache) refsDir() string {
+	return filepath.Join(c.dir, "refs")
+}
+
+// refPath hashes the reference name, as it contains characters that are not
+// valid in file names
+func (c *BlobCache) refPath(name string) string {
+	return filepath.Join(c.refsDir(), digestpkg.FromString(name).Encoded())
+}
+
+func (c *BlobCache) tempDir() string {
+	return filepath.Join(c.dir, "tmp")
+}
+
+func (c *BlobCache) blobPath(digest digestpkg.Digest) string {
+	return filepath.Join(c.blobsDir(), digest.Algorithm().String(), digest.Encoded())
+}
+
+type blobFile struct {
+	path     string
+	size     int64
+	lastUsed time.Time
+}
+
+func verify(blob io.Reader, digest digestpkg.Digest) error {
+	verifier := digest.Verifier()
+	if _, err := io.Copy(verifier, blob); err != nil {
+		return err
+	}
+	if !verifier.Verified() {
+		return errors.Errorf("blob does not match digest %s", digest)
+	}
+	return nil
+}
+
+// Writer writes a blob to a temporary file. Commit adds it to the cache; Close
+// discards it unless it was committed.
+type Writer struct {
+	cache     *BlobCache
+	file      *os.File
+	digest    digestpkg.Digest
+	digester  digestpkg.Digester
+	committed bool
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	n, err := w.file.Write(p)
+	_, _ = w.digester.Hash().Write(p[:n])
+	return n, err
+}
+
+// Commit adds the blob to the cache, if it matches its digest, and evicts
+// blobs that no longer fit
+func (w *Writer) Commit(logger lager.Logger) error {
+	if err := w.file.Close(); err != nil {
+		return errors.Wrap(err, "closing blob cache file")
+	}
+
+	if w.digester.Digest() != w.digest {
+		return errors.Errorf("blob does not match digest %s", w.digest)
+	}
+
+	path := w.cache.blobPath(w.digest)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return errors.Wrap(err, "creating blob cache directory")
+	}
+	if err := os.Rename(w.file.Name(), path); err != nil {
+		return errors.Wrap(err, "adding blob to cache")
+	}
+	w.committed = true
+
+	return w.cache.Evict(logger)
+}
+
+func (w *Writer) Close() error {
+	if w.committed {
+		return nil
+	}
+
+	_ = w.file.Close()
+	return os.Remove(w.file.Name())
+}
@@ -0,0 +1,13 @@
+package blobcache_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestBlobCache(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Blob Cache Suite")
+}
@@ -0,0 +1,202 @@
+package blobcache_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/groot/fetcher/blobcache"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	digestpkg "github.com/opencontainers/go-digest"
+)
+
+var _ = Describe("BlobCache", func() {
+	var (
+		logger    *lagertest.TestLogger
+		cacheDir  string
+		maxSize   int64
+		blobCache *blobcache.BlobCache
+	)
+
+	addBlob := func(contents string) digestpkg.Digest {
+		digest := digestpkg.FromString(contents)
+		writer, err := blobCache.NewWriter(digest)
+		Expect(err).NotTo(HaveOccurred())
+		defer writer.Close()
+
+		_, err = io.WriteString(writer, contents)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(writer.Commit(logger)).To(Succeed())
+		return digest
+	}
+
+	get := func(digest digestpkg.Digest) string {
+		blob, size, ok := blobCache.Get(logger, digest)
+		Expect(ok).To(BeTrue())
+		defer blob.Close()
+
+		contents, err := io.ReadAll(blob)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(size).To(Equal(int64(len(contents))))
+		return string(contents)
+	}
+
+	isCached := func(digest digestpkg.Digest) bool {
+		blob, _, ok := blobCache.Get(logger, digest)
+		if ok {
+			Expect(blob.Close()).To(Succeed())
+		}
+		return ok
+	}
+
+	blobPath := func(digest digestpkg.Digest) string {
+		return filepath.Join(cacheDir, "blobs", "sha256", digest.Encoded())
+	}
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("blob-cache")
+		maxSize = 0
+
+		var err error
+		cacheDir, err = os.MkdirTemp("", "blob-cache")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	JustBeforeEach(func() {
+		var err error
+		blobCache, err = blobcache.New(cacheDir, maxSize)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(cacheDir)).To(Succeed())
+	})
+
+	It("returns the blobs that were added", func() {
+		digest := addBlob("some-blob")
+		Expect(get(digest)).To(Equal("some-blob"))
+	})
+
+	It("does not return blobs that were not added", func() {
+		Expect(isCached(digestpkg.FromString("some-blob"))).To(BeFalse())
+	})
+
+	It("shares the blobs with other caches in the same directory", func() {
+		digest := addBlob("some-blob")
+
+		otherCache, err := blobcache.New(cacheDir, 0)
+		Expect(err).NotTo(HaveOccurred())
+		blob, _, ok := otherCache.Get(logger, digest)
+		Expect(ok).To(BeTrue())
+		Expect(blob.Close()).To(Succeed())
+	})
+
+	It("adds blobs that are already in memory", func() {
+		digest := digestpkg.FromString("some-manifest")
+		Expect(blobCache.Put(logger, digest, []byte("some-manifest"))).To(Succeed())
+		Expect(get(digest)).To(Equal("some-manifest"))
+	})
+
+	It("tells whether it has a blob", func() {
+		digest := addBlob("some-blob")
+		Expect(blobCache.Has(digest)).To(BeTrue())
+		Expect(blobCache.Has(digestpkg.FromString("some-other-blob"))).To(BeFalse())
+	})
+
+	Describe("references", func() {
+		It("returns the digest recorded for a reference", func() {
+			digest := digestpkg.FromString("some-manifest")
+			Expect(blobCache.SetReference("docker://registry/image:latest", digest)).To(Succeed())
+
+			recordedDigest, ok := blobCache.Reference("docker://registry/image:latest")
+			Expect(ok).To(BeTrue())
+			Expect(recordedDigest).To(Equal(digest))
+		})
+
+		It("replaces the digest recorded for a reference", func() {
+			Expect(blobCache.SetReference("docker://registry/image:latest", digestpkg.FromString("some-manifest"))).To(Succeed())
+			Expect(blobCache.SetReference("docker://registry/image:latest", digestpkg.FromString("some-other-manifest"))).To(Succeed())
+
+			recordedDigest, _ := blobCache.Reference("docker://registry/image:latest")
+			Expect(recordedDigest).To(Equal(digestpkg.FromString("some-other-manifest")))
+		})
+
+		It("does not return a digest for unknown references", func() {
+			_, ok := blobCache.Reference("docker://registry/image:latest")
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	Context("when the written blob does not match its digest", func() {
+		It("does not add it", func() {
+			digest := digestpkg.FromString("some-blob")
+			writer, err := blobCache.NewWriter(digest)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = io.WriteString(writer, "some-other-blob")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(writer.Commit(logger)).To(MatchError(ContainSubstring("blob does not match digest")))
+			Expect(writer.Close()).To(Succeed())
+
+			Expect(isCached(digest)).To(BeFalse())
+			Expect(filepath.Join(cacheDir, "tmp")).To(BeADirectory())
+			Expect(os.ReadDir(filepath.Join(cacheDir, "tmp"))).To(BeEmpty())
+		})
+	})
+
+	Context("when the writer is closed without committing", func() {
+		It("discards the blob", func() {
+			digest := digestpkg.FromString("some-blob")
+			writer, err := blobCache.NewWriter(digest)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = io.WriteString(writer, "some-blob")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(writer.Close()).To(Succeed())
+
+			Expect(isCached(digest)).To(BeFalse())
+			Expect(os.ReadDir(filepath.Join(cacheDir, "tmp"))).To(BeEmpty())
+		})
+	})
+
+	Context("when a cached blob is corrupted", func() {
+		It("removes it", func() {
+			digest := addBlob("some-blob")
+			Expect(os.WriteFile(blobPath(digest), []byte("corrupted"), 0600)).To(Succeed())
+
+			Expect(isCached(digest)).To(BeFalse())
+			Expect(blobPath(digest)).NotTo(BeAnExistingFile())
+		})
+	})
+
+	Context("when the digest is invalid", func() {
+		It("cannot be written", func() {
+			_, err := blobCache.NewWriter(digestpkg.Digest("sha256:../../etc/passwd"))
+			Expect(err).To(MatchError(ContainSubstring("invalid blob digest")))
+		})
+	})
+
+	Context("when the cache has a maximum size", func() {
+		BeforeEach(func() {
+			maxSize = 25
+		})
+
+		It("evicts the least recently used blobs", func() {
+			first := addBlob("first-blob")
+			second := addBlob("second-blob")
+			Expect(os.Chtimes(blobPath(first), time.Now().Add(-time.Hour), time.Now().Add(-time.Hour))).To(Succeed())
+			Expect(os.Chtimes(blobPath(second), time.Now().Add(-2*time.Hour), time.Now().Add(-2*time.Hour))).To(Succeed())
+
+			// Reading the second blob makes it the most recently used one
+			Expect(get(second)).To(Equal("second-blob"))
+			third := addBlob("third-blob")
+
+			Expect(isCached(first)).To(BeFalse())
+			Expect(get(second)).To(Equal("second-blob"))
+			Expect(get(third)).To(Equal("third-blob"))
+		})
+	})
+})
@@ -1,6 +1,7 @@
 package filefetcher // import "code.cloudfoundry.org/groot/fetcher/filefetcher"
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
@@ -21,7 +22,7 @@ func NewFileFetcher(imageURL *url.URL) *FileFetcher {
 	return &FileFetcher{imagePath: imageURL.String()}
 }
 
-func (l *FileFetcher) StreamBlob(logger lager.Logger, layerInfo imagepuller.LayerInfo) (io.ReadCloser, int64, error) {
+func (l *FileFetcher) StreamBlob(ctx context.Context, logger lager.Logger, layerInfo imagepuller.LayerInfo) (io.ReadCloser, int64, error) {
 	logger = logger.Session("stream-blob", lager.Data{"imagePath": l.imagePath})
 	logger.Info("starting", lager.Data{
 		"source": layerInfo.BlobID,
@@ -29,7 +30,7 @@ func (l *FileFetcher) StreamBlob(logger lager.Logger, layerInfo imagepuller.Laye
 	defer logger.Info("ending")
 
 	if _, err := os.Stat(l.imagePath); err != nil {
-		return nil, 0, errors.Wrapf(err, "local image not found in `%s`", l.imagePath)
+		return nil, 0, imagepuller.Classify(imagepuller.ErrImageNotFound, errors.Wrapf(err, "local image not found in `%s`", l.imagePath))
 	}
 
 	if err := l.validateImage(); err != nil {
@@ -45,7 +46,7 @@ func (l *FileFetcher) StreamBlob(logger lager.Logger, layerInfo imagepuller.Laye
 	return stream, 0, nil
 }
 
-func (l *FileFetcher) ImageInfo(logger lager.Logger) (imagepuller.ImageInfo, error) {
+func (l *FileFetcher) ImageInfo(ctx context.Context, logger lager.Logger) (imagepuller.ImageInfo, error) {
 	logger = logger.Session("layers-digest", lager.Data{"imagePath": l.imagePath})
 
 	logger.Info("starting")
@@ -53,8 +54,11 @@ func (l *FileFetcher) ImageInfo(logger lager.Logger) (imagepuller.ImageInfo, err
 
 	stat, err := os.Stat(l.imagePath)
 	if err != nil {
-		return imagepuller.ImageInfo{},
-			errors.Wrap(err, "fetching image timestamp")
+		err = errors.Wrap(err, "fetching image timestamp")
+		if os.IsNotExist(errors.Cause(err)) {
+			err = imagepuller.Classify(imagepuller.ErrImageNotFound, err)
+		}
+		return imagepuller.ImageInfo{}, err
 	}
 
 	return imagepuller.ImageInfo{
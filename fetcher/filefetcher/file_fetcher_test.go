@@ -1,6 +1,7 @@
 package filefetcher_test
 
 import (
+	"context"
 	"io"
 	"net/url"
 	"os"
@@ -52,7 +53,7 @@ var _ = Describe("File Fetcher", func() {
 		)
 
 		JustBeforeEach(func() {
-			stream, _, streamErr = fetcher.StreamBlob(logger, imagepuller.LayerInfo{})
+			stream, _, streamErr = fetcher.StreamBlob(context.Background(), logger, imagepuller.LayerInfo{})
 		})
 
 		AfterEach(func() {
@@ -100,7 +101,7 @@ var _ = Describe("File Fetcher", func() {
 		)
 
 		JustBeforeEach(func() {
-			imageInfo, infoErr = fetcher.ImageInfo(logger)
+			imageInfo, infoErr = fetcher.ImageInfo(context.Background(), logger)
 		})
 
 		It("does not return an error", func() {
@@ -125,7 +126,7 @@ var _ = Describe("File Fetcher", func() {
 			})
 
 			It("generates another chain id", func() {
-				newImageInfo, err := fetcher.ImageInfo(logger)
+				newImageInfo, err := fetcher.ImageInfo(context.Background(), logger)
 				Expect(err).NotTo(HaveOccurred())
 				Expect(imageInfo.LayerInfos[0].ChainID).NotTo(Equal(newImageInfo.LayerInfos[0].ChainID))
 			})
@@ -139,6 +140,10 @@ var _ = Describe("File Fetcher", func() {
 			It("returns an error", func() {
 				Expect(infoErr).To(MatchError(ContainSubstring("fetching image timestamp")))
 			})
+
+			It("classifies it as image not found", func() {
+				Expect(infoErr).To(MatchError(imagepuller.ErrImageNotFound))
+			})
 		})
 	})
 })
@@ -11,6 +11,7 @@ import (
 	"code.cloudfoundry.org/groot/imagepuller"
 	"code.cloudfoundry.org/lager/v3"
 
+	manifestpkg "github.com/containers/image/v5/manifest"
 	"github.com/containers/image/v5/types"
 	imgspec "github.com/opencontainers/image-spec/specs-go/v1"
 	errorspkg "github.com/pkg/errors"
@@ -26,51 +27,81 @@ type Manifest interface {
 }
 
 type Source interface {
-	Manifest(logger lager.Logger) (types.Image, error)
-	Blob(logger lager.Logger, layerInfo imagepuller.LayerInfo) (string, int64, error)
+	Manifest(ctx context.Context, logger lager.Logger) (types.Image, error)
+	Blob(ctx context.Context, logger lager.Logger, layerInfo imagepuller.LayerInfo) (string, int64, error)
+	StreamBlob(ctx context.Context, logger lager.Logger, layerInfo imagepuller.LayerInfo) (io.ReadCloser, int64, error)
+	MissingBlobs(ctx context.Context, logger lager.Logger, layerInfos []imagepuller.LayerInfo) ([]string, error)
 	Close() error
 }
 
 type LayerFetcher struct {
-	source Source
+	source      Source
+	streamBlobs bool
 }
 
-func NewLayerFetcher(source Source) *LayerFetcher {
+// NewLayerFetcher creates a LayerFetcher. When streamBlobs is true, blobs are
+// passed on to the driver while being downloaded instead of being stored in a
+// temporary file first; invalid blobs then fail at the end of the stream.
+func NewLayerFetcher(source Source, streamBlobs bool) *LayerFetcher {
 	return &LayerFetcher{
-		source: source,
+		source:      source,
+		streamBlobs: streamBlobs,
 	}
 }
 
-func (f *LayerFetcher) ImageInfo(logger lager.Logger) (imagepuller.ImageInfo, error) {
+func (f *LayerFetcher) ImageInfo(ctx context.Context, logger lager.Logger) (imagepuller.ImageInfo, error) {
 	logger = logger.Session("layers-digest")
 	logger.Info("starting")
 	defer logger.Info("ending")
 
 	logger.Debug("fetching-image-manifest")
-	manifest, err := f.source.Manifest(logger)
+	manifest, err := f.source.Manifest(ctx, logger)
 	if err != nil {
 		return imagepuller.ImageInfo{}, err
 	}
 
 	logger.Debug("fetching-image-config")
 	var config *imgspec.Image
-	config, err = manifest.OCIConfig(context.TODO())
+	config, err = manifest.OCIConfig(ctx)
 	if err != nil {
 		return imagepuller.ImageInfo{}, err
 	}
 
+	manifestBlob, mediaType, err := manifest.Manifest(ctx)
+	if err != nil {
+		return imagepuller.ImageInfo{}, errorspkg.Wrap(err, "reading image manifest")
+	}
+
+	manifestDigest, err := manifestpkg.Digest(manifestBlob)
+	if err != nil {
+		return imagepuller.ImageInfo{}, errorspkg.Wrap(err, "computing manifest digest")
+	}
+
 	return imagepuller.ImageInfo{
-		LayerInfos: f.createLayerInfos(logger, manifest, config),
-		Config:     *config,
+		LayerInfos:     f.createLayerInfos(logger, manifest, config),
+		Config:         *config,
+		ManifestDigest: manifestDigest.String(),
+		MediaType:      mediaType,
+		ConfigDigest:   manifest.ConfigInfo().Digest.String(),
 	}, nil
 }
 
-func (f *LayerFetcher) StreamBlob(logger lager.Logger, layerInfo imagepuller.LayerInfo) (io.ReadCloser, int64, error) {
-	logger = logger.Session("streaming")
+func (f *LayerFetcher) StreamBlob(ctx context.Context, logger lager.Logger, layerInfo imagepuller.LayerInfo) (io.ReadCloser, int64, error) {
+	logger = logger.Session("streaming", lager.Data{"streamBlobs": f.streamBlobs})
 	logger.Info("starting")
 	defer logger.Info("ending")
 
-	blobFilePath, size, err := f.source.Blob(logger, layerInfo)
+	if f.streamBlobs {
+		stream, size, err := f.source.StreamBlob(ctx, logger, layerInfo)
+		if err != nil {
+			logger.Error("source-stream-blob-failed", err, lager.Data{"blobId": layerInfo.BlobID, "URL": layerInfo.URLs})
+			return nil, 0, err
+		}
+
+		return stream, size, nil
+	}
+
+	blobFilePath, size, err := f.source.Blob(ctx, logger, layerInfo)
 	if err != nil {
 		logger.Error("source-blob-failed", err, lager.Data{"blobId": layerInfo.BlobID, "URL": layerInfo.URLs})
 		return nil, 0, err
@@ -85,6 +116,10 @@ func (f *LayerFetcher) StreamBlob(logger lager.Logger, layerInfo imagepuller.Lay
 	return blobReader, size, nil
 }
 
+func (f *LayerFetcher) MissingBlobs(ctx context.Context, logger lager.Logger, layerInfos []imagepuller.LayerInfo) ([]string, error) {
+	return f.source.MissingBlobs(ctx, logger, layerInfos)
+}
+
 func (f *LayerFetcher) Close() error {
 	return f.source.Close()
 }
@@ -3,9 +3,12 @@ package layerfetcher_test
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 	"time"
 
 	"code.cloudfoundry.org/groot/imagepuller"
@@ -20,6 +23,8 @@ import (
 	specsv1 "github.com/opencontainers/image-spec/specs-go/v1"
 )
 
+type contextKey struct{}
+
 var _ = Describe("LayerFetcher", func() {
 	var (
 		fakeSource        *layerfetcherfakes.FakeSource
@@ -37,7 +42,7 @@ var _ = Describe("LayerFetcher", func() {
 		Expect(gzipWriter.Close()).To(Succeed())
 		gzipedBlobContent = readAll(gzipBuffer)
 
-		fetcher = layerfetcher.NewLayerFetcher(fakeSource)
+		fetcher = layerfetcher.NewLayerFetcher(fakeSource, false)
 
 		var err error
 		logger = lagertest.NewTestLogger("test-layer-fetcher")
@@ -50,19 +55,71 @@ var _ = Describe("LayerFetcher", func() {
 			fakeManifest.OCIConfigReturns(&specsv1.Image{}, nil)
 			fakeSource.ManifestReturns(fakeManifest, nil)
 
-			_, err := fetcher.ImageInfo(logger)
+			_, err := fetcher.ImageInfo(context.Background(), logger)
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(fakeSource.ManifestCallCount()).To(Equal(1))
 		})
 
+		It("passes the context to the source", func() {
+			fakeManifest := new(layerfetcherfakes.FakeManifest)
+			fakeManifest.OCIConfigReturns(&specsv1.Image{}, nil)
+			fakeSource.ManifestReturns(fakeManifest, nil)
+
+			ctx := context.WithValue(context.Background(), contextKey{}, "pull")
+			_, err := fetcher.ImageInfo(ctx, logger)
+			Expect(err).NotTo(HaveOccurred())
+
+			sourceCtx, _ := fakeSource.ManifestArgsForCall(0)
+			Expect(sourceCtx.Value(contextKey{})).To(Equal("pull"))
+			oCIConfigCtx := fakeManifest.OCIConfigArgsForCall(0)
+			Expect(oCIConfigCtx.Value(contextKey{})).To(Equal("pull"))
+		})
+
+		It("returns the digest and media type of the manifest", func() {
+			fakeManifest := new(layerfetcherfakes.FakeManifest)
+			fakeManifest.OCIConfigReturns(&specsv1.Image{}, nil)
+			fakeManifest.ManifestReturns([]byte(`{"schemaVersion":2}`), specsv1.MediaTypeImageManifest, nil)
+			fakeSource.ManifestReturns(fakeManifest, nil)
+
+			imageInfo, err := fetcher.ImageInfo(context.Background(), logger)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(imageInfo.ManifestDigest).To(Equal(digestpkg.FromString(`{"schemaVersion":2}`).String()))
+			Expect(imageInfo.MediaType).To(Equal(specsv1.MediaTypeImageManifest))
+		})
+
+		It("returns the digest of the config", func() {
+			fakeManifest := new(layerfetcherfakes.FakeManifest)
+			fakeManifest.OCIConfigReturns(&specsv1.Image{}, nil)
+			fakeManifest.ConfigInfoReturns(types.BlobInfo{Digest: "sha256:config"})
+			fakeSource.ManifestReturns(fakeManifest, nil)
+
+			imageInfo, err := fetcher.ImageInfo(context.Background(), logger)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(imageInfo.ConfigDigest).To(Equal("sha256:config"))
+		})
+
+		Context("when reading the manifest fails", func() {
+			BeforeEach(func() {
+				fakeManifest := new(layerfetcherfakes.FakeManifest)
+				fakeManifest.OCIConfigReturns(&specsv1.Image{}, nil)
+				fakeManifest.ManifestReturns(nil, "", errors.New("manifest is gone"))
+				fakeSource.ManifestReturns(fakeManifest, nil)
+			})
+
+			It("returns an error", func() {
+				_, err := fetcher.ImageInfo(context.Background(), logger)
+				Expect(err).To(MatchError(ContainSubstring("manifest is gone")))
+			})
+		})
+
 		Context("when fetching the manifest fails", func() {
 			BeforeEach(func() {
 				fakeSource.ManifestReturns(nil, errors.New("fetching the manifest"))
 			})
 
 			It("returns an error", func() {
-				_, err := fetcher.ImageInfo(logger)
+				_, err := fetcher.ImageInfo(context.Background(), logger)
 				Expect(err).To(MatchError(ContainSubstring("fetching the manifest")))
 			})
 		})
@@ -91,7 +148,7 @@ var _ = Describe("LayerFetcher", func() {
 			})
 			fakeSource.ManifestReturns(fakeManifest, nil)
 
-			imageInfo, err := fetcher.ImageInfo(logger)
+			imageInfo, err := fetcher.ImageInfo(context.Background(), logger)
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(imageInfo.LayerInfos).To(Equal([]imagepuller.LayerInfo{
@@ -120,7 +177,7 @@ var _ = Describe("LayerFetcher", func() {
 			})
 
 			It("returns the error", func() {
-				_, err := fetcher.ImageInfo(logger)
+				_, err := fetcher.ImageInfo(context.Background(), logger)
 				Expect(err).To(MatchError(ContainSubstring("OCI Config retrieval failed")))
 			})
 		})
@@ -141,7 +198,7 @@ var _ = Describe("LayerFetcher", func() {
 			fakeManifest.OCIConfigReturns(&expectedConfig, nil)
 			fakeSource.ManifestReturns(fakeManifest, nil)
 
-			imageInfo, err := fetcher.ImageInfo(logger)
+			imageInfo, err := fetcher.ImageInfo(context.Background(), logger)
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(imageInfo.Config).To(Equal(expectedConfig))
@@ -170,7 +227,7 @@ var _ = Describe("LayerFetcher", func() {
 		})
 
 		It("uses the source", func() {
-			stream, _, err := fetcher.StreamBlob(logger, layerInfo)
+			stream, _, err := fetcher.StreamBlob(context.Background(), logger, layerInfo)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(stream.Close()).To(Succeed())
 
@@ -178,8 +235,18 @@ var _ = Describe("LayerFetcher", func() {
 			Expect(layerInfo.BlobID).To(Equal("sha256:layer-digest"))
 		})
 
+		It("passes the context to the source", func() {
+			ctx := context.WithValue(context.Background(), contextKey{}, "pull")
+			stream, _, err := fetcher.StreamBlob(ctx, logger, layerInfo)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stream.Close()).To(Succeed())
+
+			sourceCtx, _, _ := fakeSource.BlobArgsForCall(0)
+			Expect(sourceCtx.Value(contextKey{})).To(Equal("pull"))
+		})
+
 		It("returns the stream from the source", func() {
-			stream, _, err := fetcher.StreamBlob(logger, layerInfo)
+			stream, _, err := fetcher.StreamBlob(context.Background(), logger, layerInfo)
 			Expect(err).NotTo(HaveOccurred())
 			defer stream.Close()
 
@@ -194,7 +261,7 @@ var _ = Describe("LayerFetcher", func() {
 
 			fakeSource.BlobReturns(tmpFile.Name(), 1024, nil)
 
-			stream, size, err := fetcher.StreamBlob(logger, layerInfo)
+			stream, size, err := fetcher.StreamBlob(context.Background(), logger, layerInfo)
 			Expect(err).NotTo(HaveOccurred())
 			defer stream.Close()
 			Expect(size).To(Equal(int64(1024)))
@@ -204,12 +271,81 @@ var _ = Describe("LayerFetcher", func() {
 			It("returns an error", func() {
 				fakeSource.BlobReturns("", 0, errors.New("failed to stream blob"))
 
-				_, _, err := fetcher.StreamBlob(logger, layerInfo)
+				_, _, err := fetcher.StreamBlob(context.Background(), logger, layerInfo)
 				Expect(err).To(MatchError(ContainSubstring("failed to stream blob")))
 			})
 		})
 	})
 
+	Describe("StreamBlob when streaming blobs", func() {
+		var layerInfo = imagepuller.LayerInfo{
+			BlobID: "sha256:layer-digest",
+		}
+
+		BeforeEach(func() {
+			fetcher = layerfetcher.NewLayerFetcher(fakeSource, true)
+			fakeSource.StreamBlobReturns(io.NopCloser(strings.NewReader("hello-world")), 1024, nil)
+		})
+
+		It("streams the blob from the source", func() {
+			stream, size, err := fetcher.StreamBlob(context.Background(), logger, layerInfo)
+			Expect(err).NotTo(HaveOccurred())
+			defer stream.Close()
+
+			Expect(readAll(stream)).To(Equal("hello-world"))
+			Expect(size).To(Equal(int64(1024)))
+
+			Expect(fakeSource.StreamBlobCallCount()).To(Equal(1))
+			_, _, actualLayerInfo := fakeSource.StreamBlobArgsForCall(0)
+			Expect(actualLayerInfo).To(Equal(layerInfo))
+		})
+
+		It("does not store the blob in a temporary file", func() {
+			_, _, err := fetcher.StreamBlob(context.Background(), logger, layerInfo)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeSource.BlobCallCount()).To(BeZero())
+		})
+
+		Context("when the source fails to stream the blob", func() {
+			It("returns an error", func() {
+				fakeSource.StreamBlobReturns(nil, 0, errors.New("failed to stream blob"))
+
+				_, _, err := fetcher.StreamBlob(context.Background(), logger, layerInfo)
+				Expect(err).To(MatchError(ContainSubstring("failed to stream blob")))
+			})
+		})
+	})
+
+	Describe("MissingBlobs", func() {
+		var layerInfos []imagepuller.LayerInfo
+
+		BeforeEach(func() {
+			layerInfos = []imagepuller.LayerInfo{{BlobID: "sha256:layer-digest"}}
+			fakeSource.MissingBlobsReturns([]string{"sha256:layer-digest"}, nil)
+		})
+
+		It("asks the source which blobs are missing", func() {
+			missingBlobs, err := fetcher.MissingBlobs(context.Background(), logger, layerInfos)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(missingBlobs).To(ConsistOf("sha256:layer-digest"))
+
+			Expect(fakeSource.MissingBlobsCallCount()).To(Equal(1))
+			_, _, checkedLayerInfos := fakeSource.MissingBlobsArgsForCall(0)
+			Expect(checkedLayerInfos).To(Equal(layerInfos))
+		})
+
+		Context("when the source fails", func() {
+			BeforeEach(func() {
+				fakeSource.MissingBlobsReturns(nil, errors.New("failed to check blobs"))
+			})
+
+			It("returns an error", func() {
+				_, err := fetcher.MissingBlobs(context.Background(), logger, layerInfos)
+				Expect(err).To(MatchError(ContainSubstring("failed to check blobs")))
+			})
+		})
+	})
+
 	Describe("Close", func() {
 		It("closes the source", func() {
 			Expect(fetcher.Close()).To(Succeed())
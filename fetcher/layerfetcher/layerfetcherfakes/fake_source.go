@@ -2,6 +2,8 @@
 package layerfetcherfakes
 
 import (
+	"context"
+	"io"
 	"sync"
 
 	"code.cloudfoundry.org/groot/fetcher/layerfetcher"
@@ -11,11 +13,12 @@ import (
 )
 
 type FakeSource struct {
-	BlobStub        func(lager.Logger, imagepuller.LayerInfo) (string, int64, error)
+	BlobStub        func(context.Context, lager.Logger, imagepuller.LayerInfo) (string, int64, error)
 	blobMutex       sync.RWMutex
 	blobArgsForCall []struct {
-		arg1 lager.Logger
-		arg2 imagepuller.LayerInfo
+		arg1 context.Context
+		arg2 lager.Logger
+		arg3 imagepuller.LayerInfo
 	}
 	blobReturns struct {
 		result1 string
@@ -37,10 +40,11 @@ type FakeSource struct {
 	closeReturnsOnCall map[int]struct {
 		result1 error
 	}
-	ManifestStub        func(lager.Logger) (types.Image, error)
+	ManifestStub        func(context.Context, lager.Logger) (types.Image, error)
 	manifestMutex       sync.RWMutex
 	manifestArgsForCall []struct {
-		arg1 lager.Logger
+		arg1 context.Context
+		arg2 lager.Logger
 	}
 	manifestReturns struct {
 		result1 types.Image
@@ -50,23 +54,56 @@ type FakeSource struct {
 		result1 types.Image
 		result2 error
 	}
+	MissingBlobsStub        func(context.Context, lager.Logger, []imagepuller.LayerInfo) ([]string, error)
+	missingBlobsMutex       sync.RWMutex
+	missingBlobsArgsForCall []struct {
+		arg1 context.Context
+		arg2 lager.Logger
+		arg3 []imagepuller.LayerInfo
+	}
+	missingBlobsReturns struct {
+		result1 []string
+		result2 error
+	}
+	missingBlobsReturnsOnCall map[int]struct {
+		result1 []string
+		result2 error
+	}
+	StreamBlobStub        func(context.Context, lager.Logger, imagepuller.LayerInfo) (io.ReadCloser, int64, error)
+	streamBlobMutex       sync.RWMutex
+	streamBlobArgsForCall []struct {
+		arg1 context.Context
+		arg2 lager.Logger
+		arg3 imagepuller.LayerInfo
+	}
+	streamBlobReturns struct {
+		result1 io.ReadCloser
+		result2 int64
+		result3 error
+	}
+	streamBlobReturnsOnCall map[int]struct {
+		result1 io.ReadCloser
+		result2 int64
+		result3 error
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
 
-func (fake *FakeSource) Blob(arg1 lager.Logger, arg2 imagepuller.LayerInfo) (string, int64, error) {
+func (fake *FakeSource) Blob(arg1 context.Context, arg2 lager.Logger, arg3 imagepuller.LayerInfo) (string, int64, error) {
 	fake.blobMutex.Lock()
 	ret, specificReturn := fake.blobReturnsOnCall[len(fake.blobArgsForCall)]
 	fake.blobArgsForCall = append(fake.blobArgsForCall, struct {
-		arg1 lager.Logger
-		arg2 imagepuller.LayerInfo
-	}{arg1, arg2})
+		arg1 context.Context
+		arg2 lager.Logger
+		arg3 imagepuller.LayerInfo
+	}{arg1, arg2, arg3})
 	stub := fake.BlobStub
 	fakeReturns := fake.blobReturns
-	fake.recordInvocation("Blob", []interface{}{arg1, arg2})
+	fake.recordInvocation("Blob", []interface{}{arg1, arg2, arg3})
 	fake.blobMutex.Unlock()
 	if stub != nil {
-		return stub(arg1, arg2)
+		return stub(arg1, arg2, arg3)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2, ret.result3
@@ -80,17 +117,17 @@ func (fake *FakeSource) BlobCallCount() int {
 	return len(fake.blobArgsForCall)
 }
 
-func (fake *FakeSource) BlobCalls(stub func(lager.Logger, imagepuller.LayerInfo) (string, int64, error)) {
+func (fake *FakeSource) BlobCalls(stub func(context.Context, lager.Logger, imagepuller.LayerInfo) (string, int64, error)) {
 	fake.blobMutex.Lock()
 	defer fake.blobMutex.Unlock()
 	fake.BlobStub = stub
 }
 
-func (fake *FakeSource) BlobArgsForCall(i int) (lager.Logger, imagepuller.LayerInfo) {
+func (fake *FakeSource) BlobArgsForCall(i int) (context.Context, lager.Logger, imagepuller.LayerInfo) {
 	fake.blobMutex.RLock()
 	defer fake.blobMutex.RUnlock()
 	argsForCall := fake.blobArgsForCall[i]
-	return argsForCall.arg1, argsForCall.arg2
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
 }
 
 func (fake *FakeSource) BlobReturns(result1 string, result2 int64, result3 error) {
@@ -175,18 +212,19 @@ func (fake *FakeSource) CloseReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
-func (fake *FakeSource) Manifest(arg1 lager.Logger) (types.Image, error) {
+func (fake *FakeSource) Manifest(arg1 context.Context, arg2 lager.Logger) (types.Image, error) {
 	fake.manifestMutex.Lock()
 	ret, specificReturn := fake.manifestReturnsOnCall[len(fake.manifestArgsForCall)]
 	fake.manifestArgsForCall = append(fake.manifestArgsForCall, struct {
-		arg1 lager.Logger
-	}{arg1})
+		arg1 context.Context
+		arg2 lager.Logger
+	}{arg1, arg2})
 	stub := fake.ManifestStub
 	fakeReturns := fake.manifestReturns
-	fake.recordInvocation("Manifest", []interface{}{arg1})
+	fake.recordInvocation("Manifest", []interface{}{arg1, arg2})
 	fake.manifestMutex.Unlock()
 	if stub != nil {
-		return stub(arg1)
+		return stub(arg1, arg2)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
@@ -200,17 +238,17 @@ func (fake *FakeSource) ManifestCallCount() int {
 	return len(fake.manifestArgsForCall)
 }
 
-func (fake *FakeSource) ManifestCalls(stub func(lager.Logger) (types.Image, error)) {
+func (fake *FakeSource) ManifestCalls(stub func(context.Context, lager.Logger) (types.Image, error)) {
 	fake.manifestMutex.Lock()
 	defer fake.manifestMutex.Unlock()
 	fake.ManifestStub = stub
 }
 
-func (fake *FakeSource) ManifestArgsForCall(i int) lager.Logger {
+func (fake *FakeSource) ManifestArgsForCall(i int) (context.Context, lager.Logger) {
 	fake.manifestMutex.RLock()
 	defer fake.manifestMutex.RUnlock()
 	argsForCall := fake.manifestArgsForCall[i]
-	return argsForCall.arg1
+	return argsForCall.arg1, argsForCall.arg2
 }
 
 func (fake *FakeSource) ManifestReturns(result1 types.Image, result2 error) {
@@ -239,6 +277,146 @@ func (fake *FakeSource) ManifestReturnsOnCall(i int, result1 types.Image, result
 	}{result1, result2}
 }
 
+func (fake *FakeSource) MissingBlobs(arg1 context.Context, arg2 lager.Logger, arg3 []imagepuller.LayerInfo) ([]string, error) {
+	var arg3Copy []imagepuller.LayerInfo
+	if arg3 != nil {
+		arg3Copy = make([]imagepuller.LayerInfo, len(arg3))
+		copy(arg3Copy, arg3)
+	}
+	fake.missingBlobsMutex.Lock()
+	ret, specificReturn := fake.missingBlobsReturnsOnCall[len(fake.missingBlobsArgsForCall)]
+	fake.missingBlobsArgsForCall = append(fake.missingBlobsArgsForCall, struct {
+		arg1 context.Context
+		arg2 lager.Logger
+		arg3 []imagepuller.LayerInfo
+	}{arg1, arg2, arg3Copy})
+	stub := fake.MissingBlobsStub
+	fakeReturns := fake.missingBlobsReturns
+	fake.recordInvocation("MissingBlobs", []interface{}{arg1, arg2, arg3Copy})
+	fake.missingBlobsMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeSource) MissingBlobsCallCount() int {
+	fake.missingBlobsMutex.RLock()
+	defer fake.missingBlobsMutex.RUnlock()
+	return len(fake.missingBlobsArgsForCall)
+}
+
+func (fake *FakeSource) MissingBlobsCalls(stub func(context.Context, lager.Logger, []imagepuller.LayerInfo) ([]string, error)) {
+	fake.missingBlobsMutex.Lock()
+	defer fake.missingBlobsMutex.Unlock()
+	fake.MissingBlobsStub = stub
+}
+
+func (fake *FakeSource) MissingBlobsArgsForCall(i int) (context.Context, lager.Logger, []imagepuller.LayerInfo) {
+	fake.missingBlobsMutex.RLock()
+	defer fake.missingBlobsMutex.RUnlock()
+	argsForCall := fake.missingBlobsArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeSource) MissingBlobsReturns(result1 []string, result2 error) {
+	fake.missingBlobsMutex.Lock()
+	defer fake.missingBlobsMutex.Unlock()
+	fake.MissingBlobsStub = nil
+	fake.missingBlobsReturns = struct {
+		result1 []string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeSource) MissingBlobsReturnsOnCall(i int, result1 []string, result2 error) {
+	fake.missingBlobsMutex.Lock()
+	defer fake.missingBlobsMutex.Unlock()
+	fake.MissingBlobsStub = nil
+	if fake.missingBlobsReturnsOnCall == nil {
+		fake.missingBlobsReturnsOnCall = make(map[int]struct {
+			result1 []string
+			result2 error
+		})
+	}
+	fake.missingBlobsReturnsOnCall[i] = struct {
+		result1 []string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeSource) StreamBlob(arg1 context.Context, arg2 lager.Logger, arg3 imagepuller.LayerInfo) (io.ReadCloser, int64, error) {
+	fake.streamBlobMutex.Lock()
+	ret, specificReturn := fake.streamBlobReturnsOnCall[len(fake.streamBlobArgsForCall)]
+	fake.streamBlobArgsForCall = append(fake.streamBlobArgsForCall, struct {
+		arg1 context.Context
+		arg2 lager.Logger
+		arg3 imagepuller.LayerInfo
+	}{arg1, arg2, arg3})
+	stub := fake.StreamBlobStub
+	fakeReturns := fake.streamBlobReturns
+	fake.recordInvocation("StreamBlob", []interface{}{arg1, arg2, arg3})
+	fake.streamBlobMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2, ret.result3
+	}
+	return fakeReturns.result1, fakeReturns.result2, fakeReturns.result3
+}
+
+func (fake *FakeSource) StreamBlobCallCount() int {
+	fake.streamBlobMutex.RLock()
+	defer fake.streamBlobMutex.RUnlock()
+	return len(fake.streamBlobArgsForCall)
+}
+
+func (fake *FakeSource) StreamBlobCalls(stub func(context.Context, lager.Logger, imagepuller.LayerInfo) (io.ReadCloser, int64, error)) {
+	fake.streamBlobMutex.Lock()
+	defer fake.streamBlobMutex.Unlock()
+	fake.StreamBlobStub = stub
+}
+
+func (fake *FakeSource) StreamBlobArgsForCall(i int) (context.Context, lager.Logger, imagepuller.LayerInfo) {
+	fake.streamBlobMutex.RLock()
+	defer fake.streamBlobMutex.RUnlock()
+	argsForCall := fake.streamBlobArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeSource) StreamBlobReturns(result1 io.ReadCloser, result2 int64, result3 error) {
+	fake.streamBlobMutex.Lock()
+	defer fake.streamBlobMutex.Unlock()
+	fake.StreamBlobStub = nil
+	fake.streamBlobReturns = struct {
+		result1 io.ReadCloser
+		result2 int64
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *FakeSource) StreamBlobReturnsOnCall(i int, result1 io.ReadCloser, result2 int64, result3 error) {
+	fake.streamBlobMutex.Lock()
+	defer fake.streamBlobMutex.Unlock()
+	fake.StreamBlobStub = nil
+	if fake.streamBlobReturnsOnCall == nil {
+		fake.streamBlobReturnsOnCall = make(map[int]struct {
+			result1 io.ReadCloser
+			result2 int64
+			result3 error
+		})
+	}
+	fake.streamBlobReturnsOnCall[i] = struct {
+		result1 io.ReadCloser
+		result2 int64
+		result3 error
+	}{result1, result2, result3}
+}
+
 func (fake *FakeSource) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -248,6 +426,10 @@ func (fake *FakeSource) Invocations() map[string][][]interface{} {
 	defer fake.closeMutex.RUnlock()
 	fake.manifestMutex.RLock()
 	defer fake.manifestMutex.RUnlock()
+	fake.missingBlobsMutex.RLock()
+	defer fake.missingBlobsMutex.RUnlock()
+	fake.streamBlobMutex.RLock()
+	defer fake.streamBlobMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
@@ -3,6 +3,8 @@ package layerfetcher
 import (
 	"errors"
 	"io"
+
+	"code.cloudfoundry.org/groot/imagepuller"
 )
 
 type QuotaedReader struct {
@@ -16,7 +18,7 @@ func NewQuotaedReader(delegateReader io.ReadCloser, quotaLeft int64, errorMsg st
 		DelegateReader: delegateReader,
 		QuotaLeft:      quotaLeft,
 		QuotaExceededErrorHandler: func() error {
-			return errors.New(errorMsg)
+			return imagepuller.Classify(imagepuller.ErrQuotaExceeded, errors.New(errorMsg))
 		},
 	}
 }
@@ -0,0 +1,175 @@
+package source // import "code.cloudfoundry.org/groot/fetcher/layerfetcher/source"
+
+import (
+	"context"
+	"io"
+
+	"code.cloudfoundry.org/groot/fetcher/blobcache"
+	"code.cloudfoundry.org/groot/imagepuller"
+	"code.cloudfoundry.org/lager/v3"
+	manifestpkg "github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/types"
+	digestpkg "github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+)
+
+// cachedImageSource serves manifests and blobs from the blob cache, and adds
+// the ones it fetches from upstream to it. Without an upstream image source,
+// as in offline mode, only cached content is served.
+//
+// Manifests requested without a digest are looked up through the image URL,
+// which is recorded in the cache every time the manifest is fetched upstream.
+type cachedImageSource struct {
+	ref      types.ImageReference
+	upstream types.ImageSource
+	cache    *blobcache.BlobCache
+	refName  string
+	logger   lager.Logger
+}
+
+func (s *cachedImageSource) Reference() types.ImageReference {
+	return s.ref
+}
+
+func (s *cachedImageSource) Close() error {
+	if s.upstream == nil {
+		return nil
+	}
+	return s.upstream.Close()
+}
+
+func (s *cachedImageSource) GetManifest(ctx context.Context, instanceDigest *digestpkg.Digest) ([]byte, string, error) {
+	if s.upstream == nil {
+		return s.cachedManifest(instanceDigest)
+	}
+
+	if instanceDigest != nil {
+		if manifest, mimeType, err := s.cachedManifest(instanceDigest); err == nil {
+			return manifest, mimeType, nil
+		}
+	}
+
+	manifest, mimeType, err := s.upstream.GetManifest(ctx, instanceDigest)
+	if err != nil {
+		return nil, "", err
+	}
+
+	s.cacheManifest(manifest, instanceDigest)
+	return manifest, mimeType, nil
+}
+
+func (s *cachedImageSource) cachedManifest(instanceDigest *digestpkg.Digest) ([]byte, string, error) {
+	var digest digestpkg.Digest
+	if instanceDigest != nil {
+		digest = *instanceDigest
+	} else {
+		var ok bool
+		if digest, ok = s.cache.Reference(s.refName); !ok {
+			return nil, "", imagepuller.Classify(imagepuller.ErrImageNotFound, errors.Errorf("no manifest for %s in the blob cache", s.refName))
+		}
+	}
+
+	blob, _, ok := s.cache.Get(s.logger, digest)
+	if !ok {
+		return nil, "", imagepuller.Classify(imagepuller.ErrImageNotFound, errors.Errorf("manifest %s is not in the blob cache", digest))
+	}
+	defer blob.Close()
+
+	manifest, err := io.ReadAll(blob)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "reading cached manifest")
+	}
+
+	return manifest, manifestpkg.GuessMIMEType(manifest), nil
+}
+
+func (s *cachedImageSource) cacheManifest(manifest []byte, instanceDigest *digestpkg.Digest) {
+	digest := digestpkg.FromBytes(manifest)
+	if err := s.cache.Put(s.logger, digest, manifest); err != nil {
+		s.logger.Error("caching-manifest-failed", err, lager.Data{"digest": digest})
+		return
+	}
+
+	if instanceDigest == nil {
+		if err := s.cache.SetReference(s.refName, digest); err != nil {
+			s.logger.Error("caching-reference-failed", err, lager.Data{"reference": s.refName})
+		}
+	}
+}
+
+func (s *cachedImageSource) GetBlob(ctx context.Context, blobInfo types.BlobInfo, blobInfoCache types.BlobInfoCache) (io.ReadCloser, int64, error) {
+	if blob, size, ok := s.cache.Get(s.logger, blobInfo.Digest); ok {
+		return blob, size, nil
+	}
+
+	if s.upstream == nil {
+		return nil, 0, imagepuller.Classify(imagepuller.ErrImageNotFound, errors.Errorf("blob %s is not in the blob cache", blobInfo.Digest))
+	}
+
+	blob, size, err := s.upstream.GetBlob(ctx, blobInfo, blobInfoCache)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	writer, err := s.cache.NewWriter(blobInfo.Digest)
+	if err != nil {
+		s.logger.Error("creating-blob-cache-writer-failed", err)
+		return blob, size, nil
+	}
+
+	return &cachingReader{blob: blob, writer: writer, logger: s.logger}, size, nil
+}
+
+func (s *cachedImageSource) HasThreadSafeGetBlob() bool {
+	return s.upstream == nil || s.upstream.HasThreadSafeGetBlob()
+}
+
+func (s *cachedImageSource) GetSignatures(ctx context.Context, instanceDigest *digestpkg.Digest) ([][]byte, error) {
+	if s.upstream == nil {
+		return nil, nil
+	}
+	return s.upstream.GetSignatures(ctx, instanceDigest)
+}
+
+func (s *cachedImageSource) LayerInfosForCopy(ctx context.Context, instanceDigest *digestpkg.Digest) ([]types.BlobInfo, error) {
+	if s.upstream == nil {
+		return nil, nil
+	}
+	return s.upstream.LayerInfosForCopy(ctx, instanceDigest)
+}
+
+// cachingReader writes the blob to the cache while it is being read. The blob
+// is only added to the cache once it has been read completely and matches its
+// digest.
+type cachingReader struct {
+	blob   io.ReadCloser
+	writer *blobcache.Writer
+	// done is set once the blob is committed or can no longer be cached
+	done   bool
+	logger lager.Logger
+}
+
+func (r *cachingReader) Read(p []byte) (int, error) {
+	n, err := r.blob.Read(p)
+
+	if n > 0 && !r.done {
+		if _, writeErr := r.writer.Write(p[:n]); writeErr != nil {
+			r.logger.Error("writing-blob-to-cache-failed", writeErr)
+			r.done = true
+		}
+	}
+
+	if err == io.EOF && !r.done {
+		if commitErr := r.writer.Commit(r.logger); commitErr != nil {
+			r.logger.Error("caching-blob-failed", commitErr)
+		}
+		r.done = true
+	}
+
+	return n, err
+}
+
+func (r *cachingReader) Close() error {
+	_ = r.writer.Close()
+	return r.blob.Close()
+}
@@ -0,0 +1,44 @@
+package source // import "code.cloudfoundry.org/groot/fetcher/layerfetcher/source"
+
+import (
+	"io/fs"
+	"net/http"
+
+	"code.cloudfoundry.org/groot/imagepuller"
+	"github.com/containers/image/v5/oci/layout"
+	"github.com/pkg/errors"
+)
+
+// classifyError classifies the error fetching an image failed with, once it
+// is no longer retried. Errors that are already classified, and errors that
+// are not about the image or registry, are returned as they are.
+func (s *LayerSource) classifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var classifiedErr *imagepuller.ClassifiedError
+	if errors.As(err, &classifiedErr) {
+		return err
+	}
+
+	if statusCode, ok := httpStatusCode(err); ok {
+		switch statusCode {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return imagepuller.Classify(imagepuller.ErrUnauthorized, err)
+		case http.StatusNotFound:
+			return imagepuller.Classify(imagepuller.ErrImageNotFound, err)
+		}
+	}
+
+	var notFoundErr layout.ImageNotFoundError
+	if errors.As(err, &notFoundErr) || errors.Is(err, fs.ErrNotExist) {
+		return imagepuller.Classify(imagepuller.ErrImageNotFound, err)
+	}
+
+	if s.retryPolicy.IsRetryable(err) {
+		return imagepuller.Classify(imagepuller.ErrRegistryUnavailable, err)
+	}
+
+	return err
+}
@@ -13,9 +13,13 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
+	"time"
 
+	"code.cloudfoundry.org/groot/fetcher/blobcache"
 	"code.cloudfoundry.org/groot/fetcher/layerfetcher"
 	"code.cloudfoundry.org/groot/imagepuller"
+	"code.cloudfoundry.org/groot/tracing"
 	"code.cloudfoundry.org/lager/v3"
 	_ "github.com/containers/image/v5/docker"
 	"github.com/containers/image/v5/image"
@@ -24,13 +28,15 @@ import (
 	"github.com/containers/image/v5/pkg/blobinfocache/none"
 	"github.com/containers/image/v5/transports"
 	"github.com/containers/image/v5/types"
+	"github.com/klauspost/compress/zstd"
 	digestpkg "github.com/opencontainers/go-digest"
+	imgspec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-const MAX_DOCKER_RETRIES = 3
-
 type LayerSource struct {
 	skipOCILayerValidation bool
 	systemContext          types.SystemContext
@@ -39,82 +45,154 @@ type LayerSource struct {
 	imageSource              types.ImageSource
 	remainingImageQuota      int64
 	skipImageQuotaValidation bool
+	retryPolicy              RetryPolicy
+	mirrors                  []Mirror
+	// blobCache is optional; manifests and blobs are fetched from the cache
+	// when it has them and added to it when they are fetched from the network
+	blobCache *blobcache.BlobCache
+	// offline restricts registry images to the contents of the blob cache
+	offline bool
+	// signatureVerifier is optional; when set, images it rejects are not used
+	signatureVerifier SignatureVerifier
+
+	progress imagepuller.ProgressReporter
+	// endpoint is the URL of the image that imageSource was created for, either
+	// one of the mirrors or imageURL
+	endpoint string
+	// usingMirror is set when imageSource was created for one of the mirrors
+	usingMirror bool
+	// originSource is created on demand for blobs that the mirror in use fails
+	// to serve. DO NOT use the field directly, use getOriginSource instead
+	originSource types.ImageSource
+	// mutex guards imageSource, endpoint, usingMirror, originSource and remainingImageQuota, as blobs can be fetched concurrently
+	mutex sync.Mutex
+}
+
+// SignatureVerifier decides whether an image may be used, for example by
+// checking its signatures, before any of its layers are fetched. The errors
+// it returns are classified as imagepuller.ErrPolicyRejected.
+type SignatureVerifier interface {
+	Verify(ctx context.Context, image types.UnparsedImage) error
+	Close() error
+}
+
+// Mirror is a location that serves the same image as the image URL, such as a
+// pull-through cache of its registry. Mirrors are tried in order before the
+// image URL is.
+type Mirror struct {
+	URL           *url.URL
+	SystemContext types.SystemContext
 }
 
-func NewLayerSource(systemContext types.SystemContext, skipOCILayerValidation, skipImageQuotaValidation bool, diskLimit int64, imageURL *url.URL) LayerSource {
+// Options configures a LayerSource. Only ImageURL is required.
+type Options struct {
+	ImageURL                 *url.URL
+	SystemContext            types.SystemContext
+	DiskLimit                int64
+	SkipImageQuotaValidation bool
+	SkipOCILayerValidation   bool
+	// RetryPolicy defaults to DefaultRetryPolicy when it allows no attempts
+	RetryPolicy RetryPolicy
+	Mirrors     []Mirror
+	// BlobCache and SignatureVerifier are optional
+	BlobCache         *blobcache.BlobCache
+	Offline           bool
+	SignatureVerifier SignatureVerifier
+	// Progress defaults to imagepuller.DiscardProgress
+	Progress imagepuller.ProgressReporter
+}
+
+func NewLayerSource(options Options) LayerSource {
+	if options.Progress == nil {
+		options.Progress = imagepuller.DiscardProgress
+	}
+	if options.RetryPolicy.Attempts <= 0 {
+		options.RetryPolicy = DefaultRetryPolicy()
+	}
+
 	return LayerSource{
-		systemContext:            systemContext,
-		skipOCILayerValidation:   skipOCILayerValidation,
-		imageURL:                 imageURL,
-		remainingImageQuota:      diskLimit,
-		skipImageQuotaValidation: skipImageQuotaValidation,
+		systemContext:            options.SystemContext,
+		skipOCILayerValidation:   options.SkipOCILayerValidation,
+		imageURL:                 options.ImageURL,
+		remainingImageQuota:      options.DiskLimit,
+		skipImageQuotaValidation: options.SkipImageQuotaValidation,
+		retryPolicy:              options.RetryPolicy,
+		mirrors:                  options.Mirrors,
+		blobCache:                options.BlobCache,
+		offline:                  options.Offline,
+		signatureVerifier:        options.SignatureVerifier,
+		progress:                 options.Progress,
 	}
 }
 
-func (s *LayerSource) Manifest(logger lager.Logger) (types.Image, error) {
+func (s *LayerSource) Manifest(ctx context.Context, logger lager.Logger) (types.Image, error) {
 	logger = logger.Session("fetching-image-manifest", lager.Data{"imageURL": s.imageURL})
 	logger.Info("starting")
 	defer logger.Info("ending")
 
-	img, err := s.getImageWithRetries(logger)
+	ctx, span := tracing.Start(ctx, "source.Manifest", attribute.String("groot.image_url", s.imageURL.Redacted()))
+	img, err := s.manifest(ctx, logger)
+	tracing.End(span, err)
+	return img, err
+}
+
+func (s *LayerSource) manifest(ctx context.Context, logger lager.Logger) (types.Image, error) {
+	started := time.Now()
+
+	img, err := s.getImageWithRetries(ctx, logger)
 	if err != nil {
 		logger.Error("fetching-image-reference-failed", err)
-		return nil, errors.Wrap(err, "fetching image reference")
+		return nil, s.classifyError(errors.Wrap(err, "fetching image reference"))
 	}
 
-	img, err = s.convertImage(logger, img)
+	if err := s.verifySignatures(ctx, logger); err != nil {
+		logger.Error("verifying-signatures-failed", err)
+		return nil, err
+	}
+
+	img, err = s.convertImage(ctx, logger, img)
 	if err != nil {
 		logger.Error("converting-image-failed", err)
 		return nil, err
 	}
 
-	for i := 0; i < MAX_DOCKER_RETRIES; i++ {
-		logger.Debug("attempt-get-config", lager.Data{"attempt": i + 1})
-		_, e := img.ConfigBlob(context.TODO())
-		if e == nil {
-			return img, nil
+	err = s.retry(ctx, logger, "", func(attempt int) error {
+		logger.Debug("attempt-get-config", lager.Data{"attempt": attempt})
+		_, err := img.ConfigBlob(ctx)
+		if err != nil {
+			logger.Error("fetching-image-config-failed", err, lager.Data{"attempt": attempt})
 		}
-
-		logger.Error("fetching-image-config-failed", e, lager.Data{"attempt": i + 1})
-		err = e
+		return err
+	})
+	if err != nil {
+		return nil, s.classifyError(errors.Wrap(err, "fetching image configuration"))
 	}
 
-	return nil, errors.Wrap(err, "fetching image configuration")
+	s.progress.Report(imagepuller.ProgressEvent{
+		Event:    imagepuller.ProgressManifestFetched,
+		Duration: time.Since(started).Seconds(),
+	})
+	return img, nil
 }
 
-func (s *LayerSource) Blob(logger lager.Logger, layerInfo imagepuller.LayerInfo) (string, int64, error) {
-	logrus.SetOutput(os.Stderr)
-	logger = logger.Session("streaming-blob", lager.Data{
-		"imageURL":                 s.imageURL,
-		"digest":                   layerInfo.BlobID,
-		"remainingImageQuota":      s.remainingImageQuota,
-		"skipImageQuotaValidation": s.skipImageQuotaValidation,
-	})
+func (s *LayerSource) Blob(ctx context.Context, logger lager.Logger, layerInfo imagepuller.LayerInfo) (string, int64, error) {
+	logger, remainingImageQuota := s.blobSession(logger, layerInfo)
 	logger.Info("starting")
 	defer logger.Info("ending")
 
-	imgSrc, err := s.getImageSource(logger)
-	if err != nil {
-		return "", 0, err
-	}
-
-	blobInfo := types.BlobInfo{
-		Digest: digestpkg.Digest(layerInfo.BlobID),
-		URLs:   layerInfo.URLs,
-	}
+	ctx, span := startBlobSpan(ctx, "source.Blob", layerInfo)
+	blobPath, size, err := s.blob(ctx, logger, layerInfo, remainingImageQuota)
+	tracing.End(span, err)
+	return blobPath, size, err
+}
 
-	blob, size, err := s.getBlobWithRetries(logger, imgSrc, blobInfo)
+func (s *LayerSource) blob(ctx context.Context, logger lager.Logger, layerInfo imagepuller.LayerInfo, remainingImageQuota int64) (string, int64, error) {
+	blob, size, err := s.openVerifiedBlob(ctx, logger, layerInfo, remainingImageQuota)
 	if err != nil {
 		return "", 0, err
 	}
 	defer blob.Close()
 
-	logger.Debug("got-blob-stream", lager.Data{"digest": layerInfo.BlobID, "size": size, "mediaType": layerInfo.MediaType})
-
-	if err = s.validateLayerSize(layerInfo, size); err != nil {
-		return "", 0, err
-	}
-
 	// ":" is an invalid character for Windows paths
 	blobTempFile, err := os.CreateTemp("", "blob-"+strings.Replace(layerInfo.BlobID, ":", "-", -1))
 	if err != nil {
@@ -135,84 +213,340 @@ func (s *LayerSource) Blob(logger lager.Logger, layerInfo imagepuller.LayerInfo)
 		}
 	}()
 
-	blobIDHash := sha256.New()
-	digestReader := io.NopCloser(io.TeeReader(blob, blobIDHash))
-	if layerInfo.MediaType == "" || strings.Contains(layerInfo.MediaType, "gzip") {
-		logger.Debug("uncompressing-blob")
+	// #nosec - G110 - We're fine with unbounded file decompression here as we have container filesystem quotas that will prevent this from eating up the entire diego cell disk space
+	_, err = io.Copy(blobTempFile, blob)
+	if err != nil {
+		logger.Error("writing-blob-to-file", err)
+		return "", 0, errors.Wrap(err, "writing blob to tempfile")
+	}
 
-		digestReader, err = gzip.NewReader(digestReader)
-		if err != nil {
-			return "", 0, errors.Wrapf(err, "expected blob to be of type %s", layerInfo.MediaType)
-		}
-		defer digestReader.Close()
+	return blobTempFile.Name(), size, nil
+}
+
+// StreamBlob returns the uncompressed contents of a blob without storing them
+// on disk. The blob and diffID digests, as well as the image quota, can only be
+// checked once the whole stream has been read, so instead of io.EOF the stream
+// returns an error when any of them do not match.
+func (s *LayerSource) StreamBlob(ctx context.Context, logger lager.Logger, layerInfo imagepuller.LayerInfo) (io.ReadCloser, int64, error) {
+	logger, remainingImageQuota := s.blobSession(logger, layerInfo)
+	logger.Info("starting")
+	defer logger.Info("ending")
+
+	// The span ends once the stream is open; reading it is part of the span
+	// of whoever reads it
+	ctx, span := startBlobSpan(ctx, "source.StreamBlob", layerInfo)
+	stream, size, err := s.openVerifiedBlob(ctx, logger, layerInfo, remainingImageQuota)
+	tracing.End(span, err)
+	return stream, size, err
+}
+
+func startBlobSpan(ctx context.Context, name string, layerInfo imagepuller.LayerInfo) (context.Context, trace.Span) {
+	return tracing.Start(ctx, name,
+		attribute.String("groot.blob_id", layerInfo.BlobID),
+		attribute.Int64("groot.blob_size", layerInfo.Size),
+	)
+}
 
+func (s *LayerSource) blobSession(logger lager.Logger, layerInfo imagepuller.LayerInfo) (lager.Logger, int64) {
+	logrus.SetOutput(os.Stderr)
+	remainingImageQuota := s.getRemainingImageQuota()
+	logger = logger.Session("streaming-blob", lager.Data{
+		"imageURL":                 s.imageURL,
+		"digest":                   layerInfo.BlobID,
+		"remainingImageQuota":      remainingImageQuota,
+		"skipImageQuotaValidation": s.skipImageQuotaValidation,
+	})
+
+	return logger, remainingImageQuota
+}
+
+func (s *LayerSource) openVerifiedBlob(ctx context.Context, logger lager.Logger, layerInfo imagepuller.LayerInfo, remainingImageQuota int64) (io.ReadCloser, int64, error) {
+	started := time.Now()
+	blobInfo := types.BlobInfo{
+		Digest: digestpkg.Digest(layerInfo.BlobID),
+		URLs:   layerInfo.URLs,
 	}
 
+	blob, size, cached, err := s.openBlob(ctx, logger, blobInfo)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	logger.Debug("got-blob-stream", lager.Data{"digest": layerInfo.BlobID, "size": size, "mediaType": layerInfo.MediaType})
+
+	if err = s.validateLayerSize(layerInfo, size); err != nil {
+		blob.Close()
+		return nil, 0, err
+	}
+
+	stream := &blobStream{closers: []io.Closer{blob}}
+
+	s.progress.Report(imagepuller.ProgressEvent{
+		Event:  imagepuller.ProgressDownloadStarted,
+		BlobID: layerInfo.BlobID,
+		Size:   size,
+		Cached: cached,
+	})
+	transfer := &transferProgress{reporter: s.progress, blobID: layerInfo.BlobID, size: size}
+
+	blobIDHash := sha256.New()
+	digestReader, err := decompress(logger, layerInfo.MediaType, io.TeeReader(contextReader{ctx: ctx, reader: blob}, io.MultiWriter(blobIDHash, transfer)))
+	if err != nil {
+		stream.Close()
+		return nil, 0, errors.Wrapf(err, "expected blob to be of type %s", layerInfo.MediaType)
+	}
+	stream.closers = append([]io.Closer{digestReader}, stream.closers...)
+
 	if s.shouldEnforceImageQuotaValidation() {
-		digestReader = layerfetcher.NewQuotaedReader(digestReader, s.remainingImageQuota, "uncompressed layer size exceeds quota")
+		digestReader = layerfetcher.NewQuotaedReader(digestReader, remainingImageQuota, "uncompressed layer size exceeds quota")
 	}
 
 	diffIDHash := sha256.New()
-	digestReader = io.NopCloser(io.TeeReader(digestReader, diffIDHash))
+	var uncompressedSize byteCounter
+	stream.Reader = io.TeeReader(digestReader, io.MultiWriter(diffIDHash, &uncompressedSize))
 
-	// #nosec - G110 - We're fine with unbounded file decompression here as we have container filesystem quotas that will prevent this from eating up the entire diego cell disk space
-	uncompressedSize, err := io.Copy(blobTempFile, digestReader)
+	return layerfetcher.NewVerifyingReader(stream, func() error {
+		blobIDHex := strings.Split(layerInfo.BlobID, ":")[1]
+		if err := s.checkCheckSum(logger, blobIDHash, blobIDHex, s.imageURL.Scheme); err != nil {
+			return imagepuller.Classify(imagepuller.ErrDigestMismatch, errors.Wrap(err, "layerID digest mismatch"))
+		}
+
+		if err := s.checkCheckSum(logger, diffIDHash, layerInfo.DiffID, s.imageURL.Scheme); err != nil {
+			return imagepuller.Classify(imagepuller.ErrDigestMismatch, errors.Wrap(err, "diffID digest mismatch"))
+		}
+
+		if err := s.consumeImageQuota(int64(uncompressedSize)); err != nil {
+			return err
+		}
+
+		s.progress.Report(imagepuller.ProgressEvent{
+			Event:    imagepuller.ProgressLayerVerified,
+			BlobID:   layerInfo.BlobID,
+			Size:     size,
+			Bytes:    transfer.transferred,
+			Cached:   cached,
+			Duration: time.Since(started).Seconds(),
+		})
+		return nil
+	}), size, nil
+}
+
+// openBlob returns the compressed blob, and whether it was read from the blob
+// cache. Blobs in the blob cache are read from there without creating an
+// image source, which would fetch the manifest again.
+func (s *LayerSource) openBlob(ctx context.Context, logger lager.Logger, blobInfo types.BlobInfo) (io.ReadCloser, int64, bool, error) {
+	if s.blobCache != nil {
+		if blob, size, ok := s.blobCache.Get(logger, blobInfo.Digest); ok {
+			logger.Info("got-blob", lager.Data{"endpoint": "blob-cache"})
+			return blob, size, true, nil
+		}
+	}
+
+	imgSrc, err := s.getImageSource(ctx, logger)
 	if err != nil {
-		logger.Error("writing-blob-to-file", err)
-		return "", 0, errors.Wrap(err, "writing blob to tempfile")
+		return nil, 0, false, s.classifyError(err)
 	}
 
-	blobIDHex := strings.Split(layerInfo.BlobID, ":")[1]
-	if err = s.checkCheckSum(logger, blobIDHash, blobIDHex, s.imageURL.Scheme); err != nil {
-		return "", 0, errors.Wrap(err, "layerID digest mismatch")
+	endpoint, usingMirror := s.getEndpoint()
+	blob, size, err := s.getBlobWithRetries(ctx, logger, imgSrc, blobInfo)
+	if err != nil && usingMirror && ctx.Err() == nil {
+		logger.Error("mirror-blob-failed", err, lager.Data{"endpoint": endpoint})
+		blob, size, err = s.getOriginBlob(ctx, logger, blobInfo)
+		endpoint = s.imageURL.String()
+	}
+	if err != nil {
+		return nil, 0, false, s.classifyError(err)
 	}
 
-	if err = s.checkCheckSum(logger, diffIDHash, layerInfo.DiffID, s.imageURL.Scheme); err != nil {
-		return "", 0, errors.Wrap(err, "diffID digest mismatch")
+	logger.Info("got-blob", lager.Data{"endpoint": endpoint})
+	return blob, size, false, nil
+}
+
+// getOriginBlob fetches a blob from the image URL instead of the mirror in
+// use, once the mirror has used up its retries for the blob
+func (s *LayerSource) getOriginBlob(ctx context.Context, logger lager.Logger, blobInfo types.BlobInfo) (io.ReadCloser, int64, error) {
+	logger.Info("falling-back-to-origin", lager.Data{"endpoint": s.imageURL.String()})
+
+	imgSrc, err := s.getOriginSource(ctx, logger)
+	if err != nil {
+		return nil, 0, err
 	}
 
-	s.remainingImageQuota -= uncompressedSize
+	return s.getBlobWithRetries(ctx, logger, imgSrc, blobInfo)
+}
 
-	return blobTempFile.Name(), size, nil
+func (s *LayerSource) blobInfoCache() types.BlobInfoCache {
+	if s.blobCache == nil {
+		return none.NoCache
+	}
+	return s.blobCache.BlobInfoCache()
+}
+
+// decompress returns the uncompressed contents of a layer blob, based on the
+// media type of the layer. Layers of schema 1 images have no media type and are
+// always gzipped. zstd:chunked layers are regular zstd streams with additional
+// skippable frames, so they need no special treatment.
+func decompress(logger lager.Logger, mediaType string, blob io.Reader) (io.ReadCloser, error) {
+	switch mediaType {
+	case "",
+		manifestpkg.DockerV2Schema2LayerMediaType,
+		manifestpkg.DockerV2Schema2ForeignLayerMediaTypeGzip,
+		imgspec.MediaTypeImageLayerGzip,
+		//lint:ignore SA1019 images with nondistributable layers are still pulled
+		imgspec.MediaTypeImageLayerNonDistributableGzip:
+		logger.Debug("uncompressing-blob", lager.Data{"compression": "gzip"})
+		gzipReader, err := gzip.NewReader(blob)
+		if err != nil {
+			return nil, err
+		}
+		return gzipReader, nil
+
+	case imgspec.MediaTypeImageLayerZstd,
+		//lint:ignore SA1019 images with nondistributable layers are still pulled
+		imgspec.MediaTypeImageLayerNonDistributableZstd:
+		logger.Debug("uncompressing-blob", lager.Data{"compression": "zstd"})
+		zstdReader, err := zstd.NewReader(blob)
+		if err != nil {
+			return nil, err
+		}
+		return zstdReader.IOReadCloser(), nil
+
+	case manifestpkg.DockerV2SchemaLayerMediaTypeUncompressed,
+		manifestpkg.DockerV2Schema2ForeignLayerMediaType,
+		imgspec.MediaTypeImageLayer,
+		//lint:ignore SA1019 images with nondistributable layers are still pulled
+		imgspec.MediaTypeImageLayerNonDistributable:
+		return io.NopCloser(blob), nil
+	}
+
+	return nil, errors.Errorf("unsupported layer media type %s", mediaType)
 }
 
 func (s *LayerSource) shouldEnforceImageQuotaValidation() bool {
 	return !s.skipImageQuotaValidation
 }
 
+func (s *LayerSource) getRemainingImageQuota() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.remainingImageQuota
+}
+
+// consumeImageQuota re-checks the quota once the layer size is known, as other
+// layers may have used up the quota while this one was being downloaded
+func (s *LayerSource) consumeImageQuota(uncompressedSize int64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.shouldEnforceImageQuotaValidation() && uncompressedSize > s.remainingImageQuota {
+		return imagepuller.Classify(imagepuller.ErrQuotaExceeded, errors.New("uncompressed layer size exceeds quota"))
+	}
+
+	s.remainingImageQuota -= uncompressedSize
+	return nil
+}
+
 func (s *LayerSource) validateLayerSize(layerInfo imagepuller.LayerInfo, size int64) error {
 	if s.skipOCILayerValidation || isV1Image(layerInfo) || layerInfo.Size == size {
 		return nil
 	}
 
-	return errors.New("layer size is different from the value in the manifest")
+	return imagepuller.Classify(imagepuller.ErrDigestMismatch, errors.New("layer size is different from the value in the manifest"))
 }
 
 func isV1Image(layerInfo imagepuller.LayerInfo) bool {
 	return layerInfo.Size == -1
 }
 
+// verifySignatures checks the image the URL refers to, rather than the
+// instance chosen for the platform, as that is what images are signed as when
+// they are pushed. The image is checked as the image URL even when it is
+// fetched from a mirror, as policies are written for the origin of images.
+func (s *LayerSource) verifySignatures(ctx context.Context, logger lager.Logger) error {
+	if s.signatureVerifier == nil {
+		return nil
+	}
+
+	imageSource, err := s.getImageSource(ctx, logger)
+	if err != nil {
+		return s.classifyError(errors.Wrap(err, "fetching image reference"))
+	}
+
+	ref, err := reference(logger, s.imageURL)
+	if err != nil {
+		return err
+	}
+
+	err = s.signatureVerifier.Verify(ctx, image.UnparsedInstance(originImageSource{ImageSource: imageSource, ref: ref}, nil))
+	return imagepuller.Classify(imagepuller.ErrPolicyRejected, err)
+}
+
+// originImageSource is an image source that reports the reference of the image
+// URL, whichever endpoint it reads the image from
+type originImageSource struct {
+	types.ImageSource
+	ref types.ImageReference
+}
+
+func (s originImageSource) Reference() types.ImageReference {
+	return s.ref
+}
+
 func (s *LayerSource) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.signatureVerifier != nil {
+		defer s.signatureVerifier.Close()
+	}
+
+	if s.originSource != nil {
+		defer s.originSource.Close()
+	}
+
 	if s.imageSource != nil {
 		return s.imageSource.Close()
 	}
 	return nil
 }
 
-func (s *LayerSource) getBlobWithRetries(logger lager.Logger, imgSrc types.ImageSource, blobInfo types.BlobInfo) (io.ReadCloser, int64, error) {
-	var err error
-	for i := 0; i < MAX_DOCKER_RETRIES; i++ {
-		logger.Debug(fmt.Sprintf("attempt-get-blob-%d", i+1))
-		blob, size, e := imgSrc.GetBlob(context.TODO(), blobInfo, none.NoCache)
-		if e == nil {
-			logger.Debug("attempt-get-blob-success")
-			return blob, size, nil
+func (s *LayerSource) getBlobWithRetries(ctx context.Context, logger lager.Logger, imgSrc types.ImageSource, blobInfo types.BlobInfo) (io.ReadCloser, int64, error) {
+	var (
+		blob io.ReadCloser
+		size int64
+	)
+	err := s.retry(ctx, logger, blobInfo.Digest.String(), func(attempt int) error {
+		logger.Debug(fmt.Sprintf("attempt-get-blob-%d", attempt))
+		var err error
+		blob, size, err = imgSrc.GetBlob(ctx, blobInfo, s.blobInfoCache())
+		if err != nil {
+			logger.Error("attempt-get-blob-failed", err)
+			return err
 		}
-		err = e
-		logger.Error("attempt-get-blob-failed", err)
+		logger.Debug("attempt-get-blob-success")
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
 	}
 
-	return nil, 0, err
+	return blob, size, nil
+}
+
+// retry calls fn according to the retry policy and reports every retry of
+// the request for the blob, or for the manifest when blobID is empty
+func (s *LayerSource) retry(ctx context.Context, logger lager.Logger, blobID string, fn func(attempt int) error) error {
+	return s.retryPolicy.Retry(ctx, logger, func(attempt int) error {
+		if attempt > 1 {
+			s.progress.Report(imagepuller.ProgressEvent{
+				Event:   imagepuller.ProgressRetry,
+				BlobID:  blobID,
+				Attempt: attempt,
+			})
+		}
+		return fn(attempt)
+	})
 }
 
 func (s *LayerSource) checkCheckSum(logger lager.Logger, hash hash.Hash, digest string, scheme string) error {
@@ -232,10 +566,10 @@ func (s *LayerSource) checkCheckSum(logger lager.Logger, hash hash.Hash, digest
 	return nil
 }
 
-func (s *LayerSource) reference(logger lager.Logger) (types.ImageReference, error) {
-	refString := generateRefString(s.imageURL)
+func reference(logger lager.Logger, imageURL *url.URL) (types.ImageReference, error) {
+	refString := generateRefString(imageURL)
 	logger.Debug("parsing-reference", lager.Data{"refString": refString})
-	transport := transports.Get(s.imageURL.Scheme)
+	transport := transports.Get(imageURL.Scheme)
 	ref, err := transport.ParseReference(refString)
 	if err != nil {
 		return nil, errors.Wrap(err, "parsing url failed")
@@ -258,30 +592,105 @@ func generateRefString(imageURL *url.URL) string {
 	return refString
 }
 
-func (s *LayerSource) getImageWithRetries(logger lager.Logger) (types.Image, error) {
-	var imgErr error
+func (s *LayerSource) getImageWithRetries(ctx context.Context, logger lager.Logger) (types.Image, error) {
 	var img types.Image
-	for i := 0; i < MAX_DOCKER_RETRIES; i++ {
-		logger.Debug(fmt.Sprintf("attempt-get-image-%d", i+1))
-
-		imageSource, err := s.getImageSource(logger)
-		if err == nil {
-			img, err = image.FromUnparsedImage(context.TODO(), &s.systemContext, image.UnparsedInstance(imageSource, nil))
-			if err == nil {
-				logger.Debug("attempt-get-image-success")
-				return img, nil
-			}
+	err := s.retry(ctx, logger, "", func(attempt int) error {
+		logger.Debug(fmt.Sprintf("attempt-get-image-%d", attempt))
+
+		imageSource, err := s.getImageSource(ctx, logger)
+		if err != nil {
+			return err
 		}
-		imgErr = err
+
+		unparsedImage, err := s.chooseInstance(ctx, logger, imageSource)
+		if err != nil {
+			return err
+		}
+
+		img, err = image.FromUnparsedImage(ctx, &s.systemContext, unparsedImage)
+		if err != nil {
+			return err
+		}
+
+		logger.Debug("attempt-get-image-success")
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "creating image")
 	}
 
-	return nil, errors.Wrap(imgErr, "creating image")
+	return img, nil
 }
 
-func (s *LayerSource) getImageSource(logger lager.Logger) (types.ImageSource, error) {
+// chooseInstance picks the image matching the platform of the system context
+// when the image is a manifest list or an OCI image index
+func (s *LayerSource) chooseInstance(ctx context.Context, logger lager.Logger, imageSource types.ImageSource) (*image.UnparsedImage, error) {
+	unparsedImage := image.UnparsedInstance(imageSource, nil)
+	manifestBlob, mimeType, err := unparsedImage.Manifest(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if !manifestpkg.MIMETypeIsMultiImage(mimeType) {
+		return unparsedImage, nil
+	}
+
+	list, err := manifestpkg.ListFromBlob(manifestBlob, mimeType)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing manifest list")
+	}
+
+	instanceDigest, err := list.ChooseInstance(&s.systemContext)
+	if err != nil {
+		return nil, imagepuller.Classify(imagepuller.ErrImageNotFound, errors.Errorf("no image found for platform %s, available platforms: %s", s.wantedPlatform(), strings.Join(availablePlatforms(list), ", ")))
+	}
+
+	logger.Debug("chose-image-for-platform", lager.Data{"platform": s.wantedPlatform(), "digest": instanceDigest})
+	return image.UnparsedInstance(imageSource, &instanceDigest), nil
+}
+
+func (s *LayerSource) wantedPlatform() string {
+	platform := imgspec.Platform{
+		OS:           s.systemContext.OSChoice,
+		Architecture: s.systemContext.ArchitectureChoice,
+		Variant:      s.systemContext.VariantChoice,
+	}
+	if platform.OS == "" {
+		platform.OS = runtime.GOOS
+	}
+	if platform.Architecture == "" {
+		platform.Architecture = runtime.GOARCH
+	}
+	return formatPlatform(platform)
+}
+
+func availablePlatforms(list manifestpkg.List) []string {
+	platforms := []string{}
+	for _, instanceDigest := range list.Instances() {
+		instance, err := list.Instance(instanceDigest)
+		if err != nil || instance.ReadOnly.Platform == nil {
+			continue
+		}
+		platforms = append(platforms, formatPlatform(*instance.ReadOnly.Platform))
+	}
+	return platforms
+}
+
+func formatPlatform(platform imgspec.Platform) string {
+	formatted := platform.OS + "/" + platform.Architecture
+	if platform.Variant != "" {
+		formatted += "/" + platform.Variant
+	}
+	return formatted
+}
+
+func (s *LayerSource) getImageSource(ctx context.Context, logger lager.Logger) (types.ImageSource, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
 	if s.imageSource == nil {
 		var err error
-		s.imageSource, err = s.createImageSource(logger)
+		s.imageSource, err = s.createImageSource(ctx, logger)
 		if err != nil {
 			return nil, err
 		}
@@ -290,22 +699,134 @@ func (s *LayerSource) getImageSource(logger lager.Logger) (types.ImageSource, er
 	return s.imageSource, nil
 }
 
-func (s *LayerSource) createImageSource(logger lager.Logger) (types.ImageSource, error) {
-	ref, err := s.reference(logger)
+func (s *LayerSource) getOriginSource(ctx context.Context, logger lager.Logger) (types.ImageSource, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.originSource == nil {
+		imgSrc, err := newImageSource(ctx, logger, s.imageURL, &s.systemContext)
+		if err != nil {
+			return nil, errors.Wrap(err, "creating image source")
+		}
+
+		s.originSource, err = s.newCachedImageSource(logger, imgSrc)
+		if err != nil {
+			imgSrc.Close()
+			return nil, err
+		}
+	}
+
+	return s.originSource, nil
+}
+
+// getEndpoint returns the URL of the image that the image source was created
+// for, and whether it is one of the mirrors
+func (s *LayerSource) getEndpoint() (string, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.endpoint, s.usingMirror
+}
+
+// createImageSource creates the image source from the first mirror that has
+// the image, falling back to the image URL when none of them do. The docker
+// transport fetches the manifest when the image source is created, so blobs
+// are fetched from the same endpoint as the manifest, unless the mirror fails
+// to serve a blob, which is then fetched from the image URL.
+//
+// When there is a blob cache, images are read through it. Offline, registry
+// images are only read from it.
+func (s *LayerSource) createImageSource(ctx context.Context, logger lager.Logger) (types.ImageSource, error) {
+	if s.isOfflineRegistryImage() {
+		if s.blobCache == nil {
+			return nil, errors.New("registry images can only be pulled offline from a blob cache")
+		}
+		return s.newCachedImageSource(logger, nil)
+	}
+
+	for _, mirror := range s.mirrors {
+		imgSrc, err := newImageSource(ctx, logger, mirror.URL, &mirror.SystemContext)
+		if err != nil {
+			logger.Error("mirror-failed", err, lager.Data{"endpoint": mirror.URL.String()})
+			continue
+		}
+
+		s.endpoint = mirror.URL.String()
+		s.usingMirror = true
+		logger.Info("using-endpoint", lager.Data{"endpoint": s.endpoint})
+		return s.newCachedImageSource(logger, imgSrc)
+	}
+
+	imgSrc, err := newImageSource(ctx, logger, s.imageURL, &s.systemContext)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating image source")
+	}
+
+	s.endpoint = s.imageURL.String()
+	if len(s.mirrors) > 0 {
+		logger.Info("using-endpoint", lager.Data{"endpoint": s.endpoint})
+	}
+	return s.newCachedImageSource(logger, imgSrc)
+}
+
+// newCachedImageSource reads images through the blob cache, if there is one.
+// upstream is nil for registry images offline.
+func (s *LayerSource) newCachedImageSource(logger lager.Logger, upstream types.ImageSource) (types.ImageSource, error) {
+	if s.blobCache == nil {
+		return upstream, nil
+	}
+
+	ref, err := reference(logger, s.imageURL)
 	if err != nil {
 		return nil, err
 	}
 
-	imgSrc, err := ref.NewImageSource(context.TODO(), &s.systemContext)
+	if upstream == nil {
+		s.endpoint = "blob-cache"
+	}
+
+	return &cachedImageSource{
+		ref:      ref,
+		upstream: upstream,
+		cache:    s.blobCache,
+		refName:  s.imageURL.String(),
+		logger:   logger,
+	}, nil
+}
+
+func (s *LayerSource) isOfflineRegistryImage() bool {
+	return s.offline && s.imageURL.Scheme == "docker"
+}
+
+// MissingBlobs returns the blob IDs of the layers that cannot be fetched
+// offline. Online, and for OCI layouts, every blob can be fetched.
+func (s *LayerSource) MissingBlobs(ctx context.Context, logger lager.Logger, layerInfos []imagepuller.LayerInfo) ([]string, error) {
+	missing := []string{}
+	if !s.isOfflineRegistryImage() {
+		return missing, nil
+	}
+
+	for _, layerInfo := range layerInfos {
+		if s.blobCache == nil || !s.blobCache.Has(digestpkg.Digest(layerInfo.BlobID)) {
+			missing = append(missing, layerInfo.BlobID)
+		}
+	}
+
+	logger.Debug("missing-blobs", lager.Data{"missing": missing})
+	return missing, nil
+}
+
+func newImageSource(ctx context.Context, logger lager.Logger, imageURL *url.URL, systemContext *types.SystemContext) (types.ImageSource, error) {
+	ref, err := reference(logger, imageURL)
 	if err != nil {
-		return nil, errors.Wrap(err, "creating image source")
+		return nil, err
 	}
 
-	return imgSrc, nil
+	return ref.NewImageSource(ctx, systemContext)
 }
 
-func (s *LayerSource) convertImage(logger lager.Logger, originalImage types.Image) (types.Image, error) {
-	_, mimetype, err := originalImage.Manifest(context.TODO())
+func (s *LayerSource) convertImage(ctx context.Context, logger lager.Logger, originalImage types.Image) (types.Image, error) {
+	_, mimetype, err := originalImage.Manifest(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -318,14 +839,14 @@ func (s *LayerSource) convertImage(logger lager.Logger, originalImage types.Imag
 	logger.Info("starting")
 	defer logger.Info("ending")
 
-	imgSrc, err := s.getImageSource(logger)
+	imgSrc, err := s.getImageSource(ctx, logger)
 	if err != nil {
 		return nil, err
 	}
 
 	diffIDs := []digestpkg.Digest{}
 	for _, layer := range originalImage.LayerInfos() {
-		diffID, err := s.v1DiffID(logger, layer, imgSrc)
+		diffID, err := s.v1DiffID(ctx, logger, layer, imgSrc)
 		if err != nil {
 			return nil, errors.Wrap(err, "converting V1 schema failed")
 		}
@@ -340,11 +861,11 @@ func (s *LayerSource) convertImage(logger lager.Logger, originalImage types.Imag
 		},
 	}
 
-	return originalImage.UpdatedImage(context.TODO(), options)
+	return originalImage.UpdatedImage(ctx, options)
 }
 
-func (s *LayerSource) v1DiffID(logger lager.Logger, layer types.BlobInfo, imgSrc types.ImageSource) (digestpkg.Digest, error) {
-	blob, _, err := s.getBlobWithRetries(logger, imgSrc, layer)
+func (s *LayerSource) v1DiffID(ctx context.Context, logger lager.Logger, layer types.BlobInfo, imgSrc types.ImageSource) (digestpkg.Digest, error) {
+	blob, _, err := s.getBlobWithRetries(ctx, logger, imgSrc, layer)
 	if err != nil {
 		return "", errors.Wrap(err, "fetching V1 layer blob")
 	}
@@ -375,3 +896,69 @@ func destToWindowsPath(input string) string {
 	}
 	return filepath.Clean(input)
 }
+
+// blobStream reads the (uncompressed) blob contents and closes every reader
+// in the chain
+type blobStream struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (b *blobStream) Close() error {
+	var closeErr error
+	for _, closer := range b.closers {
+		if err := closer.Close(); err != nil && closeErr == nil {
+			closeErr = err
+		}
+	}
+	return closeErr
+}
+
+// contextReader stops reading once the context is done, even for transports
+// (such as local OCI layouts) that do not honour the context themselves
+type contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.reader.Read(p)
+}
+
+type byteCounter int64
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	*c += byteCounter(len(p))
+	return len(p), nil
+}
+
+// progressInterval is how many blob bytes are transferred between two
+// bytes-transferred progress events
+const progressInterval = 1024 * 1024
+
+// transferProgress reports how much of a blob has been transferred, every
+// progressInterval bytes and once the whole blob has been transferred
+type transferProgress struct {
+	reporter    imagepuller.ProgressReporter
+	blobID      string
+	size        int64
+	transferred int64
+	reported    int64
+}
+
+func (t *transferProgress) Write(p []byte) (int, error) {
+	t.transferred += int64(len(p))
+	if t.transferred-t.reported >= progressInterval || (t.transferred == t.size && t.transferred > t.reported) {
+		t.reported = t.transferred
+		t.reporter.Report(imagepuller.ProgressEvent{
+			Event:  imagepuller.ProgressBytesTransferred,
+			BlobID: t.blobID,
+			Size:   t.size,
+			Bytes:  t.transferred,
+		})
+	}
+	return len(p), nil
+}
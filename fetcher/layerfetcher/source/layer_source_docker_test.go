@@ -37,6 +37,7 @@ var _ = Describe("Layer source: Docker", func() {
 		skipOCILayerValidation   bool
 		skipImageQuotaValidation bool
 		imageQuota               int64
+		retryPolicy              source.RetryPolicy
 	)
 
 	BeforeEach(func() {
@@ -45,6 +46,7 @@ var _ = Describe("Layer source: Docker", func() {
 		skipOCILayerValidation = false
 		skipImageQuotaValidation = true
 		imageQuota = 0
+		retryPolicy = source.DefaultRetryPolicy()
 
 		layerInfos = []imagepuller.LayerInfo{
 			{
@@ -68,7 +70,14 @@ var _ = Describe("Layer source: Docker", func() {
 	})
 
 	JustBeforeEach(func() {
-		layerSource = source.NewLayerSource(systemContext, skipOCILayerValidation, skipImageQuotaValidation, imageQuota, imageURL)
+		layerSource = source.NewLayerSource(source.Options{
+			ImageURL:                 imageURL,
+			SystemContext:            systemContext,
+			DiskLimit:                imageQuota,
+			SkipImageQuotaValidation: skipImageQuotaValidation,
+			SkipOCILayerValidation:   skipOCILayerValidation,
+			RetryPolicy:              retryPolicy,
+		})
 	})
 
 	Describe("Manifest", func() {
@@ -78,7 +87,7 @@ var _ = Describe("Layer source: Docker", func() {
 		)
 
 		JustBeforeEach(func() {
-			manifest, manifestErr = layerSource.Manifest(logger)
+			manifest, manifestErr = layerSource.Manifest(context.Background(), logger)
 		})
 
 		It("fetches the manifest", func() {
@@ -266,7 +275,7 @@ var _ = Describe("Layer source: Docker", func() {
 		)
 
 		JustBeforeEach(func() {
-			manifest, err := layerSource.Manifest(logger)
+			manifest, err := layerSource.Manifest(context.Background(), logger)
 			Expect(err).NotTo(HaveOccurred())
 			config, configErr = manifest.OCIConfig(context.TODO())
 		})
@@ -347,7 +356,7 @@ var _ = Describe("Layer source: Docker", func() {
 		)
 
 		JustBeforeEach(func() {
-			blobPath, blobSize, blobErr = layerSource.Blob(logger, layerInfos[0])
+			blobPath, blobSize, blobErr = layerSource.Blob(context.Background(), logger, layerInfos[0])
 		})
 
 		AfterEach(func() {
@@ -548,7 +557,7 @@ var _ = Describe("Layer source: Docker", func() {
 				systemContext.DockerInsecureSkipTLSVerify = types.OptionalBoolTrue
 				imageURL = urlParse(fmt.Sprintf("docker://%s/cfgarden/empty:groot", fakeRegistry.Addr()))
 				fakeRegistry.WhenGettingBlob(layerInfos[0].BlobID, 1, func(resp http.ResponseWriter, req *http.Request) {
-					resp.WriteHeader(http.StatusTeapot)
+					resp.WriteHeader(http.StatusServiceUnavailable)
 					_, _ = io.WriteString(resp, "null")
 				})
 			})
@@ -568,6 +577,50 @@ var _ = Describe("Layer source: Docker", func() {
 			})
 		})
 
+		Context("when the registry does not have the blob", func() {
+			BeforeEach(func() {
+				fakeRegistry.Start()
+				systemContext.DockerInsecureSkipTLSVerify = types.OptionalBoolTrue
+				imageURL = urlParse(fmt.Sprintf("docker://%s/cfgarden/empty:groot", fakeRegistry.Addr()))
+				fakeRegistry.WhenGettingBlob(layerInfos[0].BlobID, 0, func(resp http.ResponseWriter, req *http.Request) {
+					resp.WriteHeader(http.StatusNotFound)
+					_, _ = io.WriteString(resp, "null")
+				})
+			})
+
+			AfterEach(func() {
+				fakeRegistry.Stop()
+			})
+
+			It("returns an error", func() {
+				Expect(blobErr).To(HaveOccurred())
+			})
+
+			It("does not retry fetching the blob", func() {
+				Expect(logger).To(gbytes.Say("test-layer-source.streaming-blob.attempt-get-blob-failed"))
+				Expect(logger.TestSink.LogMessages()).NotTo(ContainElement("test-layer-source.streaming-blob.attempt-get-blob-2"))
+			})
+		})
+
+		Context("when the retry policy does not retry the returned status code", func() {
+			BeforeEach(func() {
+				retryPolicy.RetryableStatusCodes = []int{http.StatusTooManyRequests}
+				fakeRegistry.Start()
+				systemContext.DockerInsecureSkipTLSVerify = types.OptionalBoolTrue
+				imageURL = urlParse(fmt.Sprintf("docker://%s/cfgarden/empty:groot", fakeRegistry.Addr()))
+				fakeRegistry.FailNextBlobRequests(1)
+			})
+
+			AfterEach(func() {
+				fakeRegistry.Stop()
+			})
+
+			It("returns an error without retrying", func() {
+				Expect(blobErr).To(HaveOccurred())
+				Expect(logger.TestSink.LogMessages()).NotTo(ContainElement("test-layer-source.streaming-blob.attempt-get-blob-2"))
+			})
+		})
+
 		Context("when image quota validation is not skipped", func() {
 			BeforeEach(func() {
 				skipImageQuotaValidation = false
@@ -579,7 +632,7 @@ var _ = Describe("Layer source: Docker", func() {
 				})
 
 				It("returns quota exceeded error", func() {
-					_, _, err := layerSource.Blob(logger, layerInfos[0])
+					_, _, err := layerSource.Blob(context.Background(), logger, layerInfos[0])
 					Expect(err).To(MatchError(ContainSubstring("uncompressed layer size exceeds quota")))
 				})
 			})
@@ -2,18 +2,30 @@ package source_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/url"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
 
+	"code.cloudfoundry.org/groot/fetcher/blobcache"
 	"code.cloudfoundry.org/groot/fetcher/layerfetcher/source"
 	"code.cloudfoundry.org/groot/imagepuller"
+	"code.cloudfoundry.org/groot/imagepuller/imagepullerfakes"
 	"code.cloudfoundry.org/lager/v3/lagertest"
 	"github.com/containers/image/v5/types"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/opencontainers/go-digest"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 var _ = Describe("Layer source: OCI", func() {
@@ -31,12 +43,23 @@ var _ = Describe("Layer source: OCI", func() {
 		skipOCILayerValidation   bool
 		skipImageQuotaValidation bool
 		imageQuota               int64
+		mirrors                  []source.Mirror
+		blobCache                *blobcache.BlobCache
+		offline                  bool
+		signatureVerifier        source.SignatureVerifier
+		progressReporter         *imagepullerfakes.FakeProgressReporter
 	)
 
 	BeforeEach(func() {
+		systemContext = types.SystemContext{}
 		skipOCILayerValidation = false
 		skipImageQuotaValidation = true
 		imageQuota = 0
+		mirrors = nil
+		blobCache = nil
+		offline = false
+		signatureVerifier = nil
+		progressReporter = new(imagepullerfakes.FakeProgressReporter)
 
 		configBlob = "sha256:10c8f0eb9d1af08fe6e3b8dbd29e5aa2b6ecfa491ecd04ed90de19a4ac22de7b"
 		layerInfos = []imagepuller.LayerInfo{
@@ -62,12 +85,33 @@ var _ = Describe("Layer source: OCI", func() {
 	})
 
 	JustBeforeEach(func() {
-		layerSource = source.NewLayerSource(systemContext, skipOCILayerValidation, skipImageQuotaValidation, imageQuota, imageURL)
+		layerSource = source.NewLayerSource(source.Options{
+			ImageURL:                 imageURL,
+			SystemContext:            systemContext,
+			DiskLimit:                imageQuota,
+			SkipImageQuotaValidation: skipImageQuotaValidation,
+			SkipOCILayerValidation:   skipOCILayerValidation,
+			Mirrors:                  mirrors,
+			BlobCache:                blobCache,
+			Offline:                  offline,
+			SignatureVerifier:        signatureVerifier,
+			Progress:                 progressReporter,
+		})
 	})
 
 	Describe("Manifest", func() {
+		It("reports how long fetching the manifest took", func() {
+			_, err := layerSource.Manifest(context.Background(), logger)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(progressReporter.ReportCallCount()).To(Equal(1))
+			event := progressReporter.ReportArgsForCall(0)
+			Expect(event.Event).To(Equal(imagepuller.ProgressManifestFetched))
+			Expect(event.Duration).To(BeNumerically(">", 0))
+		})
+
 		It("fetches the manifest", func() {
-			manifest, err := layerSource.Manifest(logger)
+			manifest, err := layerSource.Manifest(context.Background(), logger)
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(manifest.ConfigInfo().Digest.String()).To(Equal(configBlob))
@@ -80,7 +124,7 @@ var _ = Describe("Layer source: OCI", func() {
 		})
 
 		It("contains the config", func() {
-			manifest, err := layerSource.Manifest(logger)
+			manifest, err := layerSource.Manifest(context.Background(), logger)
 			Expect(err).NotTo(HaveOccurred())
 
 			config, err := manifest.OCIConfig(context.TODO())
@@ -91,12 +135,161 @@ var _ = Describe("Layer source: OCI", func() {
 			Expect(config.RootFS.DiffIDs[1].Hex()).To(Equal(layerInfos[1].DiffID))
 		})
 
+		Context("when a signature verifier is given", func() {
+			var verifier *recordingVerifier
+
+			BeforeEach(func() {
+				verifier = &recordingVerifier{}
+				signatureVerifier = verifier
+			})
+
+			It("verifies the image the URL refers to", func() {
+				_, err := layerSource.Manifest(context.Background(), logger)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(verifier.verifiedImages).To(HaveLen(1))
+				manifest, _, err := verifier.verifiedImages[0].Manifest(context.Background())
+				Expect(err).NotTo(HaveOccurred())
+				Expect(digest.FromBytes(manifest).String()).To(Equal("sha256:9c90ae0cffa9d1426e83a516183f0267e03edbb765efc5fb0c0dccc8edca4f15"))
+			})
+
+			Context("when the image is fetched from a mirror", func() {
+				BeforeEach(func() {
+					mirrors = []source.Mirror{
+						{URL: urlParse(fmt.Sprintf("oci:///%s/../../../integration/oci-test-images/zstd-busybox:latest", workDir))},
+					}
+				})
+
+				It("verifies the image as the image URL", func() {
+					_, err := layerSource.Manifest(context.Background(), logger)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(verifier.verifiedImages).To(HaveLen(1))
+					Expect(verifier.verifiedImages[0].Reference().StringWithinTransport()).To(ContainSubstring("opq-whiteouts-busybox:latest"))
+				})
+			})
+
+			It("closes the verifier with the layer source", func() {
+				Expect(layerSource.Close()).To(Succeed())
+				Expect(verifier.closed).To(BeTrue())
+			})
+
+			Context("when the verifier rejects the image", func() {
+				BeforeEach(func() {
+					verifier.err = errors.New("not-signed")
+				})
+
+				It("returns the error", func() {
+					_, err := layerSource.Manifest(context.Background(), logger)
+					Expect(err).To(MatchError("not-signed"))
+				})
+
+				It("classifies the error as a policy rejection", func() {
+					_, err := layerSource.Manifest(context.Background(), logger)
+					Expect(err).To(MatchError(imagepuller.ErrPolicyRejected))
+				})
+			})
+		})
+
+		Context("when the image is a multi-architecture image", func() {
+			BeforeEach(func() {
+				imageURL = urlParse(fmt.Sprintf("oci:///%s/../../../integration/oci-test-images/multi-arch:latest", workDir))
+				systemContext.OSChoice = "linux"
+				systemContext.ArchitectureChoice = "amd64"
+			})
+
+			It("fetches the manifest for the chosen platform", func() {
+				manifest, err := layerSource.Manifest(context.Background(), logger)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(manifest.ConfigInfo().Digest.String()).To(Equal(configBlob))
+				Expect(manifest.LayerInfos()).To(HaveLen(2))
+			})
+
+			Context("when another platform is chosen", func() {
+				BeforeEach(func() {
+					systemContext.ArchitectureChoice = "arm64"
+					systemContext.VariantChoice = "v8"
+				})
+
+				It("fetches the manifest for that platform", func() {
+					manifest, err := layerSource.Manifest(context.Background(), logger)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(manifest.LayerInfos()).To(HaveLen(1))
+					Expect(manifest.LayerInfos()[0].Digest.String()).To(Equal(layerInfos[0].BlobID))
+
+					config, err := manifest.OCIConfig(context.Background())
+					Expect(err).NotTo(HaveOccurred())
+					Expect(config.Architecture).To(Equal("arm64"))
+				})
+			})
+
+			Context("when no image matches the chosen platform", func() {
+				BeforeEach(func() {
+					systemContext.OSChoice = "windows"
+				})
+
+				It("returns an error listing the available platforms", func() {
+					_, err := layerSource.Manifest(context.Background(), logger)
+					Expect(err).To(MatchError(ContainSubstring("no image found for platform windows/amd64, available platforms: linux/amd64, linux/arm64/v8")))
+				})
+			})
+		})
+
+		Context("when mirrors are given", func() {
+			var mirrorURL *url.URL
+
+			BeforeEach(func() {
+				mirrorURL = urlParse(fmt.Sprintf("oci:///%s/../../../integration/oci-test-images/zstd-busybox:latest", workDir))
+				mirrors = []source.Mirror{
+					{URL: urlParse("oci:///cfgarden/non-existing-image")},
+					{URL: mirrorURL},
+				}
+			})
+
+			It("fetches the manifest from the first mirror that has the image", func() {
+				manifest, err := layerSource.Manifest(context.Background(), logger)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(manifest.LayerInfos()[0].Digest.String()).To(Equal("sha256:bea2e79b226da45be0d8d103d8a4738026fdb817765cf54c1fe04506a71ac9f5"))
+			})
+
+			It("logs which endpoint is used", func() {
+				_, err := layerSource.Manifest(context.Background(), logger)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(logger.LogMessages()).To(ContainElement("test-layer-source.fetching-image-manifest.mirror-failed"))
+				Expect(logger.Logs()).To(ContainElement(And(
+					HaveField("Message", "test-layer-source.fetching-image-manifest.using-endpoint"),
+					HaveField("Data", HaveKeyWithValue("endpoint", mirrorURL.String())),
+				)))
+			})
+
+			Context("when none of the mirrors has the image", func() {
+				BeforeEach(func() {
+					mirrors = mirrors[:1]
+				})
+
+				It("falls back to the image URL", func() {
+					manifest, err := layerSource.Manifest(context.Background(), logger)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(manifest.ConfigInfo().Digest.String()).To(Equal(configBlob))
+					Expect(logger.Logs()).To(ContainElement(And(
+						HaveField("Message", "test-layer-source.fetching-image-manifest.using-endpoint"),
+						HaveField("Data", HaveKeyWithValue("endpoint", imageURL.String())),
+					)))
+				})
+			})
+		})
+
 		Context("when the image url is invalid", func() {
 			BeforeEach(func() {
 				imageURL = urlParse("oci://///\\cfgarden/empty:v0.1.0")
 			})
 			It("returns an error", func() {
-				_, err := layerSource.Manifest(logger)
+				_, err := layerSource.Manifest(context.Background(), logger)
 				Expect(err).To(MatchError(ContainSubstring("parsing url failed")))
 			})
 		})
@@ -107,9 +300,14 @@ var _ = Describe("Layer source: OCI", func() {
 			})
 
 			It("wraps the containers/image with a useful error", func() {
-				_, err := layerSource.Manifest(logger)
+				_, err := layerSource.Manifest(context.Background(), logger)
 				Expect(err.Error()).To(MatchRegexp("^fetching image reference"))
 			})
+
+			It("classifies it as image not found", func() {
+				_, err := layerSource.Manifest(context.Background(), logger)
+				Expect(err).To(MatchError(imagepuller.ErrImageNotFound))
+			})
 		})
 
 		Context("when the config blob does not exist", func() {
@@ -118,7 +316,7 @@ var _ = Describe("Layer source: OCI", func() {
 			})
 
 			It("retuns an error", func() {
-				_, err := layerSource.Manifest(logger)
+				_, err := layerSource.Manifest(context.Background(), logger)
 				Expect(err).To(MatchError(ContainSubstring("creating image")))
 			})
 		})
@@ -134,12 +332,71 @@ var _ = Describe("Layer source: OCI", func() {
 			})
 
 			It("fetches the manifest without error", func() {
-				_, err := layerSource.Manifest(logger)
+				_, err := layerSource.Manifest(context.Background(), logger)
 				Expect(err).NotTo(HaveOccurred())
 			})
 		})
 	})
 
+	Describe("tracing", func() {
+		var exporter *tracetest.InMemoryExporter
+
+		BeforeEach(func() {
+			exporter = tracetest.NewInMemoryExporter()
+			otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+		})
+
+		AfterEach(func() {
+			otel.SetTracerProvider(noop.NewTracerProvider())
+		})
+
+		It("records a span for fetching the manifest", func() {
+			_, err := layerSource.Manifest(context.Background(), logger)
+			Expect(err).NotTo(HaveOccurred())
+
+			spans := exporter.GetSpans()
+			Expect(spans).To(HaveLen(1))
+			Expect(spans[0].Name).To(Equal("source.Manifest"))
+			Expect(spans[0].Status.Code).To(Equal(codes.Unset))
+		})
+
+		It("records a span for downloading a blob", func() {
+			blobPath, _, err := layerSource.Blob(context.Background(), logger, layerInfos[0])
+			Expect(err).NotTo(HaveOccurred())
+			Expect(os.Remove(blobPath)).To(Succeed())
+
+			spans := exporter.GetSpans()
+			Expect(spans).To(HaveLen(1))
+			Expect(spans[0].Name).To(Equal("source.Blob"))
+			Expect(spans[0].Attributes).To(ContainElement(attribute.String("groot.blob_id", layerInfos[0].BlobID)))
+		})
+
+		It("records a span for opening the stream of a blob", func() {
+			stream, _, err := layerSource.StreamBlob(context.Background(), logger, layerInfos[0])
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stream.Close()).To(Succeed())
+
+			spans := exporter.GetSpans()
+			Expect(spans).To(HaveLen(1))
+			Expect(spans[0].Name).To(Equal("source.StreamBlob"))
+		})
+
+		Context("when the image does not exist", func() {
+			BeforeEach(func() {
+				imageURL = urlParse("oci:///cfgarden/non-existing-image")
+			})
+
+			It("marks the manifest span as failed", func() {
+				_, err := layerSource.Manifest(context.Background(), logger)
+				Expect(err).To(HaveOccurred())
+
+				spans := exporter.GetSpans()
+				Expect(spans).To(HaveLen(1))
+				Expect(spans[0].Status.Code).To(Equal(codes.Error))
+			})
+		})
+	})
+
 	Describe("Blob", func() {
 		var (
 			layerInfo imagepuller.LayerInfo
@@ -154,7 +411,7 @@ var _ = Describe("Layer source: OCI", func() {
 		})
 
 		JustBeforeEach(func() {
-			blobPath, blobSize, blobErr = layerSource.Blob(logger, layerInfo)
+			blobPath, blobSize, blobErr = layerSource.Blob(context.Background(), logger, layerInfo)
 		})
 
 		AfterEach(func() {
@@ -175,6 +432,278 @@ var _ = Describe("Layer source: OCI", func() {
 			Expect(entries).To(ContainElement("etc/localtime"))
 		})
 
+		Context("when the mirror in use does not have the blob", func() {
+			var mirrorURL *url.URL
+
+			BeforeEach(func() {
+				mirrorURL = urlParse(fmt.Sprintf("oci:///%s/../../../integration/oci-test-images/zstd-busybox:latest", workDir))
+				mirrors = []source.Mirror{{URL: mirrorURL}}
+			})
+
+			It("fetches the blob from the image URL", func() {
+				Expect(blobErr).NotTo(HaveOccurred())
+				Expect(blobSize).To(Equal(int64(668151)))
+			})
+
+			It("logs the endpoint the blob was fetched from", func() {
+				Expect(blobErr).NotTo(HaveOccurred())
+
+				Expect(logger.Logs()).To(ContainElement(And(
+					HaveField("Message", "test-layer-source.streaming-blob.mirror-blob-failed"),
+					HaveField("Data", HaveKeyWithValue("endpoint", mirrorURL.String())),
+				)))
+				Expect(logger.Logs()).To(ContainElement(And(
+					HaveField("Message", "test-layer-source.streaming-blob.got-blob"),
+					HaveField("Data", HaveKeyWithValue("endpoint", imageURL.String())),
+				)))
+			})
+
+			Context("when the image URL does not have the blob either", func() {
+				BeforeEach(func() {
+					layerInfo = imagepuller.LayerInfo{BlobID: "sha256:0000000000000000000000000000000000000000000000000000000000000000"}
+				})
+
+				It("returns an error", func() {
+					Expect(blobErr).To(HaveOccurred())
+				})
+			})
+		})
+
+		Context("when the layer is nondistributable", func() {
+			BeforeEach(func() {
+				layerInfo.MediaType = "application/vnd.oci.image.layer.nondistributable.v1.tar+gzip"
+			})
+
+			It("uncompresses the blob", func() {
+				Expect(blobErr).NotTo(HaveOccurred())
+
+				blobReader, err := os.Open(blobPath)
+				Expect(err).NotTo(HaveOccurred())
+				defer blobReader.Close()
+
+				Expect(tarEntries(blobReader)).To(ContainElement("etc/localtime"))
+			})
+		})
+
+		It("reports the progress of the download", func() {
+			Expect(blobErr).NotTo(HaveOccurred())
+
+			events := []imagepuller.ProgressEvent{}
+			for i := 0; i < progressReporter.ReportCallCount(); i++ {
+				events = append(events, progressReporter.ReportArgsForCall(i))
+			}
+			Expect(events).NotTo(BeEmpty())
+
+			Expect(events[0]).To(Equal(imagepuller.ProgressEvent{
+				Event:  imagepuller.ProgressDownloadStarted,
+				BlobID: layerInfo.BlobID,
+				Size:   668151,
+			}))
+			verified := events[len(events)-1]
+			Expect(verified.Duration).To(BeNumerically(">", 0))
+			verified.Duration = 0
+			Expect(verified).To(Equal(imagepuller.ProgressEvent{
+				Event:  imagepuller.ProgressLayerVerified,
+				BlobID: layerInfo.BlobID,
+				Size:   668151,
+				Bytes:  668151,
+			}))
+
+			transferred := events[1 : len(events)-1]
+			Expect(transferred).NotTo(BeEmpty())
+			for _, event := range transferred {
+				Expect(event.Event).To(Equal(imagepuller.ProgressBytesTransferred))
+				Expect(event.BlobID).To(Equal(layerInfo.BlobID))
+			}
+			Expect(transferred[len(transferred)-1].Bytes).To(Equal(int64(668151)))
+		})
+
+		Context("when a blob cache is given", func() {
+			var (
+				cacheDir       string
+				cachedBlobPath string
+			)
+
+			BeforeEach(func() {
+				var err error
+				cacheDir, err = os.MkdirTemp("", "blob-cache")
+				Expect(err).NotTo(HaveOccurred())
+				blobCache, err = blobcache.New(cacheDir, 0)
+				Expect(err).NotTo(HaveOccurred())
+
+				cachedBlobPath = filepath.Join(cacheDir, "blobs", "sha256", strings.TrimPrefix(layerInfo.BlobID, "sha256:"))
+			})
+
+			AfterEach(func() {
+				Expect(os.RemoveAll(cacheDir)).To(Succeed())
+			})
+
+			It("adds the blob to the cache", func() {
+				Expect(blobErr).NotTo(HaveOccurred())
+				Expect(cachedBlobPath).To(BeARegularFile())
+
+				layerBlob, err := os.ReadFile(filepath.Join(workDir, "../../../integration/oci-test-images/opq-whiteouts-busybox/blobs/sha256", strings.TrimPrefix(layerInfo.BlobID, "sha256:")))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(os.ReadFile(cachedBlobPath)).To(Equal(layerBlob))
+			})
+
+			Context("when the cache has the blob", func() {
+				BeforeEach(func() {
+					layerBlob, err := os.ReadFile(filepath.Join(workDir, "../../../integration/oci-test-images/opq-whiteouts-busybox/blobs/sha256", strings.TrimPrefix(layerInfo.BlobID, "sha256:")))
+					Expect(err).NotTo(HaveOccurred())
+					Expect(os.MkdirAll(filepath.Dir(cachedBlobPath), 0700)).To(Succeed())
+					Expect(os.WriteFile(cachedBlobPath, layerBlob, 0600)).To(Succeed())
+
+					imageURL = urlParse("oci:///cfgarden/non-existing-image")
+				})
+
+				It("does not fetch the blob from the image", func() {
+					Expect(blobErr).NotTo(HaveOccurred())
+					Expect(blobSize).To(Equal(int64(668151)))
+
+					blobReader, err := os.Open(blobPath)
+					Expect(err).NotTo(HaveOccurred())
+					defer blobReader.Close()
+					Expect(tarEntries(blobReader)).To(ContainElement("etc/localtime"))
+				})
+
+				It("reports the blob as cached", func() {
+					Expect(blobErr).NotTo(HaveOccurred())
+
+					lastEvent := progressReporter.ReportArgsForCall(progressReporter.ReportCallCount() - 1)
+					Expect(lastEvent.Event).To(Equal(imagepuller.ProgressLayerVerified))
+					Expect(lastEvent.Cached).To(BeTrue())
+					Expect(lastEvent.Bytes).To(Equal(int64(668151)))
+				})
+			})
+
+			Context("when the cached blob is corrupted", func() {
+				BeforeEach(func() {
+					Expect(os.MkdirAll(filepath.Dir(cachedBlobPath), 0700)).To(Succeed())
+					Expect(os.WriteFile(cachedBlobPath, []byte("corrupted"), 0600)).To(Succeed())
+				})
+
+				It("fetches the blob from the image instead", func() {
+					Expect(blobErr).NotTo(HaveOccurred())
+					Expect(blobSize).To(Equal(int64(668151)))
+				})
+
+				It("replaces the cached blob", func() {
+					cachedBlob, err := os.ReadFile(cachedBlobPath)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(digest.FromBytes(cachedBlob).String()).To(Equal(layerInfo.BlobID))
+				})
+			})
+		})
+
+		Context("when the layers are not gzipped", func() {
+			BeforeEach(func() {
+				imageURL = urlParse(fmt.Sprintf("oci:///%s/../../../integration/oci-test-images/zstd-busybox:latest", workDir))
+			})
+
+			Context("when the layer is zstd compressed", func() {
+				BeforeEach(func() {
+					layerInfo = imagepuller.LayerInfo{
+						BlobID:    "sha256:bea2e79b226da45be0d8d103d8a4738026fdb817765cf54c1fe04506a71ac9f5",
+						DiffID:    layerInfos[0].DiffID,
+						Size:      596343,
+						MediaType: "application/vnd.oci.image.layer.v1.tar+zstd",
+					}
+				})
+
+				It("uncompresses the blob", func() {
+					Expect(blobErr).NotTo(HaveOccurred())
+
+					blobReader, err := os.Open(blobPath)
+					Expect(err).NotTo(HaveOccurred())
+					defer blobReader.Close()
+
+					entries := tarEntries(blobReader)
+					Expect(entries).To(ContainElement("etc/localtime"))
+				})
+
+				Context("when the layer is nondistributable", func() {
+					BeforeEach(func() {
+						layerInfo.MediaType = "application/vnd.oci.image.layer.nondistributable.v1.tar+zstd"
+					})
+
+					It("uncompresses the blob", func() {
+						Expect(blobErr).NotTo(HaveOccurred())
+
+						blobReader, err := os.Open(blobPath)
+						Expect(err).NotTo(HaveOccurred())
+						defer blobReader.Close()
+
+						Expect(tarEntries(blobReader)).To(ContainElement("etc/localtime"))
+					})
+				})
+
+				Context("when the blob doesn't match the diffID", func() {
+					BeforeEach(func() {
+						layerInfo.DiffID = "0000000000000000000000000000000000000000000000000000000000000000"
+					})
+
+					It("returns an error", func() {
+						Expect(blobErr).To(MatchError(ContainSubstring("diffID digest mismatch")))
+					})
+				})
+
+				Context("when image quota validation is not skipped", func() {
+					BeforeEach(func() {
+						skipImageQuotaValidation = false
+						imageQuota = 1
+					})
+
+					It("returns quota exceeded error", func() {
+						Expect(blobErr).To(MatchError(ContainSubstring("uncompressed layer size exceeds quota")))
+					})
+				})
+			})
+
+			Context("when the layer is uncompressed", func() {
+				BeforeEach(func() {
+					layerInfo = imagepuller.LayerInfo{
+						BlobID:    "sha256:1e664bbd066a13dc6e8d9503fe0d439e89617eaac0558a04240bcbf4bd969ff9",
+						DiffID:    layerInfos[1].DiffID,
+						Size:      2048,
+						MediaType: "application/vnd.oci.image.layer.v1.tar",
+					}
+				})
+
+				It("returns the blob as is", func() {
+					Expect(blobErr).NotTo(HaveOccurred())
+					Expect(blobSize).To(Equal(int64(2048)))
+
+					blobReader, err := os.Open(blobPath)
+					Expect(err).NotTo(HaveOccurred())
+					defer blobReader.Close()
+
+					Expect(tarEntries(blobReader)).To(ContainElement("var/.wh..wh..opq"))
+				})
+
+				Context("when the layer is nondistributable", func() {
+					BeforeEach(func() {
+						layerInfo.MediaType = "application/vnd.oci.image.layer.nondistributable.v1.tar"
+					})
+
+					It("returns the blob as is", func() {
+						Expect(blobErr).NotTo(HaveOccurred())
+						Expect(blobSize).To(Equal(int64(2048)))
+					})
+				})
+			})
+		})
+
+		Context("when the layer media type is not supported", func() {
+			BeforeEach(func() {
+				layerInfo.MediaType = "application/vnd.oci.image.layer.v1.tar+lz4"
+			})
+
+			It("returns an error", func() {
+				Expect(blobErr).To(MatchError(ContainSubstring("unsupported layer media type application/vnd.oci.image.layer.v1.tar+lz4")))
+			})
+		})
+
 		Context("when the blob has an invalid checksum", func() {
 			BeforeEach(func() {
 				layerInfo = imagepuller.LayerInfo{BlobID: "sha256:steamed-blob"}
@@ -194,6 +723,10 @@ var _ = Describe("Layer source: OCI", func() {
 			It("returns an error", func() {
 				Expect(blobErr).To(MatchError(ContainSubstring("layerID digest mismatch")))
 			})
+
+			It("classifies it as a digest mismatch", func() {
+				Expect(blobErr).To(MatchError(imagepuller.ErrDigestMismatch))
+			})
 		})
 
 		Context("when skipOCILayerValidation is set to true", func() {
@@ -284,12 +817,191 @@ var _ = Describe("Layer source: OCI", func() {
 				})
 
 				It("fails when downloading subsequent layers", func() {
-					_, _, err := layerSource.Blob(logger, layerInfos[1])
+					_, _, err := layerSource.Blob(context.Background(), logger, layerInfos[1])
 					Expect(err).To(MatchError(ContainSubstring("uncompressed layer size exceeds quota")))
 				})
 			})
 		})
 	})
+
+	Describe("StreamBlob", func() {
+		var (
+			layerInfo imagepuller.LayerInfo
+
+			stream    io.ReadCloser
+			blobSize  int64
+			streamErr error
+		)
+
+		BeforeEach(func() {
+			layerInfo = layerInfos[0]
+		})
+
+		JustBeforeEach(func() {
+			stream, blobSize, streamErr = layerSource.StreamBlob(context.Background(), logger, layerInfo)
+		})
+
+		AfterEach(func() {
+			if stream != nil {
+				Expect(stream.Close()).To(Succeed())
+			}
+		})
+
+		It("streams the uncompressed blob", func() {
+			Expect(streamErr).NotTo(HaveOccurred())
+			Expect(blobSize).To(Equal(int64(668151)))
+
+			entries := tarEntries(stream)
+			Expect(entries).To(ContainElement("etc/localtime"))
+		})
+
+		It("succeeds when the stream is read to the end", func() {
+			Expect(streamErr).NotTo(HaveOccurred())
+			_, err := io.Copy(io.Discard, stream)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		Context("when the blob is corrupted", func() {
+			BeforeEach(func() {
+				imageURL = urlParse(fmt.Sprintf("oci:///%s/../../../integration/oci-test-images/corrupted:latest", workDir))
+				layerInfo.Size = 668551
+			})
+
+			It("fails at the end of the stream", func() {
+				Expect(streamErr).NotTo(HaveOccurred())
+				_, err := io.Copy(io.Discard, stream)
+				Expect(err).To(MatchError(ContainSubstring("layerID digest mismatch")))
+			})
+		})
+
+		Context("when the blob doesn't match the diffID", func() {
+			BeforeEach(func() {
+				layerInfo.DiffID = "0000000000000000000000000000000000000000000000000000000000000000"
+			})
+
+			It("fails at the end of the stream", func() {
+				Expect(streamErr).NotTo(HaveOccurred())
+				_, err := io.Copy(io.Discard, stream)
+				Expect(err).To(MatchError(ContainSubstring("diffID digest mismatch")))
+			})
+		})
+
+		Context("when the actual blob size is different than the layersize in the manifest", func() {
+			BeforeEach(func() {
+				layerInfo.Size = 100
+			})
+
+			It("returns an error", func() {
+				Expect(streamErr).To(MatchError(ContainSubstring("layer size is different from the value in the manifest")))
+			})
+		})
+
+		Context("when the first layer exhausts the quota", func() {
+			BeforeEach(func() {
+				skipImageQuotaValidation = false
+				imageQuota = int64(1293824)
+			})
+
+			It("fails when streaming subsequent layers", func() {
+				_, err := io.Copy(io.Discard, stream)
+				Expect(err).NotTo(HaveOccurred())
+
+				nextStream, _, err := layerSource.StreamBlob(context.Background(), logger, layerInfos[1])
+				Expect(err).NotTo(HaveOccurred())
+				defer nextStream.Close()
+
+				_, err = io.Copy(io.Discard, nextStream)
+				Expect(err).To(MatchError(ContainSubstring("uncompressed layer size exceeds quota")))
+			})
+		})
+	})
+
+	Describe("MissingBlobs", func() {
+		Context("when offline", func() {
+			BeforeEach(func() {
+				offline = true
+			})
+
+			It("does not report OCI layout blobs as missing", func() {
+				missingBlobs, err := layerSource.MissingBlobs(context.Background(), logger, layerInfos)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(missingBlobs).To(BeEmpty())
+			})
+
+			It("fetches the manifest and blobs from the OCI layout", func() {
+				_, err := layerSource.Manifest(context.Background(), logger)
+				Expect(err).NotTo(HaveOccurred())
+
+				blobPath, _, err := layerSource.Blob(context.Background(), logger, layerInfos[0])
+				Expect(err).NotTo(HaveOccurred())
+				Expect(os.Remove(blobPath)).To(Succeed())
+			})
+
+			Context("when the image is in a registry", func() {
+				var cacheDir string
+
+				BeforeEach(func() {
+					imageURL = urlParse("docker://127.0.0.1:1/cfgarden/image")
+
+					var err error
+					cacheDir, err = os.MkdirTemp("", "blob-cache")
+					Expect(err).NotTo(HaveOccurred())
+					blobCache, err = blobcache.New(cacheDir, 0)
+					Expect(err).NotTo(HaveOccurred())
+
+					layerBlob, err := os.ReadFile(filepath.Join(workDir, "../../../integration/oci-test-images/opq-whiteouts-busybox/blobs/sha256", strings.TrimPrefix(layerInfos[0].BlobID, "sha256:")))
+					Expect(err).NotTo(HaveOccurred())
+					Expect(blobCache.Put(logger, digest.Digest(layerInfos[0].BlobID), layerBlob)).To(Succeed())
+				})
+
+				AfterEach(func() {
+					Expect(os.RemoveAll(cacheDir)).To(Succeed())
+				})
+
+				It("reports the blobs that are not in the blob cache", func() {
+					missingBlobs, err := layerSource.MissingBlobs(context.Background(), logger, layerInfos)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(missingBlobs).To(ConsistOf(layerInfos[1].BlobID))
+				})
+
+				It("fetches the cached blobs without contacting the registry", func() {
+					blobPath, size, err := layerSource.Blob(context.Background(), logger, layerInfos[0])
+					Expect(err).NotTo(HaveOccurred())
+					Expect(size).To(Equal(int64(668151)))
+					Expect(os.Remove(blobPath)).To(Succeed())
+				})
+
+				It("fails to fetch the manifest when it is not cached", func() {
+					_, err := layerSource.Manifest(context.Background(), logger)
+					Expect(err).To(MatchError(ContainSubstring("no manifest for docker://127.0.0.1:1/cfgarden/image in the blob cache")))
+				})
+
+				Context("when there is no blob cache", func() {
+					BeforeEach(func() {
+						blobCache = nil
+					})
+
+					It("reports every blob as missing", func() {
+						missingBlobs, err := layerSource.MissingBlobs(context.Background(), logger, layerInfos)
+						Expect(err).NotTo(HaveOccurred())
+						Expect(missingBlobs).To(ConsistOf(layerInfos[0].BlobID, layerInfos[1].BlobID))
+					})
+				})
+			})
+		})
+
+		Context("when not offline", func() {
+			BeforeEach(func() {
+				imageURL = urlParse("docker://127.0.0.1:1/cfgarden/image")
+			})
+
+			It("does not report any blob as missing", func() {
+				missingBlobs, err := layerSource.MissingBlobs(context.Background(), logger, layerInfos)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(missingBlobs).To(BeEmpty())
+			})
+		})
+	})
 })
 
 func pathToUnixURI(path string) string {
@@ -298,3 +1010,19 @@ func pathToUnixURI(path string) string {
 	path = strings.TrimPrefix(path, "/")
 	return path
 }
+
+type recordingVerifier struct {
+	verifiedImages []types.UnparsedImage
+	err            error
+	closed         bool
+}
+
+func (v *recordingVerifier) Verify(ctx context.Context, image types.UnparsedImage) error {
+	v.verifiedImages = append(v.verifiedImages, image)
+	return v.err
+}
+
+func (v *recordingVerifier) Close() error {
+	v.closed = true
+	return nil
+}
@@ -0,0 +1,151 @@
+package source // import "code.cloudfoundry.org/groot/fetcher/layerfetcher/source"
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+	"github.com/containers/image/v5/docker"
+	"github.com/docker/distribution/registry/api/errcode"
+	"github.com/pkg/errors"
+)
+
+// RetryPolicy decides which failed registry requests are retried, how many
+// times, and how long to wait in between
+type RetryPolicy struct {
+	// Attempts is the maximum number of times a request is made
+	Attempts int
+	// InitialBackoff is the wait before the first retry. It doubles with every
+	// further retry up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Jitter is the fraction (between 0 and 1) of every backoff that is
+	// randomised, so that clients failing together do not retry together
+	Jitter float64
+	// RetryableStatusCodes are the HTTP status codes that are worth retrying.
+	// When empty, 408, 429 and all 5xx responses are retried.
+	RetryableStatusCodes []int
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		Attempts:       3,
+		InitialBackoff: 200 * time.Millisecond,
+		MaxBackoff:     10 * time.Second,
+		Jitter:         0.2,
+	}
+}
+
+// Retry calls fn until it succeeds, fails with an error that is not
+// retryable, or the attempts are used up. fn is passed the number of the
+// current attempt, starting at 1. The last error is returned.
+func (p RetryPolicy) Retry(ctx context.Context, logger lager.Logger, fn func(attempt int) error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn(attempt)
+		if err == nil {
+			return nil
+		}
+
+		if attempt >= p.Attempts || !p.IsRetryable(err) {
+			return err
+		}
+
+		backoff := p.Backoff(attempt)
+		logger.Debug("backing-off", lager.Data{"attempt": attempt, "backoff": backoff.String()})
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
+
+// Backoff returns how long to wait after the given failed attempt
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	backoff := p.InitialBackoff
+	for i := 1; i < attempt && (p.MaxBackoff <= 0 || backoff < p.MaxBackoff); i++ {
+		backoff *= 2
+	}
+	if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+
+	if p.Jitter <= 0 {
+		return backoff
+	}
+
+	jitter := p.Jitter
+	if jitter > 1 {
+		jitter = 1
+	}
+	// #nosec - G404 - the jitter does not need to be cryptographically random
+	return time.Duration(float64(backoff) * (1 - jitter*rand.Float64()))
+}
+
+// IsRetryable returns whether the failed request might succeed when made
+// again. Server errors, rate limiting and dropped connections are retryable.
+// Other client errors, such as 401 or 404 responses, and any errors that are
+// not caused by the network are not.
+func (p RetryPolicy) IsRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	if statusCode, ok := httpStatusCode(err); ok {
+		return p.isRetryableStatusCode(statusCode)
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+func (p RetryPolicy) isRetryableStatusCode(statusCode int) bool {
+	if len(p.RetryableStatusCodes) == 0 {
+		return statusCode == http.StatusRequestTimeout ||
+			statusCode == http.StatusTooManyRequests ||
+			statusCode >= http.StatusInternalServerError
+	}
+
+	for _, retryableStatusCode := range p.RetryableStatusCodes {
+		if statusCode == retryableStatusCode {
+			return true
+		}
+	}
+	return false
+}
+
+// httpStatusCode extracts the status code of a failed registry response from
+// the errors returned by containers/image
+func httpStatusCode(err error) (int, bool) {
+	if errors.Is(err, docker.ErrTooManyRequests) {
+		return http.StatusTooManyRequests, true
+	}
+
+	var unauthorizedErr docker.ErrUnauthorizedForCredentials
+	if errors.As(err, &unauthorizedErr) {
+		return http.StatusUnauthorized, true
+	}
+
+	var statusErr docker.UnexpectedHTTPStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode, true
+	}
+
+	var registryErr errcode.ErrorCoder
+	if errors.As(err, &registryErr) {
+		return registryErr.ErrorCode().Descriptor().HTTPStatusCode, true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,181 @@
+package source_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"syscall"
+	"time"
+
+	"code.cloudfoundry.org/groot/fetcher/layerfetcher/source"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	"github.com/containers/image/v5/docker"
+	"github.com/docker/distribution/registry/api/errcode"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	errorspkg "github.com/pkg/errors"
+)
+
+var _ = Describe("RetryPolicy", func() {
+	var (
+		logger      *lagertest.TestLogger
+		retryPolicy source.RetryPolicy
+	)
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("retry-policy")
+		retryPolicy = source.RetryPolicy{
+			Attempts:       3,
+			InitialBackoff: time.Millisecond,
+			MaxBackoff:     10 * time.Millisecond,
+		}
+	})
+
+	Describe("Retry", func() {
+		var (
+			attempts []int
+			errs     []error
+		)
+
+		BeforeEach(func() {
+			attempts = []int{}
+			errs = []error{}
+		})
+
+		retry := func(ctx context.Context) error {
+			return retryPolicy.Retry(ctx, logger, func(attempt int) error {
+				attempts = append(attempts, attempt)
+				if len(errs) == 0 {
+					return nil
+				}
+				err := errs[0]
+				errs = errs[1:]
+				return err
+			})
+		}
+
+		It("does not retry when the first attempt succeeds", func() {
+			Expect(retry(context.Background())).To(Succeed())
+			Expect(attempts).To(Equal([]int{1}))
+		})
+
+		It("retries retryable errors until the attempt succeeds", func() {
+			errs = []error{syscall.ECONNRESET, docker.ErrTooManyRequests}
+			Expect(retry(context.Background())).To(Succeed())
+			Expect(attempts).To(Equal([]int{1, 2, 3}))
+		})
+
+		It("logs the backoff", func() {
+			errs = []error{syscall.ECONNRESET}
+			Expect(retry(context.Background())).To(Succeed())
+			Expect(logger.TestSink.LogMessages()).To(ContainElement("retry-policy.backing-off"))
+		})
+
+		It("returns the last error once the attempts are used up", func() {
+			errs = []error{syscall.ECONNRESET, syscall.ECONNRESET, fmt.Errorf("still failing: %w", syscall.ECONNRESET)}
+			Expect(retry(context.Background())).To(MatchError(ContainSubstring("still failing")))
+			Expect(attempts).To(Equal([]int{1, 2, 3}))
+		})
+
+		It("does not retry errors that are not retryable", func() {
+			errs = []error{docker.UnexpectedHTTPStatusError{StatusCode: http.StatusNotFound}}
+			Expect(retry(context.Background())).To(HaveOccurred())
+			Expect(attempts).To(Equal([]int{1}))
+		})
+
+		Context("when the context is cancelled while backing off", func() {
+			BeforeEach(func() {
+				retryPolicy.InitialBackoff = time.Hour
+				retryPolicy.MaxBackoff = time.Hour
+			})
+
+			It("returns the last error", func() {
+				ctx, cancel := context.WithCancel(context.Background())
+				errs = []error{syscall.ECONNRESET}
+				go func() {
+					defer GinkgoRecover()
+					Eventually(logger.TestSink.LogMessages).Should(ContainElement("retry-policy.backing-off"))
+					cancel()
+				}()
+
+				Expect(retry(ctx)).To(MatchError(syscall.ECONNRESET))
+				Expect(attempts).To(Equal([]int{1}))
+			})
+		})
+
+		Context("when the number of attempts is not positive", func() {
+			BeforeEach(func() {
+				retryPolicy.Attempts = 0
+			})
+
+			It("makes a single attempt", func() {
+				errs = []error{syscall.ECONNRESET}
+				Expect(retry(context.Background())).To(HaveOccurred())
+				Expect(attempts).To(Equal([]int{1}))
+			})
+		})
+	})
+
+	Describe("Backoff", func() {
+		It("doubles the backoff with every attempt", func() {
+			Expect(retryPolicy.Backoff(1)).To(Equal(time.Millisecond))
+			Expect(retryPolicy.Backoff(2)).To(Equal(2 * time.Millisecond))
+			Expect(retryPolicy.Backoff(3)).To(Equal(4 * time.Millisecond))
+		})
+
+		It("does not exceed the maximum backoff", func() {
+			Expect(retryPolicy.Backoff(5)).To(Equal(10 * time.Millisecond))
+			Expect(retryPolicy.Backoff(100)).To(Equal(10 * time.Millisecond))
+		})
+
+		Context("when jitter is set", func() {
+			BeforeEach(func() {
+				retryPolicy.Jitter = 0.5
+			})
+
+			It("randomises part of the backoff", func() {
+				for i := 0; i < 100; i++ {
+					backoff := retryPolicy.Backoff(3)
+					Expect(backoff).To(BeNumerically(">", 2*time.Millisecond))
+					Expect(backoff).To(BeNumerically("<=", 4*time.Millisecond))
+				}
+			})
+		})
+	})
+
+	Describe("IsRetryable", func() {
+		DescribeTable("classifies errors",
+			func(err error, retryable bool) {
+				Expect(retryPolicy.IsRetryable(err)).To(Equal(retryable))
+			},
+			Entry("5xx responses", docker.UnexpectedHTTPStatusError{StatusCode: http.StatusBadGateway}, true),
+			Entry("429 responses", docker.ErrTooManyRequests, true),
+			Entry("wrapped 429 responses", errorspkg.Wrap(docker.ErrTooManyRequests, "fetching blob"), true),
+			Entry("registry errors with a 5xx status", errcode.ErrorCodeUnavailable.WithMessage("down"), true),
+			Entry("connection resets", fmt.Errorf("reading blob: %w", syscall.ECONNRESET), true),
+			Entry("unexpected EOFs", io.ErrUnexpectedEOF, true),
+			Entry("401 responses", docker.ErrUnauthorizedForCredentials{Err: errors.New("denied")}, false),
+			Entry("404 responses", docker.UnexpectedHTTPStatusError{StatusCode: http.StatusNotFound}, false),
+			Entry("registry errors with a 4xx status", errcode.ErrorCodeDenied.WithMessage("denied"), false),
+			Entry("cancelled contexts", context.Canceled, false),
+			Entry("other errors", errors.New("invalid reference"), false),
+		)
+
+		Context("when the retryable status codes are set", func() {
+			BeforeEach(func() {
+				retryPolicy.RetryableStatusCodes = []int{http.StatusTooManyRequests}
+			})
+
+			It("only retries those status codes", func() {
+				Expect(retryPolicy.IsRetryable(docker.ErrTooManyRequests)).To(BeTrue())
+				Expect(retryPolicy.IsRetryable(docker.UnexpectedHTTPStatusError{StatusCode: http.StatusBadGateway})).To(BeFalse())
+			})
+
+			It("still retries network errors", func() {
+				Expect(retryPolicy.IsRetryable(syscall.ECONNRESET)).To(BeTrue())
+			})
+		})
+	})
+})
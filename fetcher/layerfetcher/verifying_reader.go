@@ -0,0 +1,43 @@
+package layerfetcher
+
+import "io"
+
+// VerifyingReader runs Verify once the delegate reader is exhausted and returns
+// its error instead of io.EOF, so that a consumer never sees a successfully
+// completed stream whose contents are invalid.
+type VerifyingReader struct {
+	DelegateReader io.ReadCloser
+	Verify         func() error
+
+	verified  bool
+	verifyErr error
+}
+
+func NewVerifyingReader(delegateReader io.ReadCloser, verify func() error) *VerifyingReader {
+	return &VerifyingReader{
+		DelegateReader: delegateReader,
+		Verify:         verify,
+	}
+}
+
+func (v *VerifyingReader) Read(p []byte) (int, error) {
+	n, err := v.DelegateReader.Read(p)
+	if err != io.EOF {
+		return n, err
+	}
+
+	if !v.verified {
+		v.verified = true
+		v.verifyErr = v.Verify()
+	}
+
+	if v.verifyErr != nil {
+		return n, v.verifyErr
+	}
+
+	return n, io.EOF
+}
+
+func (v *VerifyingReader) Close() error {
+	return v.DelegateReader.Close()
+}
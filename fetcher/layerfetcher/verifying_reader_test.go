@@ -0,0 +1,75 @@
+package layerfetcher_test
+
+import (
+	"errors"
+	"io"
+	"strings"
+
+	"code.cloudfoundry.org/groot/fetcher/layerfetcher"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("VerifyingReader", func() {
+	var (
+		verifyCallCount int
+		verifyErr       error
+		vr              *layerfetcher.VerifyingReader
+	)
+
+	BeforeEach(func() {
+		verifyCallCount = 0
+		verifyErr = nil
+	})
+
+	JustBeforeEach(func() {
+		vr = layerfetcher.NewVerifyingReader(io.NopCloser(strings.NewReader("hello")), func() error {
+			verifyCallCount++
+			return verifyErr
+		})
+	})
+
+	Describe("Read", func() {
+		It("reads all the data", func() {
+			Expect(io.ReadAll(vr)).To(Equal([]byte("hello")))
+		})
+
+		It("does not verify before the data is exhausted", func() {
+			_, err := vr.Read(make([]byte, 2))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(verifyCallCount).To(BeZero())
+		})
+
+		It("verifies the data once it is exhausted", func() {
+			_, err := io.ReadAll(vr)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(verifyCallCount).To(Equal(1))
+		})
+
+		It("only verifies once", func() {
+			_, err := io.ReadAll(vr)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = vr.Read(make([]byte, 2))
+			Expect(err).To(Equal(io.EOF))
+			Expect(verifyCallCount).To(Equal(1))
+		})
+
+		Context("when the verification fails", func() {
+			BeforeEach(func() {
+				verifyErr = errors.New("digest mismatch")
+			})
+
+			It("returns the verification error instead of EOF", func() {
+				_, err := io.ReadAll(vr)
+				Expect(err).To(MatchError("digest mismatch"))
+			})
+
+			It("keeps returning the verification error", func() {
+				_, _ = io.ReadAll(vr)
+				_, err := vr.Read(make([]byte, 2))
+				Expect(err).To(MatchError("digest mismatch"))
+			})
+		})
+	})
+})
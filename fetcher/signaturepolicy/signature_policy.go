@@ -0,0 +1,26 @@
+// Package signaturepolicy enforces a containers/image signature policy, as
+// described in containers-policy.json(5), on the images groot pulls.
+//
+// Verifying signatures needs an OpenPGP implementation. groot uses the pure Go
+// one, so the policy is only enforced when groot is built with the
+// containers_image_openpgp build tag; otherwise New always fails.
+package signaturepolicy // import "code.cloudfoundry.org/groot/fetcher/signaturepolicy"
+
+import (
+	"fmt"
+)
+
+// VerificationError is returned when an image is rejected by the policy, for
+// example because it is not signed by a trusted key
+type VerificationError struct {
+	Image string
+	Err   error
+}
+
+func (e *VerificationError) Error() string {
+	return fmt.Sprintf("image `%s` rejected by signature policy: %s", e.Image, e.Err)
+}
+
+func (e *VerificationError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,13 @@
+package signaturepolicy_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestSignaturePolicy(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Signature Policy Suite")
+}
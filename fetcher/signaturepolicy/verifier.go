@@ -0,0 +1,54 @@
+//go:build containers_image_openpgp
+
+package signaturepolicy
+
+import (
+	"context"
+
+	"github.com/containers/image/v5/signature"
+	"github.com/containers/image/v5/transports"
+	"github.com/containers/image/v5/types"
+	"github.com/pkg/errors"
+)
+
+// Supported is whether this build can verify signatures, which needs the
+// containers_image_openpgp build tag
+const Supported = true
+
+// Verifier checks images against the policy it was created with
+type Verifier struct {
+	policyContext *signature.PolicyContext
+}
+
+// New reads the policy at policyPath
+func New(policyPath string) (*Verifier, error) {
+	policy, err := signature.NewPolicyFromFile(policyPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading signature policy")
+	}
+
+	policyContext, err := signature.NewPolicyContext(policy)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating signature policy context")
+	}
+
+	return &Verifier{policyContext: policyContext}, nil
+}
+
+// Verify returns a VerificationError unless the policy allows the image. Any
+// signatures the policy requires are fetched from the source of the image.
+func (v *Verifier) Verify(ctx context.Context, image types.UnparsedImage) error {
+	allowed, err := v.policyContext.IsRunningImageAllowed(ctx, image)
+	if allowed && err == nil {
+		return nil
+	}
+
+	if err == nil {
+		err = errors.New("not allowed")
+	}
+	return &VerificationError{Image: transports.ImageName(image.Reference()), Err: err}
+}
+
+func (v *Verifier) Close() error {
+	return v.policyContext.Destroy()
+}
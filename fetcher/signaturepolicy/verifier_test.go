@@ -0,0 +1,107 @@
+//go:build containers_image_openpgp
+
+package signaturepolicy_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/groot/fetcher/signaturepolicy"
+	"github.com/containers/image/v5/image"
+	"github.com/containers/image/v5/oci/layout"
+	"github.com/containers/image/v5/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Verifier", func() {
+	var (
+		policyDir   string
+		policyPath  string
+		imageSource types.ImageSource
+		verifier    *signaturepolicy.Verifier
+		verifyErr   error
+	)
+
+	BeforeEach(func() {
+		var err error
+		policyDir, err = os.MkdirTemp("", "signature-policy")
+		Expect(err).NotTo(HaveOccurred())
+		policyPath = filepath.Join(policyDir, "policy.json")
+
+		workDir, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		ref, err := layout.NewReference(filepath.Join(workDir, "../../integration/oci-test-images/opq-whiteouts-busybox"), "latest")
+		Expect(err).NotTo(HaveOccurred())
+		imageSource, err = ref.NewImageSource(context.Background(), &types.SystemContext{})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	JustBeforeEach(func() {
+		var err error
+		verifier, err = signaturepolicy.New(policyPath)
+		Expect(err).NotTo(HaveOccurred())
+
+		verifyErr = verifier.Verify(context.Background(), image.UnparsedInstance(imageSource, nil))
+	})
+
+	AfterEach(func() {
+		Expect(verifier.Close()).To(Succeed())
+		Expect(imageSource.Close()).To(Succeed())
+		Expect(os.RemoveAll(policyDir)).To(Succeed())
+	})
+
+	Context("when the policy accepts anything", func() {
+		BeforeEach(func() {
+			writePolicy(policyPath, `{"default": [{"type": "insecureAcceptAnything"}]}`)
+		})
+
+		It("allows the image", func() {
+			Expect(verifyErr).NotTo(HaveOccurred())
+		})
+	})
+
+	Context("when the policy rejects the transport of the image", func() {
+		BeforeEach(func() {
+			writePolicy(policyPath, `{
+				"default": [{"type": "insecureAcceptAnything"}],
+				"transports": {"oci": {"": [{"type": "reject"}]}}
+			}`)
+		})
+
+		It("returns a VerificationError", func() {
+			var verificationErr *signaturepolicy.VerificationError
+			Expect(errors.As(verifyErr, &verificationErr)).To(BeTrue())
+			Expect(verificationErr.Image).To(HavePrefix("oci:"))
+			Expect(verifyErr).To(MatchError(ContainSubstring("rejected by signature policy")))
+		})
+	})
+
+	Context("when the policy requires a signature the image does not have", func() {
+		BeforeEach(func() {
+			keyPath := filepath.Join(policyDir, "key.gpg")
+			Expect(os.WriteFile(keyPath, []byte("not-a-key"), 0600)).To(Succeed())
+			writePolicy(policyPath, `{"default": [{"type": "signedBy", "keyType": "GPGKeys", "keyPath": "`+keyPath+`"}]}`)
+		})
+
+		It("returns a VerificationError", func() {
+			var verificationErr *signaturepolicy.VerificationError
+			Expect(errors.As(verifyErr, &verificationErr)).To(BeTrue())
+		})
+	})
+})
+
+var _ = Describe("New", func() {
+	Context("when the policy file does not exist", func() {
+		It("returns an error", func() {
+			_, err := signaturepolicy.New("/does/not/exist/policy.json")
+			Expect(err).To(MatchError(ContainSubstring("reading signature policy")))
+		})
+	})
+})
+
+func writePolicy(path, policy string) {
+	ExpectWithOffset(1, os.WriteFile(path, []byte(policy), 0600)).To(Succeed())
+}
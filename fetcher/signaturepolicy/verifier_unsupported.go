@@ -0,0 +1,30 @@
+//go:build !containers_image_openpgp
+
+package signaturepolicy
+
+import (
+	"context"
+
+	"github.com/containers/image/v5/types"
+	"github.com/pkg/errors"
+)
+
+// Supported is whether this build can verify signatures, which needs the
+// containers_image_openpgp build tag
+const Supported = false
+
+// Verifier checks images against the policy it was created with
+type Verifier struct{}
+
+// New fails, as this build of groot cannot verify signatures
+func New(policyPath string) (*Verifier, error) {
+	return nil, errors.New("signature verification is not supported by this build, groot needs to be built with the containers_image_openpgp build tag")
+}
+
+func (v *Verifier) Verify(ctx context.Context, image types.UnparsedImage) error {
+	return errors.New("signature verification is not supported by this build")
+}
+
+func (v *Verifier) Close() error {
+	return nil
+}
@@ -0,0 +1,16 @@
+//go:build !containers_image_openpgp
+
+package signaturepolicy_test
+
+import (
+	"code.cloudfoundry.org/groot/fetcher/signaturepolicy"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("New", func() {
+	It("returns an error, as signatures cannot be verified without the containers_image_openpgp build tag", func() {
+		_, err := signaturepolicy.New("policy.json")
+		Expect(err).To(MatchError(ContainSubstring("containers_image_openpgp")))
+	})
+})
@@ -0,0 +1,147 @@
+package groot
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+	"github.com/pkg/errors"
+)
+
+// GCResult lists the layers that were collected, or that would have been in a
+// dry run
+type GCResult struct {
+	Layers         []string `json:"layers"`
+	ReclaimedBytes int64    `json:"reclaimed_bytes"`
+	DryRun         bool     `json:"dry_run"`
+}
+
+// layerCollector is a Driver that supports garbage collection
+type layerCollector interface {
+	BundleLister
+	LayerLister
+	BundleLayerLister
+	LayerDeleter
+}
+
+// GC deletes the layers that no bundle references. Layers used within
+// gracePeriod are kept, as they may belong to a bundle that is still being
+// created, and so are the parents of every layer that is kept. Layers that a
+// create finds to exist already are only kept when the driver updates their
+// LastUsed time in LayerExists. Nothing is
+// collected while the layers take up no more than thresholdBytes.
+func (g *Groot) GC(ctx context.Context, gracePeriod time.Duration, thresholdBytes int64, dryRun bool) (GCResult, error) {
+	g.Logger = g.Logger.Session("gc", lager.Data{"gracePeriod": gracePeriod, "thresholdBytes": thresholdBytes, "dryRun": dryRun})
+	g.Logger.Debug("starting")
+	defer g.Logger.Debug("ending")
+
+	result := GCResult{Layers: []string{}, DryRun: dryRun}
+
+	collector, ok := g.Driver.(layerCollector)
+	if !ok {
+		return GCResult{}, driverFailure(errors.New("driver does not support garbage collection"))
+	}
+
+	layers, err := collector.ListLayers(g.Logger)
+	if err != nil {
+		return GCResult{}, driverFailure(errors.Wrap(err, "listing layers"))
+	}
+
+	var totalSize int64
+	for _, layer := range layers {
+		totalSize += layer.Size
+	}
+	if totalSize <= thresholdBytes {
+		g.Logger.Info("below-threshold", lager.Data{"totalSize": totalSize})
+		return result, nil
+	}
+
+	referenced, err := g.referencedLayers(ctx, collector)
+	if err != nil {
+		return GCResult{}, err
+	}
+
+	for _, layer := range unusedLayers(layers, referenced, time.Now().Add(-gracePeriod)) {
+		if err := ctx.Err(); err != nil {
+			return GCResult{}, err
+		}
+
+		if !dryRun {
+			if err := collector.DeleteLayer(g.Logger, layer.ID); err != nil {
+				return GCResult{}, driverFailure(errors.Wrapf(err, "deleting layer `%s`", layer.ID))
+			}
+		}
+		g.Logger.Info("collected-layer", lager.Data{"layerID": layer.ID, "size": layer.Size})
+
+		result.Layers = append(result.Layers, layer.ID)
+		result.ReclaimedBytes += layer.Size
+	}
+
+	return result, nil
+}
+
+func (g *Groot) referencedLayers(ctx context.Context, collector layerCollector) (map[string]bool, error) {
+	bundles, err := collector.ListBundles(g.Logger)
+	if err != nil {
+		return nil, driverFailure(errors.Wrap(err, "listing bundles"))
+	}
+
+	referenced := map[string]bool{}
+	for _, bundle := range bundles {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		layerIDs, err := collector.BundleLayerIDs(g.Logger, bundle.Handle)
+		if err != nil {
+			return nil, driverFailure(errors.Wrapf(err, "listing layers of bundle `%s`", bundle.Handle))
+		}
+		for _, layerID := range layerIDs {
+			referenced[layerID] = true
+		}
+	}
+
+	return referenced, nil
+}
+
+// unusedLayers returns the layers that can be deleted, children before their
+// parents. A layer is kept when a bundle references it, when it was used after
+// usedSince, or when one of its children is kept.
+func unusedLayers(layers []Layer, referenced map[string]bool, usedSince time.Time) []Layer {
+	layersByID := map[string]Layer{}
+	for _, layer := range layers {
+		layersByID[layer.ID] = layer
+	}
+
+	kept := map[string]bool{}
+	for _, layer := range layers {
+		if !referenced[layer.ID] && layer.LastUsed.Before(usedSince) {
+			continue
+		}
+
+		for id := layer.ID; id != "" && !kept[id]; id = layersByID[id].ParentID {
+			kept[id] = true
+		}
+	}
+
+	depth := func(layer Layer) int {
+		d := 0
+		for id := layer.ParentID; id != ""; id = layersByID[id].ParentID {
+			d++
+		}
+		return d
+	}
+
+	unused := []Layer{}
+	for _, layer := range layers {
+		if !kept[layer.ID] {
+			unused = append(unused, layer)
+		}
+	}
+	sort.SliceStable(unused, func(i, j int) bool {
+		return depth(unused[i]) > depth(unused[j])
+	})
+
+	return unused
+}
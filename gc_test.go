@@ -0,0 +1,224 @@
+package groot_test
+
+import (
+	"context"
+	"time"
+
+	"code.cloudfoundry.org/groot"
+	"code.cloudfoundry.org/groot/grootfakes"
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	errors "github.com/pkg/errors"
+)
+
+var _ = Describe("GC", func() {
+	var (
+		driver            *grootfakes.FakeDriver
+		bundleLister      *grootfakes.FakeBundleLister
+		layerLister       *grootfakes.FakeLayerLister
+		bundleLayerLister *grootfakes.FakeBundleLayerLister
+		layerDeleter      *grootfakes.FakeLayerDeleter
+
+		logger *lagertest.TestLogger
+		g      *groot.Groot
+
+		gracePeriod    time.Duration
+		thresholdBytes int64
+		dryRun         bool
+
+		result groot.GCResult
+		gcErr  error
+	)
+
+	longAgo := time.Now().Add(-24 * time.Hour)
+
+	deletedLayers := func() []string {
+		layerIDs := []string{}
+		for i := 0; i < layerDeleter.DeleteLayerCallCount(); i++ {
+			_, layerID := layerDeleter.DeleteLayerArgsForCall(i)
+			layerIDs = append(layerIDs, layerID)
+		}
+		return layerIDs
+	}
+
+	BeforeEach(func() {
+		driver = new(grootfakes.FakeDriver)
+		bundleLister = new(grootfakes.FakeBundleLister)
+		layerLister = new(grootfakes.FakeLayerLister)
+		bundleLayerLister = new(grootfakes.FakeBundleLayerLister)
+		layerDeleter = new(grootfakes.FakeLayerDeleter)
+
+		// base <- used <- used-child
+		//      <- unused <- unused-child
+		layerLister.ListLayersReturns([]groot.Layer{
+			{ID: "base", Size: 1, LastUsed: longAgo},
+			{ID: "used", ParentID: "base", Size: 10, LastUsed: longAgo},
+			{ID: "used-child", ParentID: "used", Size: 100, LastUsed: longAgo},
+			{ID: "unused", ParentID: "base", Size: 1000, LastUsed: longAgo},
+			{ID: "unused-child", ParentID: "unused", Size: 10000, LastUsed: longAgo},
+		}, nil)
+		bundleLister.ListBundlesReturns([]groot.Bundle{{Handle: "some-handle"}}, nil)
+		bundleLayerLister.BundleLayerIDsReturns([]string{"base", "used", "used-child"}, nil)
+
+		logger = lagertest.NewTestLogger("groot")
+		g = &groot.Groot{
+			Driver: &collectingDriver{
+				FakeDriver:            driver,
+				FakeBundleLister:      bundleLister,
+				FakeLayerLister:       layerLister,
+				FakeBundleLayerLister: bundleLayerLister,
+				FakeLayerDeleter:      layerDeleter,
+			},
+			Logger: logger,
+		}
+
+		gracePeriod = 0
+		thresholdBytes = 0
+		dryRun = false
+	})
+
+	JustBeforeEach(func() {
+		result, gcErr = g.GC(context.Background(), gracePeriod, thresholdBytes, dryRun)
+	})
+
+	It("deletes the layers that no bundle references, children first", func() {
+		Expect(gcErr).NotTo(HaveOccurred())
+		Expect(deletedLayers()).To(Equal([]string{"unused-child", "unused"}))
+	})
+
+	It("returns the collected layers", func() {
+		Expect(result).To(Equal(groot.GCResult{
+			Layers:         []string{"unused-child", "unused"},
+			ReclaimedBytes: 11000,
+		}))
+	})
+
+	It("lists the layers of every bundle", func() {
+		Expect(bundleLayerLister.BundleLayerIDsCallCount()).To(Equal(1))
+		_, handle := bundleLayerLister.BundleLayerIDsArgsForCall(0)
+		Expect(handle).To(Equal("some-handle"))
+	})
+
+	Context("when it is a dry run", func() {
+		BeforeEach(func() {
+			dryRun = true
+		})
+
+		It("does not delete any layer", func() {
+			Expect(gcErr).NotTo(HaveOccurred())
+			Expect(layerDeleter.DeleteLayerCallCount()).To(BeZero())
+		})
+
+		It("returns the layers that would be collected", func() {
+			Expect(result).To(Equal(groot.GCResult{
+				Layers:         []string{"unused-child", "unused"},
+				ReclaimedBytes: 11000,
+				DryRun:         true,
+			}))
+		})
+	})
+
+	Context("when a layer was used within the grace period", func() {
+		BeforeEach(func() {
+			gracePeriod = time.Hour
+			layerLister.ListLayersReturns([]groot.Layer{
+				{ID: "base", Size: 1, LastUsed: longAgo},
+				{ID: "unused", ParentID: "base", Size: 1000, LastUsed: longAgo},
+				{ID: "unused-child", ParentID: "unused", Size: 10000, LastUsed: time.Now()},
+			}, nil)
+		})
+
+		It("keeps it and its parents", func() {
+			Expect(gcErr).NotTo(HaveOccurred())
+			Expect(deletedLayers()).To(BeEmpty())
+		})
+	})
+
+	Context("when the layers take up no more than the threshold", func() {
+		BeforeEach(func() {
+			thresholdBytes = 11111
+		})
+
+		It("does not collect anything", func() {
+			Expect(gcErr).NotTo(HaveOccurred())
+			Expect(bundleLister.ListBundlesCallCount()).To(BeZero())
+			Expect(result.Layers).To(BeEmpty())
+		})
+	})
+
+	Context("when the layers take up more than the threshold", func() {
+		BeforeEach(func() {
+			thresholdBytes = 11110
+		})
+
+		It("collects the unused layers", func() {
+			Expect(gcErr).NotTo(HaveOccurred())
+			Expect(deletedLayers()).To(Equal([]string{"unused-child", "unused"}))
+		})
+	})
+
+	Context("when listing the layers fails", func() {
+		BeforeEach(func() {
+			layerLister.ListLayersReturns(nil, errors.New("failed"))
+		})
+
+		It("returns the error", func() {
+			Expect(gcErr).To(MatchError("listing layers: failed"))
+		})
+	})
+
+	Context("when listing the bundles fails", func() {
+		BeforeEach(func() {
+			bundleLister.ListBundlesReturns(nil, errors.New("failed"))
+		})
+
+		It("returns the error without deleting anything", func() {
+			Expect(gcErr).To(MatchError("listing bundles: failed"))
+			Expect(layerDeleter.DeleteLayerCallCount()).To(BeZero())
+		})
+	})
+
+	Context("when listing the layers of a bundle fails", func() {
+		BeforeEach(func() {
+			bundleLayerLister.BundleLayerIDsReturns(nil, errors.New("failed"))
+		})
+
+		It("returns the error without deleting anything", func() {
+			Expect(gcErr).To(MatchError("listing layers of bundle `some-handle`: failed"))
+			Expect(layerDeleter.DeleteLayerCallCount()).To(BeZero())
+		})
+	})
+
+	Context("when deleting a layer fails", func() {
+		BeforeEach(func() {
+			layerDeleter.DeleteLayerStub = func(_ lager.Logger, layerID string) error {
+				return errors.New("failed")
+			}
+		})
+
+		It("stops and returns the error", func() {
+			Expect(gcErr).To(MatchError("deleting layer `unused-child`: failed"))
+			Expect(layerDeleter.DeleteLayerCallCount()).To(Equal(1))
+		})
+	})
+
+	Context("when the driver does not support garbage collection", func() {
+		BeforeEach(func() {
+			g.Driver = &listingDriver{FakeDriver: driver, FakeBundleLister: bundleLister}
+		})
+
+		It("returns an error", func() {
+			Expect(gcErr).To(MatchError("driver does not support garbage collection"))
+		})
+	})
+})
+
+type collectingDriver struct {
+	*grootfakes.FakeDriver
+	*grootfakes.FakeBundleLister
+	*grootfakes.FakeLayerLister
+	*grootfakes.FakeBundleLayerLister
+	*grootfakes.FakeLayerDeleter
+}
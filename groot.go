@@ -1,20 +1,32 @@
 package groot
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/url"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
+	"code.cloudfoundry.org/groot/fetcher/blobcache"
 	"code.cloudfoundry.org/groot/fetcher/filefetcher"
 	"code.cloudfoundry.org/groot/fetcher/layerfetcher"
 	"code.cloudfoundry.org/groot/fetcher/layerfetcher/source"
+	"code.cloudfoundry.org/groot/fetcher/signaturepolicy"
 	"code.cloudfoundry.org/groot/imagepuller"
+	"code.cloudfoundry.org/groot/tracing"
 	"code.cloudfoundry.org/lager/v3"
 	"github.com/containers/image/v5/types"
+	imgspec "github.com/opencontainers/image-spec/specs-go/v1"
 	runspec "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/pkg/errors"
 	"github.com/urfave/cli"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
 type DiskUsage struct {
@@ -26,8 +38,30 @@ type VolumeStats struct {
 	DiskUsage DiskUsage `json:"disk_usage"`
 }
 
+// ImageMetadata records which image a bundle was created from. ImageURL is
+// the URL given to create, without any credentials, which may name a mutable
+// tag; ManifestDigest and ConfigDigest identify the exact image that was
+// pulled.
 type ImageMetadata struct {
-	Size int64 `json:"size"`
+	Size           int64            `json:"size"`
+	ImageURL       string           `json:"image_url"`
+	ManifestDigest string           `json:"manifest_digest"`
+	ConfigDigest   string           `json:"config_digest"`
+	ChainIDs       []string         `json:"chain_ids"`
+	Platform       imgspec.Platform `json:"platform"`
+	CreatedAt      time.Time        `json:"created_at"`
+}
+
+// Bundle is a bundle as reported by a BundleLister
+type Bundle struct {
+	Handle        string        `json:"handle"`
+	ImageMetadata ImageMetadata `json:"image_metadata"`
+}
+
+// BundleInfo is what the list command prints for every bundle
+type BundleInfo struct {
+	Bundle
+	Stats VolumeStats `json:"stats"`
 }
 
 type ImageDriver interface {
@@ -37,10 +71,81 @@ type ImageDriver interface {
 	WriteMetadata(logger lager.Logger, bundleID string, imageMetadata ImageMetadata) error
 }
 
+// VolumeDriver unpacks layers. Drivers may stop reading layerTar at the end of
+// the tar archive; when blobs are streamed, the rest of the stream is then read
+// after Unpack returns to verify the blob, and when that fails the layer is
+// deleted through LayerDeleter. Blobs are only streamed to drivers that
+// implement LayerDeleter, and are otherwise verified before they are unpacked.
 type VolumeDriver interface {
 	Unpack(logger lager.Logger, layerID string, parentIDs []string, layerTar io.Reader) (int64, error)
 }
 
+// LayerChecker can optionally be implemented by a Driver. When it is, layers
+// that already exist are neither downloaded nor unpacked again, and the
+// returned size is accounted for in the image size. Drivers that also support
+// garbage collection must update the LastUsed time of a layer when they report
+// that it exists, as the layer is not referenced by the bundle being created
+// until Bundle is called, and would otherwise be collected in the meantime.
+type LayerChecker = imagepuller.LayerChecker
+
+// BundleLister can optionally be implemented by a Driver to enumerate the
+// bundles it holds, along with the metadata written for them.
+//
+//go:generate counterfeiter . BundleLister
+type BundleLister interface {
+	ListBundles(logger lager.Logger) ([]Bundle, error)
+}
+
+// MetadataReader can optionally be implemented by a Driver to read back the
+// metadata written for a bundle
+//
+//go:generate counterfeiter . MetadataReader
+type MetadataReader interface {
+	ReadMetadata(logger lager.Logger, bundleID string) (ImageMetadata, error)
+}
+
+// BundleFileReader can optionally be implemented by a Driver to read files
+// from the root filesystem of a bundle. It is used to resolve the user of the
+// image config through the /etc/passwd and /etc/group files of the image, and
+// should return an error satisfying os.IsNotExist for files that do not exist.
+// Without it, creating a bundle from an image whose user is a name fails.
+//
+//go:generate counterfeiter . BundleFileReader
+type BundleFileReader interface {
+	ReadBundleFile(logger lager.Logger, bundleID, path string) (io.ReadCloser, error)
+}
+
+// Layer is a layer as reported by a LayerLister. ParentID is the ID of the
+// layer it was unpacked on top of, if any. LastUsed is when the layer was last
+// unpacked, bundled, or reported to exist.
+type Layer struct {
+	ID       string
+	ParentID string
+	Size     int64
+	LastUsed time.Time
+}
+
+// LayerLister, BundleLayerLister and LayerDeleter can optionally be
+// implemented by a Driver, along with BundleLister, to have its unused layers
+// garbage collected.
+//
+//go:generate counterfeiter . LayerLister
+type LayerLister interface {
+	ListLayers(logger lager.Logger) ([]Layer, error)
+}
+
+// BundleLayerLister returns the layer IDs a bundle was created from
+//
+//go:generate counterfeiter . BundleLayerLister
+type BundleLayerLister interface {
+	BundleLayerIDs(logger lager.Logger, bundleID string) ([]string, error)
+}
+
+//go:generate counterfeiter . LayerDeleter
+type LayerDeleter interface {
+	DeleteLayer(logger lager.Logger, layerID string) error
+}
+
 // Driver should implement the filesystem interaction
 //
 //go:generate counterfeiter . Driver
@@ -55,7 +160,8 @@ type Driver interface {
 //
 //go:generate counterfeiter . ImagePuller
 type ImagePuller interface {
-	Pull(logger lager.Logger, spec imagepuller.ImageSpec) (imagepuller.Image, error)
+	Pull(ctx context.Context, logger lager.Logger, spec imagepuller.ImageSpec) (imagepuller.Image, error)
+	ImageInfo(ctx context.Context, logger lager.Logger) (imagepuller.ImageInfo, error)
 }
 
 type Groot struct {
@@ -64,10 +170,16 @@ type Groot struct {
 	ImagePuller ImagePuller
 }
 
+// DockerConfig holds the registry settings and credentials given on the
+// command line. IdentityToken is an OAuth2 refresh token, exchanged for access
+// tokens by the registry's token service, and RegistryToken is a bearer token
+// sent to the registry as it is.
 type DockerConfig struct {
 	InsecureRegistries []string
 	Username           string
 	Password           string
+	IdentityToken      string
+	RegistryToken      string
 }
 
 func Run(driver Driver, argv []string, driverFlags []cli.Flag, version string) {
@@ -77,6 +189,18 @@ func Run(driver Driver, argv []string, driverFlags []cli.Flag, version string) {
 	var err error
 	var fetcher imagepuller.Fetcher
 	var conf config
+	var jsonErrors bool
+	var progress imagepuller.ProgressReporter
+	var progressStream io.WriteCloser
+	var tracerProvider *sdktrace.TracerProvider
+	var logFile io.Closer
+
+	// Garden may give up on a slow invocation, in which case in-flight registry
+	// requests are cancelled so that temporary blobs get cleaned up
+	signalCtx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+	// Spans continue the trace of the Garden operation that invoked groot
+	signalCtx = tracing.ContextFromEnvironment(signalCtx)
 
 	app := cli.NewApp()
 	app.Version = version
@@ -87,6 +211,10 @@ func Run(driver Driver, argv []string, driverFlags []cli.Flag, version string) {
 			Value: "",
 			Usage: "Path to config file",
 		},
+		cli.BoolFlag{
+			Name:  "json-errors",
+			Usage: "Also print errors to stderr as JSON, with their kind and exit code",
+		},
 	}, driverFlags...)
 	app.Commands = []cli.Command{
 		{
@@ -108,27 +236,50 @@ func Run(driver Driver, argv []string, driverFlags []cli.Flag, version string) {
 					Name:  "password",
 					Usage: "Password to authenticate in image registry",
 				},
+				cli.StringFlag{
+					Name:  "password-file",
+					Usage: "Path to a file holding the password to authenticate in image registry",
+				},
+				cli.BoolFlag{
+					Name:  "credentials-stdin",
+					Usage: "Read the credentials to authenticate in image registry from stdin, as JSON with username, password, identity_token or registry_token",
+				},
+				cli.StringFlag{
+					Name:  "platform",
+					Usage: "Platform to pick from multi-architecture images, as os/architecture[/variant]",
+				},
+				cli.BoolFlag{
+					Name:  "offline",
+					Usage: "Only use layers the driver already has, the blob cache and OCI layouts, instead of the network",
+				},
 			},
 			Action: func(ctx *cli.Context) error {
-				dockerConfig := DockerConfig{
-					InsecureRegistries: conf.InsecureRegistries,
-					Username:           ctx.String("username"),
-					Password:           ctx.String("password"),
+				if err := validateArgs(ctx, 2); err != nil {
+					return err
 				}
 
-				if err := validateArgs(ctx, 2); err != nil {
+				var flags fetcherFlags
+				if flags, err = commandLineFetcherFlags(ctx, conf, os.Stdin); err != nil {
 					return err
 				}
 
-				if fetcher, err = createFetcher(ctx.Args()[0], ctx.Bool("exclude-image-from-quota"), ctx.Int64("disk-limit-size-bytes"), dockerConfig); err != nil {
+				handle := ctx.Args()[1]
+				metrics := newPullMetrics(ctx.Args()[0])
+				pullProgress := imagepuller.MultiProgressReporter(progress, metrics)
+
+				pullCtx, cancel := conf.pullContext(signalCtx)
+				defer cancel()
+
+				if fetcher, err = createFetcher(pullCtx, ctx.Args()[0], conf, flags, pullProgress); err != nil {
 					return err
 				}
 				defer fetcher.Close()
-				g.ImagePuller = imagepuller.NewImagePuller(fetcher, driver)
+				g.ImagePuller = imagepuller.NewImagePuller(fetcher, driver, conf.layerDownloadWorkers(), pullProgress)
 
-				handle := ctx.Args()[1]
+				started := time.Now()
 				var runtimeSpec runspec.Spec
-				runtimeSpec, err = g.Create(handle, ctx.Int64("disk-limit-size-bytes"), ctx.Bool("exclude-image-from-quota"))
+				runtimeSpec, err = g.Create(pullCtx, ctx.Args()[0], handle, ctx.Int64("disk-limit-size-bytes"), ctx.Bool("exclude-image-from-quota"))
+				metrics.writeOrLog(g.Logger, conf.Metrics, time.Since(started), err)
 				if err != nil {
 					return err
 				}
@@ -147,23 +298,149 @@ func Run(driver Driver, argv []string, driverFlags []cli.Flag, version string) {
 					Name:  "password",
 					Usage: "Password to authenticate in image registry",
 				},
+				cli.StringFlag{
+					Name:  "password-file",
+					Usage: "Path to a file holding the password to authenticate in image registry",
+				},
+				cli.BoolFlag{
+					Name:  "credentials-stdin",
+					Usage: "Read the credentials to authenticate in image registry from stdin, as JSON with username, password, identity_token or registry_token",
+				},
+				cli.StringFlag{
+					Name:  "platform",
+					Usage: "Platform to pick from multi-architecture images, as os/architecture[/variant]",
+				},
+				cli.BoolFlag{
+					Name:  "offline",
+					Usage: "Only use layers the driver already has, the blob cache and OCI layouts, instead of the network",
+				},
 			},
 			Action: func(ctx *cli.Context) error {
-				dockerConfig := DockerConfig{
-					InsecureRegistries: conf.InsecureRegistries,
-					Username:           ctx.String("username"),
-					Password:           ctx.String("password"),
+				if err := validateArgs(ctx, 1); err != nil {
+					return err
+				}
+
+				var flags fetcherFlags
+				if flags, err = commandLineFetcherFlags(ctx, conf, os.Stdin); err != nil {
+					return err
+				}
+
+				metrics := newPullMetrics(ctx.Args()[0])
+				pullProgress := imagepuller.MultiProgressReporter(progress, metrics)
+
+				pullCtx, cancel := conf.pullContext(signalCtx)
+				defer cancel()
+
+				if fetcher, err = createFetcher(pullCtx, ctx.Args()[0], conf, flags, pullProgress); err != nil {
+					return err
 				}
+				defer fetcher.Close()
+				g.ImagePuller = imagepuller.NewImagePuller(fetcher, driver, conf.layerDownloadWorkers(), pullProgress)
+
+				started := time.Now()
+				err = g.Pull(pullCtx)
+				metrics.writeOrLog(g.Logger, conf.Metrics, time.Since(started), err)
+				return err
+			},
+		},
+		{
+			Name: "inspect",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "username",
+					Usage: "Username to authenticate in image registry",
+				},
+				cli.StringFlag{
+					Name:  "password",
+					Usage: "Password to authenticate in image registry",
+				},
+				cli.StringFlag{
+					Name:  "password-file",
+					Usage: "Path to a file holding the password to authenticate in image registry",
+				},
+				cli.BoolFlag{
+					Name:  "credentials-stdin",
+					Usage: "Read the credentials to authenticate in image registry from stdin, as JSON with username, password, identity_token or registry_token",
+				},
+				cli.StringFlag{
+					Name:  "platform",
+					Usage: "Platform to pick from multi-architecture images, as os/architecture[/variant]",
+				},
+				cli.BoolFlag{
+					Name:  "offline",
+					Usage: "Only use the blob cache and OCI layouts, instead of the network",
+				},
+			},
+			Action: func(ctx *cli.Context) error {
 				if err := validateArgs(ctx, 1); err != nil {
 					return err
 				}
 
-				if fetcher, err = createFetcher(ctx.Args()[0], ctx.Bool("exclude-image-from-quota"), ctx.Int64("disk-limit-size-bytes"), dockerConfig); err != nil {
+				var flags fetcherFlags
+				if flags, err = commandLineFetcherFlags(ctx, conf, os.Stdin); err != nil {
+					return err
+				}
+
+				pullCtx, cancel := conf.pullContext(signalCtx)
+				defer cancel()
+
+				if fetcher, err = createFetcher(pullCtx, ctx.Args()[0], conf, flags, progress); err != nil {
 					return err
 				}
 				defer fetcher.Close()
-				g.ImagePuller = imagepuller.NewImagePuller(fetcher, driver)
-				return g.Pull()
+				g.ImagePuller = imagepuller.NewImagePuller(fetcher, driver, conf.layerDownloadWorkers(), progress)
+
+				inspection, err := g.Inspect(pullCtx)
+				if err != nil {
+					return err
+				}
+				return json.NewEncoder(os.Stdout).Encode(inspection)
+			},
+		},
+		{
+			Name: "list",
+			Action: func(ctx *cli.Context) error {
+				if err := validateArgs(ctx, 0); err != nil {
+					return err
+				}
+				bundles, err := g.List(signalCtx)
+				if err != nil {
+					return err
+				}
+				return json.NewEncoder(os.Stdout).Encode(bundles)
+			},
+		},
+		{
+			Name: "metadata",
+			Action: func(ctx *cli.Context) error {
+				if err := validateArgs(ctx, 1); err != nil {
+					return err
+				}
+				handle := ctx.Args()[0]
+				metadata, err := g.Metadata(signalCtx, handle)
+				if err != nil {
+					return err
+				}
+				return json.NewEncoder(os.Stdout).Encode(metadata)
+			},
+		},
+		{
+			Name: "gc",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "dry-run",
+					Usage: "Print the layers that would be collected without deleting them",
+				},
+			},
+			Action: func(ctx *cli.Context) error {
+				if err := validateArgs(ctx, 0); err != nil {
+					return err
+				}
+				result, err := g.GC(signalCtx, conf.gcGracePeriod(), conf.GC.ThresholdBytes, ctx.Bool("dry-run"))
+				if err != nil {
+					return err
+				}
+				return json.NewEncoder(os.Stdout).Encode(result)
 			},
 		},
 		{
@@ -173,7 +450,7 @@ func Run(driver Driver, argv []string, driverFlags []cli.Flag, version string) {
 					return err
 				}
 				handle := ctx.Args()[0]
-				return g.Delete(handle)
+				return g.Delete(signalCtx, handle)
 			},
 		},
 		{
@@ -183,7 +460,7 @@ func Run(driver Driver, argv []string, driverFlags []cli.Flag, version string) {
 					return err
 				}
 				handle := ctx.Args()[0]
-				stats, err := g.Stats(handle)
+				stats, err := g.Stats(signalCtx, handle)
 				if err != nil {
 					return err
 				}
@@ -192,17 +469,45 @@ func Run(driver Driver, argv []string, driverFlags []cli.Flag, version string) {
 		},
 	}
 	app.Before = func(ctx *cli.Context) error {
+		jsonErrors = ctx.GlobalBool("json-errors")
+
 		var err error
 		conf, err = parseConfig(ctx.GlobalString("config"))
 		if err != nil {
 			return silentError(err)
 		}
 
-		logger, err := newLogger(conf.LogLevel)
-		if err != nil {
+		var logger lager.Logger
+		if logger, logFile, err = newLogger(conf); err != nil {
 			return err
 		}
 
+		if conf.SignaturePolicyFile != "" && !signaturepolicy.Supported {
+			logger.Error("signature-policy-not-supported", errors.New("groot needs to be built with the containers_image_openpgp build tag to verify signatures, pulls fail until signature_policy_file is unset"))
+		}
+
+		// Streamed blobs are only verified once the driver has unpacked them,
+		// so the driver has to be able to delete the layers of blobs that fail
+		// verification. Otherwise blobs are verified before they are unpacked.
+		if _, ok := driver.(LayerDeleter); conf.StreamBlobs && !ok {
+			logger.Info("stream-blobs-disabled", lager.Data{"reason": "driver cannot delete layers"})
+			conf.StreamBlobs = false
+		}
+
+		if progressStream, err = conf.progressStream(); err != nil {
+			return silentError(err)
+		}
+		if progressStream != nil {
+			progress = imagepuller.NewJSONProgressReporter(progressStream)
+		}
+
+		if tracerProvider, err = conf.Tracing.tracerProvider(logger, version); err != nil {
+			return silentError(err)
+		}
+		if tracerProvider != nil {
+			otel.SetTracerProvider(tracerProvider)
+		}
+
 		g = &Groot{
 			Driver: driver,
 			Logger: logger,
@@ -210,62 +515,142 @@ func Run(driver Driver, argv []string, driverFlags []cli.Flag, version string) {
 		return nil
 	}
 
-	if err := app.Run(argv); err != nil {
+	err = app.Run(argv)
+	if progressStream != nil {
+		_ = progressStream.Close()
+	}
+	if tracerProvider != nil {
+		shutdownTracing(g.Logger, tracerProvider)
+	}
+	if logFile != nil {
+		_ = logFile.Close()
+	}
+
+	if err != nil {
 		if _, ok := err.(SilentError); !ok {
 			fmt.Println(err)
 		}
-		os.Exit(1)
+		if jsonErrors {
+			_ = writeJSONError(os.Stderr, err)
+		}
+		os.Exit(ExitCode(err))
+	}
+}
+
+// fetcherFlags are the command line flags that configure a fetcher in addition
+// to the config file. Commands without quota flags leave them unset
+type fetcherFlags struct {
+	excludeImageFromQuota bool
+	diskLimitSizeBytes    int64
+	dockerConfig          DockerConfig
+	platform              string
+	offline               bool
+}
+
+func commandLineFetcherFlags(ctx *cli.Context, conf config, stdin io.Reader) (fetcherFlags, error) {
+	dockerConfig, err := commandLineDockerConfig(ctx, conf, stdin)
+	if err != nil {
+		return fetcherFlags{}, err
 	}
+
+	return fetcherFlags{
+		excludeImageFromQuota: ctx.Bool("exclude-image-from-quota"),
+		diskLimitSizeBytes:    ctx.Int64("disk-limit-size-bytes"),
+		dockerConfig:          dockerConfig,
+		platform:              ctx.String("platform"),
+		offline:               ctx.Bool("offline"),
+	}, nil
 }
 
-func createFetcher(urlAsString string, excludeImageFromQuota bool, diskLimitSizeBytes int64, dockerConfig DockerConfig) (imagepuller.Fetcher, error) {
+func createFetcher(ctx context.Context, urlAsString string, conf config, flags fetcherFlags, progress imagepuller.ProgressReporter) (imagepuller.Fetcher, error) {
+	platform, err := conf.platform(flags.platform)
+	if err != nil {
+		return nil, err
+	}
+	offline := conf.Offline || flags.offline
+
 	imageURL, err := url.Parse(urlAsString)
 	if err != nil {
 		return nil, err
 	}
 
 	if imageURL.Scheme == "oci" || imageURL.Scheme == "docker" {
-		systemContext := types.SystemContext{}
+		systemContext := types.SystemContext{
+			OSChoice:           platform.OS,
+			ArchitectureChoice: platform.Architecture,
+			VariantChoice:      platform.Variant,
+		}
 
 		if imageURL.Scheme == "docker" {
-			systemContext.DockerInsecureSkipTLSVerify = types.NewOptionalBool(skipTLSValidation(imageURL, dockerConfig.InsecureRegistries))
-			systemContext.DockerAuthConfig = &types.DockerAuthConfig{
-				Username: dockerConfig.Username,
-				Password: dockerConfig.Password,
+			if err := checkImagePolicy(imageURL, conf.ImagePolicy); err != nil {
+				return nil, err
 			}
 		}
 
-		layerSource := source.NewLayerSource(systemContext, false, shouldSkipImageQuotaValidation(excludeImageFromQuota, diskLimitSizeBytes), diskLimitSizeBytes, imageURL)
+		var (
+			mirrors   []source.Mirror
+			blobCache *blobcache.BlobCache
+		)
+		if imageURL.Scheme == "docker" {
+			if blobCache, err = conf.blobCache(); err != nil {
+				return nil, err
+			}
+		}
 
-		return layerfetcher.NewLayerFetcher(&layerSource), nil
-	}
+		// Offline, registries are never contacted, so there is no need to
+		// configure how to connect to them
+		if imageURL.Scheme == "docker" && offline {
+			if blobCache == nil {
+				return nil, errors.New("registry images can only be pulled offline from a blob cache, set blob_cache.path in the config file")
+			}
+		} else if imageURL.Scheme == "docker" {
+			if mirrors, err = registryMirrors(ctx, systemContext, imageURL, conf, flags.dockerConfig); err != nil {
+				return nil, err
+			}
 
-	return filefetcher.NewFileFetcher(imageURL), nil
-}
+			if systemContext, err = registrySystemContext(ctx, systemContext, imageURL, conf, flags.dockerConfig); err != nil {
+				removeCertDirs(certDirs(types.SystemContext{}, mirrors))
+				return nil, err
+			}
+		}
 
-func shouldSkipImageQuotaValidation(excludeImageFromQuota bool, diskLimitSizeBytes int64) bool {
-	return excludeImageFromQuota || diskLimitSizeBytes == 0
-}
+		signatureVerifier, err := conf.signatureVerifier()
+		if err != nil {
+			removeCertDirs(certDirs(systemContext, mirrors))
+			return nil, err
+		}
 
-func newLogger(logLevelStr string) (lager.Logger, error) {
-	logLevels := map[string]lager.LogLevel{
-		"debug": lager.DEBUG,
-		"info":  lager.INFO,
-		"error": lager.ERROR,
-		"fatal": lager.FATAL,
+		layerSource := source.NewLayerSource(source.Options{
+			ImageURL:                 imageURL,
+			SystemContext:            systemContext,
+			DiskLimit:                flags.diskLimitSizeBytes,
+			SkipImageQuotaValidation: shouldSkipImageQuotaValidation(flags.excludeImageFromQuota, flags.diskLimitSizeBytes),
+			RetryPolicy:              conf.retryPolicy(),
+			Mirrors:                  mirrors,
+			BlobCache:                blobCache,
+			Offline:                  offline,
+			SignatureVerifier:        signatureVerifier,
+			Progress:                 progress,
+		})
+		layerFetcher := layerfetcher.NewLayerFetcher(&layerSource, conf.StreamBlobs)
+
+		if dirs := certDirs(systemContext, mirrors); len(dirs) > 0 {
+			return certDirFetcher{LayerFetcher: layerFetcher, certDirs: dirs}, nil
+		}
+		return layerFetcher, nil
 	}
 
-	logLevel, ok := logLevels[logLevelStr]
-	if !ok {
-		return nil, fmt.Errorf("invalid log level: %s", logLevelStr)
+	// Signatures can only be verified for images from registries and OCI
+	// layouts, so the policy cannot be satisfied by any other image
+	if conf.SignaturePolicyFile != "" {
+		return nil, policyRejected(errors.Errorf("image `%s` cannot be verified by the signature policy, only registry and OCI layout images can", urlAsString))
 	}
 
-	sink := lager.NewPrettySink(os.Stderr, logLevel)
-
-	logger := lager.NewLogger("groot")
-	logger.RegisterSink(sink)
+	return filefetcher.NewFileFetcher(imageURL), nil
+}
 
-	return logger, nil
+func shouldSkipImageQuotaValidation(excludeImageFromQuota bool, diskLimitSizeBytes int64) bool {
+	return excludeImageFromQuota || diskLimitSizeBytes == 0
 }
 
 // SilentError silences errors. urfave/cli already prints certain errors, we
@@ -278,6 +663,10 @@ func (e SilentError) Error() string {
 	return e.Underlying.Error()
 }
 
+func (e SilentError) Unwrap() error {
+	return e.Underlying
+}
+
 func silentError(err error) SilentError {
 	return SilentError{Underlying: err}
 }
@@ -294,8 +683,22 @@ func skipTLSValidation(baseImageURL *url.URL, trustedRegistries []string) bool {
 
 func validateArgs(ctx *cli.Context, num int) error {
 	if len(ctx.Args()) != num {
-		return fmt.Errorf("Incorrect number of args. Expect %d, got %d", num, len(ctx.Args()))
+		return invalidArgument(fmt.Errorf("Incorrect number of args. Expect %d, got %d", num, len(ctx.Args())))
 	}
 
 	return nil
 }
+
+// parsePlatform parses platforms in the form os/architecture[/variant]
+func parsePlatform(platform string) (imgspec.Platform, error) {
+	parts := strings.Split(platform, "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return imgspec.Platform{}, invalidArgument(fmt.Errorf("invalid platform %q, expected os/architecture[/variant]", platform))
+	}
+
+	parsed := imgspec.Platform{OS: parts[0], Architecture: parts[1]}
+	if len(parts) == 3 {
+		parsed.Variant = parts[2]
+	}
+	return parsed, nil
+}
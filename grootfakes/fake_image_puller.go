@@ -2,6 +2,7 @@
 package grootfakes
 
 import (
+	"context"
 	"sync"
 
 	"code.cloudfoundry.org/groot"
@@ -10,11 +11,26 @@ import (
 )
 
 type FakeImagePuller struct {
-	PullStub        func(lager.Logger, imagepuller.ImageSpec) (imagepuller.Image, error)
+	ImageInfoStub        func(context.Context, lager.Logger) (imagepuller.ImageInfo, error)
+	imageInfoMutex       sync.RWMutex
+	imageInfoArgsForCall []struct {
+		arg1 context.Context
+		arg2 lager.Logger
+	}
+	imageInfoReturns struct {
+		result1 imagepuller.ImageInfo
+		result2 error
+	}
+	imageInfoReturnsOnCall map[int]struct {
+		result1 imagepuller.ImageInfo
+		result2 error
+	}
+	PullStub        func(context.Context, lager.Logger, imagepuller.ImageSpec) (imagepuller.Image, error)
 	pullMutex       sync.RWMutex
 	pullArgsForCall []struct {
-		arg1 lager.Logger
-		arg2 imagepuller.ImageSpec
+		arg1 context.Context
+		arg2 lager.Logger
+		arg3 imagepuller.ImageSpec
 	}
 	pullReturns struct {
 		result1 imagepuller.Image
@@ -28,19 +44,85 @@ type FakeImagePuller struct {
 	invocationsMutex sync.RWMutex
 }
 
-func (fake *FakeImagePuller) Pull(arg1 lager.Logger, arg2 imagepuller.ImageSpec) (imagepuller.Image, error) {
+func (fake *FakeImagePuller) ImageInfo(arg1 context.Context, arg2 lager.Logger) (imagepuller.ImageInfo, error) {
+	fake.imageInfoMutex.Lock()
+	ret, specificReturn := fake.imageInfoReturnsOnCall[len(fake.imageInfoArgsForCall)]
+	fake.imageInfoArgsForCall = append(fake.imageInfoArgsForCall, struct {
+		arg1 context.Context
+		arg2 lager.Logger
+	}{arg1, arg2})
+	stub := fake.ImageInfoStub
+	fakeReturns := fake.imageInfoReturns
+	fake.recordInvocation("ImageInfo", []interface{}{arg1, arg2})
+	fake.imageInfoMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeImagePuller) ImageInfoCallCount() int {
+	fake.imageInfoMutex.RLock()
+	defer fake.imageInfoMutex.RUnlock()
+	return len(fake.imageInfoArgsForCall)
+}
+
+func (fake *FakeImagePuller) ImageInfoCalls(stub func(context.Context, lager.Logger) (imagepuller.ImageInfo, error)) {
+	fake.imageInfoMutex.Lock()
+	defer fake.imageInfoMutex.Unlock()
+	fake.ImageInfoStub = stub
+}
+
+func (fake *FakeImagePuller) ImageInfoArgsForCall(i int) (context.Context, lager.Logger) {
+	fake.imageInfoMutex.RLock()
+	defer fake.imageInfoMutex.RUnlock()
+	argsForCall := fake.imageInfoArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeImagePuller) ImageInfoReturns(result1 imagepuller.ImageInfo, result2 error) {
+	fake.imageInfoMutex.Lock()
+	defer fake.imageInfoMutex.Unlock()
+	fake.ImageInfoStub = nil
+	fake.imageInfoReturns = struct {
+		result1 imagepuller.ImageInfo
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeImagePuller) ImageInfoReturnsOnCall(i int, result1 imagepuller.ImageInfo, result2 error) {
+	fake.imageInfoMutex.Lock()
+	defer fake.imageInfoMutex.Unlock()
+	fake.ImageInfoStub = nil
+	if fake.imageInfoReturnsOnCall == nil {
+		fake.imageInfoReturnsOnCall = make(map[int]struct {
+			result1 imagepuller.ImageInfo
+			result2 error
+		})
+	}
+	fake.imageInfoReturnsOnCall[i] = struct {
+		result1 imagepuller.ImageInfo
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeImagePuller) Pull(arg1 context.Context, arg2 lager.Logger, arg3 imagepuller.ImageSpec) (imagepuller.Image, error) {
 	fake.pullMutex.Lock()
 	ret, specificReturn := fake.pullReturnsOnCall[len(fake.pullArgsForCall)]
 	fake.pullArgsForCall = append(fake.pullArgsForCall, struct {
-		arg1 lager.Logger
-		arg2 imagepuller.ImageSpec
-	}{arg1, arg2})
+		arg1 context.Context
+		arg2 lager.Logger
+		arg3 imagepuller.ImageSpec
+	}{arg1, arg2, arg3})
 	stub := fake.PullStub
 	fakeReturns := fake.pullReturns
-	fake.recordInvocation("Pull", []interface{}{arg1, arg2})
+	fake.recordInvocation("Pull", []interface{}{arg1, arg2, arg3})
 	fake.pullMutex.Unlock()
 	if stub != nil {
-		return stub(arg1, arg2)
+		return stub(arg1, arg2, arg3)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
@@ -54,17 +136,17 @@ func (fake *FakeImagePuller) PullCallCount() int {
 	return len(fake.pullArgsForCall)
 }
 
-func (fake *FakeImagePuller) PullCalls(stub func(lager.Logger, imagepuller.ImageSpec) (imagepuller.Image, error)) {
+func (fake *FakeImagePuller) PullCalls(stub func(context.Context, lager.Logger, imagepuller.ImageSpec) (imagepuller.Image, error)) {
 	fake.pullMutex.Lock()
 	defer fake.pullMutex.Unlock()
 	fake.PullStub = stub
 }
 
-func (fake *FakeImagePuller) PullArgsForCall(i int) (lager.Logger, imagepuller.ImageSpec) {
+func (fake *FakeImagePuller) PullArgsForCall(i int) (context.Context, lager.Logger, imagepuller.ImageSpec) {
 	fake.pullMutex.RLock()
 	defer fake.pullMutex.RUnlock()
 	argsForCall := fake.pullArgsForCall[i]
-	return argsForCall.arg1, argsForCall.arg2
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
 }
 
 func (fake *FakeImagePuller) PullReturns(result1 imagepuller.Image, result2 error) {
@@ -96,6 +178,8 @@ func (fake *FakeImagePuller) PullReturnsOnCall(i int, result1 imagepuller.Image,
 func (fake *FakeImagePuller) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
+	fake.imageInfoMutex.RLock()
+	defer fake.imageInfoMutex.RUnlock()
 	fake.pullMutex.RLock()
 	defer fake.pullMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
@@ -0,0 +1,160 @@
+package groot
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"code.cloudfoundry.org/lager/v3"
+	"github.com/moby/sys/user"
+	imgspec "github.com/opencontainers/image-spec/specs-go/v1"
+	runspec "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/pkg/errors"
+)
+
+const (
+	// StopSignalAnnotation holds the StopSignal of the image config, as
+	// described by the OCI image spec conversion rules
+	StopSignalAnnotation = "org.opencontainers.image.stopSignal"
+	// VolumesAnnotation holds the sorted Volumes of the image config, as a
+	// JSON array of paths
+	VolumesAnnotation = "org.cloudfoundry.image.volumes"
+
+	passwdPath = "/etc/passwd"
+	groupPath  = "/etc/group"
+)
+
+// applyImageConfig translates the image config into the bundle spec. Labels
+// are added to the annotations first, so that the annotations groot sets take
+// precedence over labels with the same name.
+func (g *Groot) applyImageConfig(logger lager.Logger, bundle *runspec.Spec, handle string, config imgspec.ImageConfig) error {
+	if len(config.Env) > 0 {
+		process(bundle).Env = append(process(bundle).Env, config.Env...)
+	}
+
+	if _, canReadFiles := g.Driver.(BundleFileReader); config.User != "" && !canReadFiles && !numericUser(config.User) {
+		// Names can only be resolved through the files of the bundle, and
+		// leaving the user unset would run the process as root instead
+		return driverFailure(errors.Errorf("resolving user `%s`: names can only be resolved by drivers that implement BundleFileReader", config.User))
+	} else if config.User != "" {
+		execUser, err := g.resolveUser(logger, handle, config.User)
+		if err != nil {
+			return errors.Wrapf(err, "resolving user `%s`", config.User)
+		}
+
+		process(bundle).User = execUser
+	}
+
+	if config.WorkingDir != "" {
+		process(bundle).Cwd = config.WorkingDir
+	}
+
+	if len(config.Entrypoint) > 0 || len(config.Cmd) > 0 {
+		args := append([]string{}, config.Entrypoint...)
+		process(bundle).Args = append(args, config.Cmd...)
+	}
+
+	for key, value := range config.Labels {
+		annotations(bundle)[key] = value
+	}
+
+	if config.StopSignal != "" {
+		annotations(bundle)[StopSignalAnnotation] = config.StopSignal
+	}
+
+	if len(config.Volumes) > 0 {
+		volumes := []string{}
+		for volume := range config.Volumes {
+			volumes = append(volumes, volume)
+		}
+		sort.Strings(volumes)
+
+		encodedVolumes, err := json.Marshal(volumes)
+		if err != nil {
+			return errors.Wrap(err, "encoding volumes")
+		}
+		annotations(bundle)[VolumesAnnotation] = string(encodedVolumes)
+	}
+
+	return nil
+}
+
+// resolveUser looks the user of the image config up in the /etc/passwd and
+// /etc/group files of the bundle. Numeric users and groups are used as they
+// are when the files do not exist or the driver cannot read them.
+func (g *Groot) resolveUser(logger lager.Logger, handle, userSpec string) (runspec.User, error) {
+	passwd, err := g.readBundleFile(logger, handle, passwdPath)
+	if err != nil {
+		return runspec.User{}, err
+	}
+	if passwd != nil {
+		defer passwd.Close()
+	}
+
+	group, err := g.readBundleFile(logger, handle, groupPath)
+	if err != nil {
+		return runspec.User{}, err
+	}
+	if group != nil {
+		defer group.Close()
+	}
+
+	execUser, err := user.GetExecUser(userSpec, nil, passwd, group)
+	if err != nil {
+		return runspec.User{}, err
+	}
+
+	// #nosec - G115 - GetExecUser only returns ids between 0 and MaxInt32
+	runtimeUser := runspec.User{UID: uint32(execUser.Uid), GID: uint32(execUser.Gid)}
+	for _, gid := range execUser.Sgids {
+		// #nosec - G115 - as above
+		runtimeUser.AdditionalGids = append(runtimeUser.AdditionalGids, uint32(gid))
+	}
+
+	return runtimeUser, nil
+}
+
+// numericUser returns whether the user, and the group if any, are ids
+func numericUser(userSpec string) bool {
+	for _, id := range strings.Split(userSpec, ":") {
+		if _, err := strconv.ParseUint(id, 10, 32); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// readBundleFile returns nil when the driver cannot read files from bundles or
+// the file does not exist
+func (g *Groot) readBundleFile(logger lager.Logger, handle, path string) (io.ReadCloser, error) {
+	fileReader, ok := g.Driver.(BundleFileReader)
+	if !ok {
+		return nil, nil
+	}
+
+	file, err := fileReader.ReadBundleFile(logger.Session("read-bundle-file", lager.Data{"path": path}), handle, path)
+	if err != nil {
+		if os.IsNotExist(errors.Cause(err)) {
+			return nil, nil
+		}
+		return nil, driverFailure(errors.Wrapf(err, "reading `%s`", path))
+	}
+	return file, nil
+}
+
+func process(bundle *runspec.Spec) *runspec.Process {
+	if bundle.Process == nil {
+		bundle.Process = &runspec.Process{}
+	}
+	return bundle.Process
+}
+
+func annotations(bundle *runspec.Spec) map[string]string {
+	if bundle.Annotations == nil {
+		bundle.Annotations = map[string]string{}
+	}
+	return bundle.Annotations
+}
@@ -0,0 +1,155 @@
+package groot
+
+import (
+	"net/url"
+	"path"
+
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/pkg/errors"
+)
+
+// imagePolicyConfig restricts which registry images can be pulled. An image
+// has to match one of the allow rules, when there are any, and none of the
+// deny rules. Images from the registries in require_digest have to be
+// referred to by digest rather than by tag.
+type imagePolicyConfig struct {
+	Allow         []imageRule `yaml:"allow"`
+	Deny          []imageRule `yaml:"deny"`
+	RequireDigest []string    `yaml:"require_digest"`
+}
+
+// imageRule matches images by glob patterns, as understood by path.Match, on
+// the parts of their normalized reference. Tag is matched against the digest
+// of images referred to by digest. Empty patterns match anything.
+type imageRule struct {
+	Registry   string `yaml:"registry"`
+	Repository string `yaml:"repository"`
+	Tag        string `yaml:"tag"`
+}
+
+// imageRef is an image reference split into the parts rules are matched on
+type imageRef struct {
+	registry   string
+	repository string
+	tag        string
+	digested   bool
+}
+
+// checkImagePolicy returns an error classified as ErrPolicyRejected when
+// imageURL may not be pulled, or as ErrInvalidArgument when the URL or the
+// policy cannot be parsed. It does not contact the registry.
+func checkImagePolicy(imageURL *url.URL, policy imagePolicyConfig) error {
+	if len(policy.Allow) == 0 && len(policy.Deny) == 0 && len(policy.RequireDigest) == 0 {
+		return nil
+	}
+
+	ref, err := parseImageRef(imageURL)
+	if err != nil {
+		return invalidArgument(err)
+	}
+
+	if len(policy.Allow) > 0 {
+		allowed, err := ref.matchesAny(policy.Allow)
+		if err != nil {
+			return invalidArgument(err)
+		}
+		if !allowed {
+			return policyRejected(errors.Errorf("image `%s` is not allowed by the image policy", ref))
+		}
+	}
+
+	denied, err := ref.matchesAny(policy.Deny)
+	if err != nil {
+		return invalidArgument(err)
+	}
+	if denied {
+		return policyRejected(errors.Errorf("image `%s` is denied by the image policy", ref))
+	}
+
+	if ref.digested {
+		return nil
+	}
+	for _, registry := range policy.RequireDigest {
+		matched, err := matchPattern(registry, ref.registry)
+		if err != nil {
+			return invalidArgument(err)
+		}
+		if matched {
+			return policyRejected(errors.Errorf("images from registry `%s` have to be referred to by digest", ref.registry))
+		}
+	}
+
+	return nil
+}
+
+func parseImageRef(imageURL *url.URL) (imageRef, error) {
+	registry := imageURL.Host
+	if registry == "" {
+		registry = dockerHubRegistry
+	}
+
+	named, err := reference.ParseNormalizedNamed(registry + imageURL.Path)
+	if err != nil {
+		return imageRef{}, errors.Wrap(err, "parsing image reference")
+	}
+
+	ref := imageRef{
+		registry:   reference.Domain(named),
+		repository: reference.Path(named),
+		tag:        "latest",
+	}
+	if tagged, ok := named.(reference.Tagged); ok {
+		ref.tag = tagged.Tag()
+	}
+	if digested, ok := named.(reference.Digested); ok {
+		ref.tag = digested.Digest().String()
+		ref.digested = true
+	}
+
+	return ref, nil
+}
+
+func (r imageRef) String() string {
+	separator := ":"
+	if r.digested {
+		separator = "@"
+	}
+	return r.registry + "/" + r.repository + separator + r.tag
+}
+
+func (r imageRef) matchesAny(rules []imageRule) (bool, error) {
+	for _, rule := range rules {
+		matched, err := r.matches(rule)
+		if err != nil || matched {
+			return matched, err
+		}
+	}
+	return false, nil
+}
+
+func (r imageRef) matches(rule imageRule) (bool, error) {
+	patterns := [][2]string{
+		{rule.Registry, r.registry},
+		{rule.Repository, r.repository},
+		{rule.Tag, r.tag},
+	}
+	for _, pattern := range patterns {
+		matched, err := matchPattern(pattern[0], pattern[1])
+		if err != nil || !matched {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func matchPattern(pattern, value string) (bool, error) {
+	if pattern == "" {
+		return true, nil
+	}
+
+	matched, err := path.Match(pattern, value)
+	if err != nil {
+		return false, errors.Wrapf(err, "invalid image policy pattern %q", pattern)
+	}
+	return matched, nil
+}
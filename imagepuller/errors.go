@@ -0,0 +1,39 @@
+package imagepuller
+
+import "errors"
+
+// Failures are classified as one of these errors, which callers can check for
+// with errors.Is to tell them apart without matching on error messages
+var (
+	ErrQuotaExceeded       = errors.New("quota exceeded")
+	ErrDigestMismatch      = errors.New("digest mismatch")
+	ErrUnauthorized        = errors.New("unauthorized")
+	ErrImageNotFound       = errors.New("image not found")
+	ErrRegistryUnavailable = errors.New("registry unavailable")
+	ErrInvalidArgument     = errors.New("invalid argument")
+	ErrDriver              = errors.New("driver failure")
+	ErrPolicyRejected      = errors.New("policy rejected")
+)
+
+// ClassifiedError is an error classified as one of the errors above. Its
+// message is the message of the error it classifies.
+type ClassifiedError struct {
+	Kind error
+	Err  error
+}
+
+func (e *ClassifiedError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ClassifiedError) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// Classify returns err classified as kind, or nil when err is nil
+func Classify(kind, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &ClassifiedError{Kind: kind, Err: err}
+}
@@ -1,16 +1,26 @@
 package imagepuller // import "code.cloudfoundry.org/groot/imagepuller"
 
 import (
+	"context"
 	"io"
+	"strings"
+	"time"
 
 	"code.cloudfoundry.org/groot/imagepuller/ondemand"
+	"code.cloudfoundry.org/groot/tracing"
 	"code.cloudfoundry.org/lager/v3"
 	imgspec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 //go:generate counterfeiter . Fetcher
 //go:generate counterfeiter . VolumeDriver
+//go:generate counterfeiter . LayerChecker
+//go:generate counterfeiter . BlobChecker
+//go:generate counterfeiter . ProgressReporter
+//go:generate counterfeiter . LayerDeleter
 
 type LayerInfo struct {
 	BlobID        string
@@ -25,6 +35,11 @@ type LayerInfo struct {
 type ImageInfo struct {
 	LayerInfos []LayerInfo
 	Config     imgspec.Image
+	// ManifestDigest, MediaType and ConfigDigest describe the manifest the
+	// layers were resolved from. They are empty for sources without a manifest.
+	ManifestDigest string
+	MediaType      string
+	ConfigDigest   string
 }
 
 type VolumeMeta struct {
@@ -32,19 +47,48 @@ type VolumeMeta struct {
 }
 
 type Fetcher interface {
-	ImageInfo(logger lager.Logger) (ImageInfo, error)
-	StreamBlob(logger lager.Logger, layerInfo LayerInfo) (io.ReadCloser, int64, error)
+	ImageInfo(ctx context.Context, logger lager.Logger) (ImageInfo, error)
+	StreamBlob(ctx context.Context, logger lager.Logger, layerInfo LayerInfo) (io.ReadCloser, int64, error)
 	Close() error
 }
 
+// VolumeDriver unpacks layers. Blobs can only be verified once they have been
+// read to the end, which drivers that stop at the end of the tar archive never
+// do, so whatever the driver leaves of the stream is read after Unpack
+// returns. When that fails verification the pull fails, and the layer that was
+// just unpacked must be treated as failed: it is deleted when the driver
+// implements LayerDeleter, and is otherwise left for the driver to discard.
 type VolumeDriver interface {
 	Unpack(logger lager.Logger, layerID string, parentIDs []string, layerTar io.Reader) (int64, error)
 }
 
+// LayerDeleter can optionally be implemented by a VolumeDriver to delete
+// layers that were unpacked from blobs that failed verification
+type LayerDeleter interface {
+	DeleteLayer(logger lager.Logger, layerID string) error
+}
+
+// LayerChecker can optionally be implemented by a VolumeDriver so that layers
+// it already holds are neither fetched nor unpacked again. LayerExists returns
+// whether the layer exists and, if so, its unpacked size.
+type LayerChecker interface {
+	LayerExists(logger lager.Logger, layerID string) (bool, int64, error)
+}
+
+// BlobChecker can optionally be implemented by a Fetcher that cannot fetch
+// every blob, such as one that is restricted to local sources. MissingBlobs
+// returns the blob IDs of the given layers that cannot be fetched, so that the
+// pull fails before anything is unpacked.
+type BlobChecker interface {
+	MissingBlobs(ctx context.Context, logger lager.Logger, layerInfos []LayerInfo) ([]string, error)
+}
+
 type Image struct {
-	Config   imgspec.Image
-	ChainIDs []string
-	Size     int64
+	Config         imgspec.Image
+	ChainIDs       []string
+	Size           int64
+	ManifestDigest string
+	ConfigDigest   string
 }
 
 type ImageSpec struct {
@@ -53,51 +97,163 @@ type ImageSpec struct {
 }
 
 type ImagePuller struct {
-	fetcher      Fetcher
-	volumeDriver VolumeDriver
+	fetcher              Fetcher
+	volumeDriver         VolumeDriver
+	maxParallelDownloads int
+	progress             ProgressReporter
 }
 
-func NewImagePuller(fetcher Fetcher, volumeDriver VolumeDriver) *ImagePuller {
+// NewImagePuller creates an ImagePuller that fetches up to
+// maxParallelDownloads layer blobs at the same time. Layers are always
+// unpacked one at a time, parents first. When maxParallelDownloads is 1 or
+// less blobs are only fetched once the driver starts reading them. The
+// progress of pulls is reported to progress, unless it is nil.
+func NewImagePuller(fetcher Fetcher, volumeDriver VolumeDriver, maxParallelDownloads int, progress ProgressReporter) *ImagePuller {
+	if progress == nil {
+		progress = DiscardProgress
+	}
+
 	return &ImagePuller{
-		fetcher:      fetcher,
-		volumeDriver: volumeDriver,
+		fetcher:              fetcher,
+		volumeDriver:         volumeDriver,
+		maxParallelDownloads: maxParallelDownloads,
+		progress:             progress,
+	}
+}
+
+// ImageInfo returns what Pull would fetch, without fetching any layer
+func (p *ImagePuller) ImageInfo(ctx context.Context, logger lager.Logger) (ImageInfo, error) {
+	logger = logger.Session("image-info")
+	logger.Info("starting")
+	defer logger.Info("ending")
+
+	imageInfo, err := p.fetcher.ImageInfo(ctx, logger)
+	if err != nil {
+		return ImageInfo{}, errors.Wrap(err, "fetching list of layer infos")
 	}
+	return imageInfo, nil
 }
 
-func (p *ImagePuller) Pull(logger lager.Logger, spec ImageSpec) (Image, error) {
+func (p *ImagePuller) Pull(ctx context.Context, logger lager.Logger, spec ImageSpec) (Image, error) {
 	logger = logger.Session("image-pulling", lager.Data{"spec": spec})
 	logger.Info("starting")
 	defer logger.Info("ending")
+	started := time.Now()
 
-	imageInfo, err := p.fetcher.ImageInfo(logger)
+	imageInfo, err := p.fetcher.ImageInfo(ctx, logger)
 	if err != nil {
 		return Image{}, errors.Wrap(err, "fetching list of layer infos")
 	}
 	logger.Debug("fetched-layer-infos", lager.Data{"infos": imageInfo.LayerInfos})
+	p.reportResolved(imageInfo.LayerInfos)
 
 	if err = quotaExceeded(logger, imageInfo.LayerInfos, spec); err != nil {
 		return Image{}, err
 	}
 
-	imageSize, err := p.buildLayers(logger, imageInfo.LayerInfos, spec)
+	existingLayerSizes, err := p.existingLayerSizes(logger, imageInfo.LayerInfos)
+	if err != nil {
+		return Image{}, err
+	}
+
+	if err = p.checkMissingBlobs(ctx, logger, imageInfo.LayerInfos, existingLayerSizes); err != nil {
+		return Image{}, err
+	}
+
+	imageSize, err := p.buildLayers(ctx, logger, imageInfo.LayerInfos, existingLayerSizes)
 	if err != nil {
 		return Image{}, err
 	}
 	chainIDs := chainIDs(imageInfo.LayerInfos)
 
+	p.progress.Report(ProgressEvent{
+		Event:     ProgressPullFinished,
+		Layers:    len(imageInfo.LayerInfos),
+		TotalSize: layersSize(imageInfo.LayerInfos),
+		Bytes:     imageSize,
+		Duration:  time.Since(started).Seconds(),
+	})
+
 	image := Image{
-		Config:   imageInfo.Config,
-		ChainIDs: chainIDs,
-		Size:     imageSize,
+		Config:         imageInfo.Config,
+		ChainIDs:       chainIDs,
+		Size:           imageSize,
+		ManifestDigest: imageInfo.ManifestDigest,
+		ConfigDigest:   imageInfo.ConfigDigest,
 	}
 	return image, nil
 }
 
-func (p *ImagePuller) buildLayers(logger lager.Logger, layerInfos []LayerInfo, spec ImageSpec) (int64, error) {
+// existingLayerSizes returns the sizes of the layers the driver already holds,
+// keyed by chain ID
+func (p *ImagePuller) existingLayerSizes(logger lager.Logger, layerInfos []LayerInfo) (map[string]int64, error) {
+	existingLayerSizes := map[string]int64{}
+
+	layerChecker, ok := p.volumeDriver.(LayerChecker)
+	if !ok {
+		return existingLayerSizes, nil
+	}
+
+	for _, layerInfo := range layerInfos {
+		exists, size, err := layerChecker.LayerExists(logger, layerInfo.ChainID)
+		if err != nil {
+			return nil, Classify(ErrDriver, errors.Wrapf(err, "checking if layer `%s` exists", layerInfo.ChainID))
+		}
+
+		if exists {
+			logger.Debug("layer-already-exists", lager.Data{"chainID": layerInfo.ChainID, "size": size})
+			existingLayerSizes[layerInfo.ChainID] = size
+		}
+	}
+
+	return existingLayerSizes, nil
+}
+
+// checkMissingBlobs fails when the fetcher cannot fetch the blobs of layers
+// that the driver does not already hold
+func (p *ImagePuller) checkMissingBlobs(ctx context.Context, logger lager.Logger, layerInfos []LayerInfo, existingLayerSizes map[string]int64) error {
+	blobChecker, ok := p.fetcher.(BlobChecker)
+	if !ok {
+		return nil
+	}
+
+	neededLayerInfos := []LayerInfo{}
+	for _, layerInfo := range layerInfos {
+		if _, exists := existingLayerSizes[layerInfo.ChainID]; !exists {
+			neededLayerInfos = append(neededLayerInfos, layerInfo)
+		}
+	}
+
+	missingBlobs, err := blobChecker.MissingBlobs(ctx, logger, neededLayerInfos)
+	if err != nil {
+		return errors.Wrap(err, "checking for missing blobs")
+	}
+
+	if len(missingBlobs) > 0 {
+		return Classify(ErrImageNotFound, errors.Errorf("missing blobs: %s", strings.Join(missingBlobs, ", ")))
+	}
+	return nil
+}
+
+func (p *ImagePuller) buildLayers(ctx context.Context, logger lager.Logger, layerInfos []LayerInfo, existingLayerSizes map[string]int64) (int64, error) {
+	if p.maxParallelDownloads > 1 {
+		return p.buildLayersInParallel(ctx, logger, layerInfos, existingLayerSizes)
+	}
+
 	totalBytes := int64(0)
 
 	for i, layerInfo := range layerInfos {
-		builtBytes, err := p.buildLayer(logger, layerInfo, chainIDs(layerInfos[0:i]), spec)
+		if err := ctx.Err(); err != nil {
+			return 0, errors.Wrap(err, "building layers")
+		}
+
+		if size, exists := existingLayerSizes[layerInfo.ChainID]; exists {
+			p.reportSkipped(i, layerInfo, size)
+			totalBytes += size
+			continue
+		}
+
+		builtBytes, err := p.buildLayer(ctx, logger, layerInfo, chainIDs(layerInfos[0:i]))
 		if err != nil {
 			return 0, err
 		}
@@ -107,27 +263,227 @@ func (p *ImagePuller) buildLayers(logger lager.Logger, layerInfos []LayerInfo, s
 	return totalBytes, nil
 }
 
-func (p *ImagePuller) buildLayer(logger lager.Logger, layerInfo LayerInfo, parentChainIDs []string, spec ImageSpec) (int64, error) {
-	logger = logger.Session("build-layer", lager.Data{
-		"blobID":        layerInfo.BlobID,
-		"chainID":       layerInfo.ChainID,
-		"parentChainID": layerInfo.ParentChainID,
-	})
+func (p *ImagePuller) buildLayer(ctx context.Context, logger lager.Logger, layerInfo LayerInfo, parentChainIDs []string) (size int64, err error) {
+	logger = buildLayerSession(logger, layerInfo)
+	ctx, span := startBuildLayerSpan(ctx, layerInfo)
+	defer func() { tracing.End(span, err) }()
 
 	onDemandReader := &ondemand.Reader{
 		Create: func() (io.ReadCloser, error) {
-			stream, blobSize, err := p.fetcher.StreamBlob(logger, layerInfo)
-			if err != nil {
-				return nil, errors.Wrapf(err, "opening stream for blob `%s`", layerInfo.BlobID)
-			}
-
-			logger.Debug("got-stream-for-blob", lager.Data{"size": blobSize})
-			return stream, nil
+			return p.streamBlob(ctx, logger, layerInfo)
 		},
 	}
 	defer onDemandReader.Close()
 
-	return p.volumeDriver.Unpack(logger, layerInfo.ChainID, parentChainIDs, onDemandReader)
+	return p.unpack(ctx, logger, layerInfo, parentChainIDs, onDemandReader)
+}
+
+// unpack unpacks the layer on top of its parents. The layer number is derived
+// from the number of parents.
+func (p *ImagePuller) unpack(ctx context.Context, logger lager.Logger, layerInfo LayerInfo, parentChainIDs []string, layerTar io.Reader) (int64, error) {
+	_, span := tracing.Start(ctx, "driver.Unpack", attribute.String("groot.chain_id", layerInfo.ChainID))
+
+	event := ProgressEvent{
+		BlobID:  layerInfo.BlobID,
+		ChainID: layerInfo.ChainID,
+		Layer:   len(parentChainIDs) + 1,
+		Size:    layerInfo.Size,
+	}
+
+	event.Event = ProgressUnpackStarted
+	p.progress.Report(event)
+	started := time.Now()
+
+	size, err := p.volumeDriver.Unpack(logger, layerInfo.ChainID, parentChainIDs, layerTar)
+	if err != nil {
+		tracing.End(span, err)
+		return 0, Classify(ErrDriver, err)
+	}
+
+	if err := drainLayer(layerTar); err != nil {
+		err = errors.Wrapf(err, "verifying layer `%s`", layerInfo.ChainID)
+		tracing.End(span, err)
+		p.deleteUnverifiedLayer(logger, layerInfo.ChainID)
+		return 0, err
+	}
+	tracing.End(span, nil)
+
+	event.Event = ProgressUnpackFinished
+	event.Bytes = size
+	event.Duration = time.Since(started).Seconds()
+	p.progress.Report(event)
+
+	return size, nil
+}
+
+// drainLayer reads the rest of the stream of a layer, so that its blob is
+// verified. Streams of blobs the driver never started reading are not opened.
+func drainLayer(layerTar io.Reader) error {
+	if onDemandReader, ok := layerTar.(*ondemand.Reader); ok && !onDemandReader.Opened() {
+		return nil
+	}
+
+	_, err := io.Copy(io.Discard, layerTar)
+	return err
+}
+
+func (p *ImagePuller) deleteUnverifiedLayer(logger lager.Logger, layerID string) {
+	layerDeleter, ok := p.volumeDriver.(LayerDeleter)
+	if !ok {
+		logger.Info("unverified-layer-not-deleted", lager.Data{"layerID": layerID})
+		return
+	}
+
+	if err := layerDeleter.DeleteLayer(logger, layerID); err != nil {
+		logger.Error("deleting-unverified-layer-failed", err, lager.Data{"layerID": layerID})
+	}
+}
+
+func (p *ImagePuller) reportResolved(layerInfos []LayerInfo) {
+	totalSize := layersSize(layerInfos)
+	for i, layerInfo := range layerInfos {
+		p.progress.Report(ProgressEvent{
+			Event:     ProgressLayerResolved,
+			BlobID:    layerInfo.BlobID,
+			ChainID:   layerInfo.ChainID,
+			Layer:     i + 1,
+			Layers:    len(layerInfos),
+			TotalSize: totalSize,
+			Size:      layerInfo.Size,
+		})
+	}
+}
+
+func (p *ImagePuller) reportSkipped(i int, layerInfo LayerInfo, size int64) {
+	p.progress.Report(ProgressEvent{
+		Event:   ProgressLayerSkipped,
+		BlobID:  layerInfo.BlobID,
+		ChainID: layerInfo.ChainID,
+		Layer:   i + 1,
+		Size:    layerInfo.Size,
+		Bytes:   size,
+	})
+}
+
+type fetchedBlob struct {
+	stream io.ReadCloser
+	err    error
+}
+
+func (p *ImagePuller) buildLayersInParallel(ctx context.Context, logger lager.Logger, layerInfos []LayerInfo, existingLayerSizes map[string]int64) (int64, error) {
+	layerLoggers := make([]lager.Logger, len(layerInfos))
+	for i, layerInfo := range layerInfos {
+		layerLoggers[i] = buildLayerSession(logger, layerInfo)
+	}
+
+	ctx, abort := context.WithCancel(ctx)
+	fetchedBlobs := p.fetchBlobs(ctx, layerLoggers, layerInfos, existingLayerSizes)
+
+	consumed := 0
+	defer func() {
+		abort()
+		for _, pending := range fetchedBlobs[consumed:] {
+			if blob := <-pending; blob.stream != nil {
+				blob.stream.Close()
+			}
+		}
+	}()
+
+	totalBytes := int64(0)
+	for i, layerInfo := range layerInfos {
+		if err := ctx.Err(); err != nil {
+			return 0, errors.Wrap(err, "building layers")
+		}
+
+		blob := <-fetchedBlobs[i]
+		consumed++
+		if size, exists := existingLayerSizes[layerInfo.ChainID]; exists {
+			p.reportSkipped(i, layerInfo, size)
+			totalBytes += size
+			continue
+		}
+
+		if blob.err != nil {
+			return 0, blob.err
+		}
+
+		layerCtx, span := startBuildLayerSpan(ctx, layerInfo)
+		builtBytes, err := p.unpack(layerCtx, layerLoggers[i], layerInfo, chainIDs(layerInfos[0:i]), blob.stream)
+		tracing.End(span, err)
+		blob.stream.Close()
+		if err != nil {
+			return 0, err
+		}
+		totalBytes += builtBytes
+	}
+
+	return totalBytes, nil
+}
+
+// fetchBlobs streams the blobs of all layers that do not exist yet using at
+// most maxParallelDownloads concurrent fetches. Every returned channel
+// receives exactly one fetchedBlob, even when the fetch was skipped because
+// the layer exists or the context was cancelled.
+func (p *ImagePuller) fetchBlobs(ctx context.Context, layerLoggers []lager.Logger, layerInfos []LayerInfo, existingLayerSizes map[string]int64) []chan fetchedBlob {
+	fetchedBlobs := make([]chan fetchedBlob, len(layerInfos))
+	for i := range fetchedBlobs {
+		fetchedBlobs[i] = make(chan fetchedBlob, 1)
+	}
+
+	workers := make(chan struct{}, p.maxParallelDownloads)
+	go func() {
+		for i, layerInfo := range layerInfos {
+			if _, exists := existingLayerSizes[layerInfo.ChainID]; exists {
+				fetchedBlobs[i] <- fetchedBlob{}
+				continue
+			}
+
+			select {
+			case workers <- struct{}{}:
+			case <-ctx.Done():
+				fetchedBlobs[i] <- fetchedBlob{err: errors.Wrap(ctx.Err(), "fetching blobs")}
+				continue
+			}
+
+			go func(i int, layerInfo LayerInfo) {
+				defer func() { <-workers }()
+
+				stream, err := p.streamBlob(ctx, layerLoggers[i], layerInfo)
+				fetchedBlobs[i] <- fetchedBlob{stream: stream, err: err}
+			}(i, layerInfo)
+		}
+	}()
+
+	return fetchedBlobs
+}
+
+func (p *ImagePuller) streamBlob(ctx context.Context, logger lager.Logger, layerInfo LayerInfo) (io.ReadCloser, error) {
+	stream, blobSize, err := p.fetcher.StreamBlob(ctx, logger, layerInfo)
+	if err != nil {
+		return nil, errors.Wrapf(err, "opening stream for blob `%s`", layerInfo.BlobID)
+	}
+
+	logger.Debug("got-stream-for-blob", lager.Data{"size": blobSize})
+	return stream, nil
+}
+
+func buildLayerSession(logger lager.Logger, layerInfo LayerInfo) lager.Logger {
+	return logger.Session("build-layer", lager.Data{
+		"blobID":        layerInfo.BlobID,
+		"chainID":       layerInfo.ChainID,
+		"parentChainID": layerInfo.ParentChainID,
+	})
+}
+
+// startBuildLayerSpan starts the span of a layer that is fetched and
+// unpacked. Blobs fetched ahead of time, when several are fetched in
+// parallel, are not part of it.
+func startBuildLayerSpan(ctx context.Context, layerInfo LayerInfo) (context.Context, trace.Span) {
+	return tracing.Start(ctx, "imagepuller.buildLayer",
+		attribute.String("groot.blob_id", layerInfo.BlobID),
+		attribute.String("groot.chain_id", layerInfo.ChainID),
+		attribute.Int64("groot.blob_size", layerInfo.Size),
+	)
 }
 
 func chainIDs(layerInfos []LayerInfo) []string {
@@ -145,7 +501,7 @@ func quotaExceeded(logger lager.Logger, layerInfos []LayerInfo, spec ImageSpec)
 
 	totalSize := layersSize(layerInfos)
 	if totalSize > spec.DiskLimit {
-		err := errors.Errorf("layers exceed disk quota %d/%d bytes", totalSize, spec.DiskLimit)
+		err := Classify(ErrQuotaExceeded, errors.Errorf("layers exceed disk quota %d/%d bytes", totalSize, spec.DiskLimit))
 		logger.Error("blob-manifest-size-check-failed", err, lager.Data{
 			"totalSize":             totalSize,
 			"diskLimit":             spec.DiskLimit,
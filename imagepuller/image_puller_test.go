@@ -1,12 +1,15 @@
 package imagepuller_test
 
 import (
+	"archive/tar"
 	"bytes"
 	"compress/gzip"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"os"
+	"sync"
 
 	"code.cloudfoundry.org/groot/imagepuller"
 	"code.cloudfoundry.org/groot/imagepuller/imagepullerfakes"
@@ -15,6 +18,13 @@ import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	specsv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 var _ = Describe("Image Puller", func() {
@@ -24,6 +34,8 @@ var _ = Describe("Image Puller", func() {
 		fakeVolumeDriver *imagepullerfakes.FakeVolumeDriver
 		expectedImgDesc  specsv1.Image
 
+		fakeProgressReporter *imagepullerfakes.FakeProgressReporter
+
 		imagePuller *imagepuller.ImagePuller
 		layerInfos  []imagepuller.LayerInfo
 
@@ -44,7 +56,7 @@ var _ = Describe("Image Puller", func() {
 				Config:     expectedImgDesc,
 			}, nil)
 
-		fakeFetcher.StreamBlobStub = func(_ lager.Logger, layerInfo imagepuller.LayerInfo) (io.ReadCloser, int64, error) {
+		fakeFetcher.StreamBlobStub = func(_ context.Context, _ lager.Logger, layerInfo imagepuller.LayerInfo) (io.ReadCloser, int64, error) {
 			buffer := bytes.NewBuffer([]byte{})
 			stream := gzip.NewWriter(buffer)
 			defer stream.Close()
@@ -53,6 +65,8 @@ var _ = Describe("Image Puller", func() {
 
 		tmpVolumesDir = tempDir("", "volumes")
 
+		fakeProgressReporter = new(imagepullerfakes.FakeProgressReporter)
+
 		fakeVolumeDriver = new(imagepullerfakes.FakeVolumeDriver)
 		count := 0
 		fakeVolumeDriver.UnpackStub = func(_ lager.Logger, layerID string, parentIDs []string, layerTar io.Reader) (int64, error) {
@@ -60,7 +74,7 @@ var _ = Describe("Image Puller", func() {
 			count++
 			return size, nil
 		}
-		imagePuller = imagepuller.NewImagePuller(fakeFetcher, fakeVolumeDriver)
+		imagePuller = imagepuller.NewImagePuller(fakeFetcher, fakeVolumeDriver, 1, fakeProgressReporter)
 		logger = lagertest.NewTestLogger("image-puller")
 	})
 
@@ -68,25 +82,194 @@ var _ = Describe("Image Puller", func() {
 		Expect(os.RemoveAll(tmpVolumesDir)).To(Succeed())
 	})
 
+	Describe("ImageInfo", func() {
+		It("returns the image info from the fetcher", func() {
+			imageInfo, err := imagePuller.ImageInfo(context.Background(), logger)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(imageInfo.LayerInfos).To(Equal(layerInfos))
+			Expect(imageInfo.Config).To(Equal(expectedImgDesc))
+		})
+
+		It("does not fetch or unpack any layer", func() {
+			_, err := imagePuller.ImageInfo(context.Background(), logger)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeFetcher.StreamBlobCallCount()).To(BeZero())
+			Expect(fakeVolumeDriver.UnpackCallCount()).To(BeZero())
+		})
+
+		Context("when the fetcher fails", func() {
+			BeforeEach(func() {
+				fakeFetcher.ImageInfoReturns(imagepuller.ImageInfo{}, errors.New("failed to fetch image info"))
+			})
+
+			It("returns an error", func() {
+				_, err := imagePuller.ImageInfo(context.Background(), logger)
+				Expect(err).To(MatchError(ContainSubstring("failed to fetch image info")))
+			})
+		})
+	})
+
+	Describe("progress", func() {
+		It("reports the progress of every layer", func() {
+			_, err := imagePuller.Pull(context.Background(), logger, imagepuller.ImageSpec{})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(reportedProgress(fakeProgressReporter)).To(Equal([]imagepuller.ProgressEvent{
+				{Event: imagepuller.ProgressLayerResolved, BlobID: "i-am-a-layer", ChainID: "layer-111", Layer: 1, Layers: 3, TotalSize: 666, Size: 111},
+				{Event: imagepuller.ProgressLayerResolved, BlobID: "i-am-another-layer", ChainID: "chain-222", Layer: 2, Layers: 3, TotalSize: 666, Size: 222},
+				{Event: imagepuller.ProgressLayerResolved, BlobID: "i-am-the-last-layer", ChainID: "chain-333", Layer: 3, Layers: 3, TotalSize: 666, Size: 333},
+				{Event: imagepuller.ProgressUnpackStarted, BlobID: "i-am-a-layer", ChainID: "layer-111", Layer: 1, Size: 111},
+				{Event: imagepuller.ProgressUnpackFinished, BlobID: "i-am-a-layer", ChainID: "layer-111", Layer: 1, Size: 111, Bytes: 111},
+				{Event: imagepuller.ProgressUnpackStarted, BlobID: "i-am-another-layer", ChainID: "chain-222", Layer: 2, Size: 222},
+				{Event: imagepuller.ProgressUnpackFinished, BlobID: "i-am-another-layer", ChainID: "chain-222", Layer: 2, Size: 222, Bytes: 222},
+				{Event: imagepuller.ProgressUnpackStarted, BlobID: "i-am-the-last-layer", ChainID: "chain-333", Layer: 3, Size: 333},
+				{Event: imagepuller.ProgressUnpackFinished, BlobID: "i-am-the-last-layer", ChainID: "chain-333", Layer: 3, Size: 333, Bytes: 333},
+				{Event: imagepuller.ProgressPullFinished, Layers: 3, TotalSize: 666, Bytes: 666},
+			}))
+		})
+
+		Context("when unpacking a layer fails", func() {
+			BeforeEach(func() {
+				fakeVolumeDriver.UnpackReturns(0, errors.New("failed to unpack the blob"))
+			})
+
+			It("does not report it as finished", func() {
+				_, err := imagePuller.Pull(context.Background(), logger, imagepuller.ImageSpec{})
+				Expect(err).To(HaveOccurred())
+
+				events := reportedProgress(fakeProgressReporter)
+				Expect(events[len(events)-1].Event).To(Equal(imagepuller.ProgressUnpackStarted))
+			})
+		})
+
+		Context("when no progress reporter is given", func() {
+			BeforeEach(func() {
+				imagePuller = imagepuller.NewImagePuller(fakeFetcher, fakeVolumeDriver, 1, nil)
+			})
+
+			It("pulls the image", func() {
+				_, err := imagePuller.Pull(context.Background(), logger, imagepuller.ImageSpec{})
+				Expect(err).NotTo(HaveOccurred())
+			})
+		})
+	})
+
+	Describe("tracing", func() {
+		var exporter *tracetest.InMemoryExporter
+
+		BeforeEach(func() {
+			exporter = tracetest.NewInMemoryExporter()
+			otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+		})
+
+		AfterEach(func() {
+			otel.SetTracerProvider(noop.NewTracerProvider())
+		})
+
+		spanNames := func() []string {
+			names := []string{}
+			for _, span := range exporter.GetSpans() {
+				names = append(names, span.Name)
+			}
+			return names
+		}
+
+		It("records a span for every layer with a child span for unpacking it", func() {
+			_, err := imagePuller.Pull(context.Background(), logger, imagepuller.ImageSpec{})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(spanNames()).To(Equal([]string{
+				"driver.Unpack", "imagepuller.buildLayer",
+				"driver.Unpack", "imagepuller.buildLayer",
+				"driver.Unpack", "imagepuller.buildLayer",
+			}))
+
+			spans := exporter.GetSpans()
+			Expect(spans[0].Parent.SpanID()).To(Equal(spans[1].SpanContext.SpanID()))
+			Expect(spans[1].Attributes).To(ContainElement(attribute.String("groot.chain_id", "layer-111")))
+			Expect(spans[1].Attributes).To(ContainElement(attribute.String("groot.blob_id", "i-am-a-layer")))
+		})
+
+		It("streams the blob of the layer within its span", func() {
+			fakeVolumeDriver.UnpackStub = func(_ lager.Logger, _ string, _ []string, layerTar io.Reader) (int64, error) {
+				_, err := io.Copy(io.Discard, layerTar)
+				return 0, err
+			}
+
+			_, err := imagePuller.Pull(context.Background(), logger, imagepuller.ImageSpec{})
+			Expect(err).NotTo(HaveOccurred())
+
+			ctx, _, _ := fakeFetcher.StreamBlobArgsForCall(0)
+			Expect(trace.SpanContextFromContext(ctx).SpanID()).To(Equal(exporter.GetSpans()[1].SpanContext.SpanID()))
+		})
+
+		Context("when layers are downloaded in parallel", func() {
+			BeforeEach(func() {
+				imagePuller = imagepuller.NewImagePuller(fakeFetcher, fakeVolumeDriver, 3, fakeProgressReporter)
+			})
+
+			It("records a span for every layer with a child span for unpacking it", func() {
+				_, err := imagePuller.Pull(context.Background(), logger, imagepuller.ImageSpec{})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(spanNames()).To(Equal([]string{
+					"driver.Unpack", "imagepuller.buildLayer",
+					"driver.Unpack", "imagepuller.buildLayer",
+					"driver.Unpack", "imagepuller.buildLayer",
+				}))
+			})
+		})
+
+		Context("when unpacking a layer fails", func() {
+			BeforeEach(func() {
+				fakeVolumeDriver.UnpackReturns(0, errors.New("failed to unpack the blob"))
+			})
+
+			It("marks the spans of the layer as failed", func() {
+				_, err := imagePuller.Pull(context.Background(), logger, imagepuller.ImageSpec{})
+				Expect(err).To(HaveOccurred())
+
+				spans := exporter.GetSpans()
+				Expect(spans).To(HaveLen(2))
+				Expect(spans[0].Status.Code).To(Equal(codes.Error))
+				Expect(spans[1].Status.Code).To(Equal(codes.Error))
+				Expect(spans[1].Status.Description).To(ContainSubstring("failed to unpack the blob"))
+			})
+		})
+	})
+
 	It("returns the image description", func() {
-		image, err := imagePuller.Pull(logger, imagepuller.ImageSpec{})
+		image, err := imagePuller.Pull(context.Background(), logger, imagepuller.ImageSpec{})
 		Expect(err).NotTo(HaveOccurred())
 
 		Expect(image.Config).To(Equal(expectedImgDesc))
 	})
 
+	It("returns the manifest and config digests", func() {
+		fakeFetcher.ImageInfoReturns(imagepuller.ImageInfo{
+			LayerInfos:     layerInfos,
+			ManifestDigest: "sha256:manifest",
+			ConfigDigest:   "sha256:config",
+		}, nil)
+
+		image, err := imagePuller.Pull(context.Background(), logger, imagepuller.ImageSpec{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(image.ManifestDigest).To(Equal("sha256:manifest"))
+		Expect(image.ConfigDigest).To(Equal("sha256:config"))
+	})
+
 	It("returns the chain ids in the order specified by the image", func() {
-		image, _ := imagePuller.Pull(logger, imagepuller.ImageSpec{})
+		image, _ := imagePuller.Pull(context.Background(), logger, imagepuller.ImageSpec{})
 		Expect(image.ChainIDs).To(Equal([]string{"layer-111", "chain-222", "chain-333"}))
 	})
 
 	It("returns the total size of the base image", func() {
-		image, _ := imagePuller.Pull(logger, imagepuller.ImageSpec{})
+		image, _ := imagePuller.Pull(context.Background(), logger, imagepuller.ImageSpec{})
 		Expect(image.Size).To(Equal(int64(666)))
 	})
 
 	It("passes the correct parentIDs to Unpack", func() {
-		imagePuller.Pull(logger, imagepuller.ImageSpec{})
+		imagePuller.Pull(context.Background(), logger, imagepuller.ImageSpec{})
 
 		Expect(fakeVolumeDriver.UnpackCallCount()).To(Equal(3))
 
@@ -99,7 +282,7 @@ var _ = Describe("Image Puller", func() {
 	})
 
 	It("unpacks the layers got from the fetcher", func() {
-		fakeFetcher.StreamBlobStub = func(_ lager.Logger, layerInfo imagepuller.LayerInfo) (io.ReadCloser, int64, error) {
+		fakeFetcher.StreamBlobStub = func(_ context.Context, _ lager.Logger, layerInfo imagepuller.LayerInfo) (io.ReadCloser, int64, error) {
 			buffer := bytes.NewBuffer([]byte{})
 			stream := gzip.NewWriter(buffer)
 			defer stream.Close()
@@ -107,7 +290,7 @@ var _ = Describe("Image Puller", func() {
 			return io.NopCloser(buffer), 1200, nil
 		}
 
-		imagePuller.Pull(logger, imagepuller.ImageSpec{})
+		imagePuller.Pull(context.Background(), logger, imagepuller.ImageSpec{})
 
 		Expect(fakeVolumeDriver.UnpackCallCount()).To(Equal(3))
 
@@ -121,6 +304,363 @@ var _ = Describe("Image Puller", func() {
 		validateLayer(2, "layer-i-am-the-last-layer-contents")
 	})
 
+	It("does not fetch a blob until the driver reads it", func() {
+		fakeVolumeDriver.UnpackReturns(0, nil)
+
+		_, err := imagePuller.Pull(context.Background(), logger, imagepuller.ImageSpec{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fakeFetcher.StreamBlobCallCount()).To(Equal(0))
+	})
+
+	Context("when layers are downloaded in parallel", func() {
+		var (
+			streamsMutex sync.Mutex
+			openStreams  map[string]bool
+		)
+
+		BeforeEach(func() {
+			openStreams = map[string]bool{}
+			fakeFetcher.StreamBlobStub = func(_ context.Context, _ lager.Logger, layerInfo imagepuller.LayerInfo) (io.ReadCloser, int64, error) {
+				streamsMutex.Lock()
+				defer streamsMutex.Unlock()
+				openStreams[layerInfo.BlobID] = true
+
+				return &closeNotifyingReader{
+					Reader: bytes.NewBufferString(fmt.Sprintf("layer-%s-contents", layerInfo.BlobID)),
+					onClose: func() {
+						streamsMutex.Lock()
+						defer streamsMutex.Unlock()
+						delete(openStreams, layerInfo.BlobID)
+					},
+				}, 0, nil
+			}
+
+			imagePuller = imagepuller.NewImagePuller(fakeFetcher, fakeVolumeDriver, 2, fakeProgressReporter)
+		})
+
+		It("fetches every blob", func() {
+			_, err := imagePuller.Pull(context.Background(), logger, imagepuller.ImageSpec{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeFetcher.StreamBlobCallCount()).To(Equal(3))
+		})
+
+		It("unpacks the layers in order with the correct parentIDs", func() {
+			var unpackedContents []string
+			fakeVolumeDriver.UnpackStub = func(_ lager.Logger, layerID string, parentIDs []string, layerTar io.Reader) (int64, error) {
+				unpackedContents = append(unpackedContents, readAll(layerTar))
+				return 0, nil
+			}
+
+			_, err := imagePuller.Pull(context.Background(), logger, imagepuller.ImageSpec{})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeVolumeDriver.UnpackCallCount()).To(Equal(3))
+			_, layerID, parentIDs, _ := fakeVolumeDriver.UnpackArgsForCall(0)
+			Expect(layerID).To(Equal("layer-111"))
+			Expect(parentIDs).To(BeEmpty())
+			_, layerID, parentIDs, _ = fakeVolumeDriver.UnpackArgsForCall(1)
+			Expect(layerID).To(Equal("chain-222"))
+			Expect(parentIDs).To(Equal([]string{"layer-111"}))
+			_, layerID, parentIDs, _ = fakeVolumeDriver.UnpackArgsForCall(2)
+			Expect(layerID).To(Equal("chain-333"))
+			Expect(parentIDs).To(Equal([]string{"layer-111", "chain-222"}))
+
+			Expect(unpackedContents).To(Equal([]string{
+				"layer-i-am-a-layer-contents",
+				"layer-i-am-another-layer-contents",
+				"layer-i-am-the-last-layer-contents",
+			}))
+		})
+
+		It("returns the total size of the base image", func() {
+			image, err := imagePuller.Pull(context.Background(), logger, imagepuller.ImageSpec{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(image.Size).To(Equal(int64(666)))
+		})
+
+		It("closes all the blob streams", func() {
+			_, err := imagePuller.Pull(context.Background(), logger, imagepuller.ImageSpec{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(openStreams).To(BeEmpty())
+		})
+
+		It("downloads blobs in parallel without exceeding the maximum number of parallel downloads", func() {
+			var (
+				inFlightMutex      sync.Mutex
+				inFlight, maxFetch int
+			)
+			inFlightFetches := func() int {
+				inFlightMutex.Lock()
+				defer inFlightMutex.Unlock()
+				return inFlight
+			}
+			release := make(chan struct{})
+			streamBlob := fakeFetcher.StreamBlobStub
+			fakeFetcher.StreamBlobStub = func(ctx context.Context, logger lager.Logger, layerInfo imagepuller.LayerInfo) (io.ReadCloser, int64, error) {
+				inFlightMutex.Lock()
+				inFlight++
+				if inFlight > maxFetch {
+					maxFetch = inFlight
+				}
+				inFlightMutex.Unlock()
+
+				defer func() {
+					inFlightMutex.Lock()
+					inFlight--
+					inFlightMutex.Unlock()
+				}()
+
+				<-release
+				return streamBlob(ctx, logger, layerInfo)
+			}
+
+			pulled := make(chan error)
+			go func() {
+				defer GinkgoRecover()
+				_, err := imagePuller.Pull(context.Background(), logger, imagepuller.ImageSpec{})
+				pulled <- err
+			}()
+
+			Eventually(inFlightFetches).Should(Equal(2))
+			Consistently(inFlightFetches).Should(Equal(2))
+			close(release)
+
+			Eventually(pulled).Should(Receive(BeNil()))
+			Expect(maxFetch).To(Equal(2))
+		})
+
+		Context("when streaming a blob fails", func() {
+			BeforeEach(func() {
+				streamBlob := fakeFetcher.StreamBlobStub
+				fakeFetcher.StreamBlobStub = func(ctx context.Context, logger lager.Logger, layerInfo imagepuller.LayerInfo) (io.ReadCloser, int64, error) {
+					if layerInfo.BlobID == "i-am-another-layer" {
+						return nil, 0, errors.New("failed to stream blob")
+					}
+					return streamBlob(ctx, logger, layerInfo)
+				}
+			})
+
+			It("returns an error", func() {
+				_, err := imagePuller.Pull(context.Background(), logger, imagepuller.ImageSpec{})
+				Expect(err).To(MatchError(ContainSubstring("failed to stream blob")))
+			})
+
+			It("does not unpack the failed layer or its children", func() {
+				_, _ = imagePuller.Pull(context.Background(), logger, imagepuller.ImageSpec{})
+				Expect(fakeVolumeDriver.UnpackCallCount()).To(Equal(1))
+			})
+
+			It("closes the streams that were already fetched", func() {
+				_, _ = imagePuller.Pull(context.Background(), logger, imagepuller.ImageSpec{})
+				Expect(openStreams).To(BeEmpty())
+			})
+		})
+
+		Context("when unpacking a layer fails", func() {
+			BeforeEach(func() {
+				fakeVolumeDriver.UnpackReturns(0, errors.New("failed to unpack the blob"))
+			})
+
+			It("returns an error", func() {
+				_, err := imagePuller.Pull(context.Background(), logger, imagepuller.ImageSpec{})
+				Expect(err).To(MatchError(ContainSubstring("failed to unpack the blob")))
+			})
+
+			It("classifies it as a driver failure", func() {
+				_, err := imagePuller.Pull(context.Background(), logger, imagepuller.ImageSpec{})
+				Expect(err).To(MatchError(imagepuller.ErrDriver))
+			})
+
+			It("closes the streams that were already fetched", func() {
+				_, _ = imagePuller.Pull(context.Background(), logger, imagepuller.ImageSpec{})
+				Expect(openStreams).To(BeEmpty())
+			})
+		})
+	})
+
+	Context("when the volume driver can tell which layers exist", func() {
+		var fakeLayerChecker *imagepullerfakes.FakeLayerChecker
+
+		BeforeEach(func() {
+			fakeLayerChecker = new(imagepullerfakes.FakeLayerChecker)
+			fakeLayerChecker.LayerExistsStub = func(_ lager.Logger, layerID string) (bool, int64, error) {
+				if layerID == "chain-222" {
+					return true, 2000, nil
+				}
+				return false, 0, nil
+			}
+
+			fakeVolumeDriver.UnpackStub = func(_ lager.Logger, layerID string, _ []string, _ io.Reader) (int64, error) {
+				return 100, nil
+			}
+
+			imagePuller = imagepuller.NewImagePuller(fakeFetcher, &checkingVolumeDriver{
+				FakeVolumeDriver: fakeVolumeDriver,
+				FakeLayerChecker: fakeLayerChecker,
+			}, 1, fakeProgressReporter)
+		})
+
+		It("checks every layer", func() {
+			_, err := imagePuller.Pull(context.Background(), logger, imagepuller.ImageSpec{})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeLayerChecker.LayerExistsCallCount()).To(Equal(3))
+			_, layerID := fakeLayerChecker.LayerExistsArgsForCall(1)
+			Expect(layerID).To(Equal("chain-222"))
+		})
+
+		It("only unpacks the missing layers with the correct parentIDs", func() {
+			_, err := imagePuller.Pull(context.Background(), logger, imagepuller.ImageSpec{})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeVolumeDriver.UnpackCallCount()).To(Equal(2))
+			_, layerID, parentIDs, _ := fakeVolumeDriver.UnpackArgsForCall(0)
+			Expect(layerID).To(Equal("layer-111"))
+			Expect(parentIDs).To(BeEmpty())
+			_, layerID, parentIDs, _ = fakeVolumeDriver.UnpackArgsForCall(1)
+			Expect(layerID).To(Equal("chain-333"))
+			Expect(parentIDs).To(Equal([]string{"layer-111", "chain-222"}))
+		})
+
+		It("includes the size of the existing layers in the image size", func() {
+			image, err := imagePuller.Pull(context.Background(), logger, imagepuller.ImageSpec{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(image.Size).To(Equal(int64(2200)))
+		})
+
+		It("reports the existing layers as skipped", func() {
+			_, err := imagePuller.Pull(context.Background(), logger, imagepuller.ImageSpec{})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(reportedProgress(fakeProgressReporter)).To(ContainElement(imagepuller.ProgressEvent{
+				Event: imagepuller.ProgressLayerSkipped, BlobID: "i-am-another-layer", ChainID: "chain-222", Layer: 2, Size: 222, Bytes: 2000,
+			}))
+			Expect(reportedProgress(fakeProgressReporter)).NotTo(ContainElement(SatisfyAll(HaveField("ChainID", "chain-222"), HaveField("Event", imagepuller.ProgressUnpackStarted))))
+		})
+
+		It("still returns all the chain ids", func() {
+			image, err := imagePuller.Pull(context.Background(), logger, imagepuller.ImageSpec{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(image.ChainIDs).To(Equal([]string{"layer-111", "chain-222", "chain-333"}))
+		})
+
+		Context("when layers are downloaded in parallel", func() {
+			BeforeEach(func() {
+				imagePuller = imagepuller.NewImagePuller(fakeFetcher, &checkingVolumeDriver{
+					FakeVolumeDriver: fakeVolumeDriver,
+					FakeLayerChecker: fakeLayerChecker,
+				}, 3, fakeProgressReporter)
+			})
+
+			It("does not fetch the existing layers", func() {
+				_, err := imagePuller.Pull(context.Background(), logger, imagepuller.ImageSpec{})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeFetcher.StreamBlobCallCount()).To(Equal(2))
+				for i := 0; i < fakeFetcher.StreamBlobCallCount(); i++ {
+					_, _, layerInfo := fakeFetcher.StreamBlobArgsForCall(i)
+					Expect(layerInfo.ChainID).NotTo(Equal("chain-222"))
+				}
+			})
+
+			It("includes the size of the existing layers in the image size", func() {
+				image, err := imagePuller.Pull(context.Background(), logger, imagepuller.ImageSpec{})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(image.Size).To(Equal(int64(2200)))
+			})
+		})
+
+		Context("when checking a layer fails", func() {
+			BeforeEach(func() {
+				fakeLayerChecker.LayerExistsReturns(false, 0, errors.New("failed to check layer"))
+			})
+
+			It("returns an error", func() {
+				_, err := imagePuller.Pull(context.Background(), logger, imagepuller.ImageSpec{})
+				Expect(err).To(MatchError(ContainSubstring("failed to check layer")))
+			})
+
+			It("does not unpack any layer", func() {
+				_, _ = imagePuller.Pull(context.Background(), logger, imagepuller.ImageSpec{})
+				Expect(fakeVolumeDriver.UnpackCallCount()).To(BeZero())
+			})
+		})
+	})
+
+	Context("when the fetcher can tell which blobs it cannot fetch", func() {
+		var fakeBlobChecker *imagepullerfakes.FakeBlobChecker
+
+		BeforeEach(func() {
+			fakeBlobChecker = new(imagepullerfakes.FakeBlobChecker)
+			imagePuller = imagepuller.NewImagePuller(&checkingFetcher{
+				FakeFetcher:     fakeFetcher,
+				FakeBlobChecker: fakeBlobChecker,
+			}, fakeVolumeDriver, 1, fakeProgressReporter)
+		})
+
+		It("checks the blobs of every layer", func() {
+			_, err := imagePuller.Pull(context.Background(), logger, imagepuller.ImageSpec{})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeBlobChecker.MissingBlobsCallCount()).To(Equal(1))
+			_, _, checkedLayerInfos := fakeBlobChecker.MissingBlobsArgsForCall(0)
+			Expect(checkedLayerInfos).To(Equal(layerInfos))
+		})
+
+		Context("when the volume driver already has some of the layers", func() {
+			BeforeEach(func() {
+				fakeLayerChecker := new(imagepullerfakes.FakeLayerChecker)
+				fakeLayerChecker.LayerExistsStub = func(_ lager.Logger, layerID string) (bool, int64, error) {
+					return layerID == "chain-222", 0, nil
+				}
+
+				imagePuller = imagepuller.NewImagePuller(&checkingFetcher{
+					FakeFetcher:     fakeFetcher,
+					FakeBlobChecker: fakeBlobChecker,
+				}, &checkingVolumeDriver{
+					FakeVolumeDriver: fakeVolumeDriver,
+					FakeLayerChecker: fakeLayerChecker,
+				}, 1, fakeProgressReporter)
+			})
+
+			It("does not check their blobs", func() {
+				_, err := imagePuller.Pull(context.Background(), logger, imagepuller.ImageSpec{})
+				Expect(err).NotTo(HaveOccurred())
+
+				_, _, checkedLayerInfos := fakeBlobChecker.MissingBlobsArgsForCall(0)
+				Expect(checkedLayerInfos).To(Equal([]imagepuller.LayerInfo{layerInfos[0], layerInfos[2]}))
+			})
+		})
+
+		Context("when blobs are missing", func() {
+			BeforeEach(func() {
+				fakeBlobChecker.MissingBlobsReturns([]string{"i-am-a-layer", "i-am-the-last-layer"}, nil)
+			})
+
+			It("returns an error listing them", func() {
+				_, err := imagePuller.Pull(context.Background(), logger, imagepuller.ImageSpec{})
+				Expect(err).To(MatchError("missing blobs: i-am-a-layer, i-am-the-last-layer"))
+			})
+
+			It("does not fetch any layer", func() {
+				_, _ = imagePuller.Pull(context.Background(), logger, imagepuller.ImageSpec{})
+				Expect(fakeFetcher.StreamBlobCallCount()).To(BeZero())
+				Expect(fakeVolumeDriver.UnpackCallCount()).To(BeZero())
+			})
+		})
+
+		Context("when checking the blobs fails", func() {
+			BeforeEach(func() {
+				fakeBlobChecker.MissingBlobsReturns(nil, errors.New("failed to check blobs"))
+			})
+
+			It("returns an error", func() {
+				_, err := imagePuller.Pull(context.Background(), logger, imagepuller.ImageSpec{})
+				Expect(err).To(MatchError(ContainSubstring("failed to check blobs")))
+			})
+		})
+	})
+
 	Context("when the layers size in the manifest will exceed the limit", func() {
 		Context("when including the image size in the limit", func() {
 			It("returns an error", func() {
@@ -131,11 +671,12 @@ var _ = Describe("Image Puller", func() {
 					},
 				}, nil)
 
-				_, err := imagePuller.Pull(logger, imagepuller.ImageSpec{
+				_, err := imagePuller.Pull(context.Background(), logger, imagepuller.ImageSpec{
 					DiskLimit:             1200,
 					ExcludeImageFromQuota: false,
 				})
 				Expect(err).To(MatchError(ContainSubstring("layers exceed disk quota")))
+				Expect(err).To(MatchError(imagepuller.ErrQuotaExceeded))
 			})
 
 			Context("when the disk limit is zero", func() {
@@ -147,7 +688,7 @@ var _ = Describe("Image Puller", func() {
 						},
 					}, nil)
 
-					_, err := imagePuller.Pull(logger, imagepuller.ImageSpec{
+					_, err := imagePuller.Pull(context.Background(), logger, imagepuller.ImageSpec{
 						DiskLimit:             0,
 						ExcludeImageFromQuota: false,
 					})
@@ -166,7 +707,7 @@ var _ = Describe("Image Puller", func() {
 					},
 				}, nil)
 
-				_, err := imagePuller.Pull(logger, imagepuller.ImageSpec{
+				_, err := imagePuller.Pull(context.Background(), logger, imagepuller.ImageSpec{
 					DiskLimit:             1024,
 					ExcludeImageFromQuota: true,
 				})
@@ -176,6 +717,49 @@ var _ = Describe("Image Puller", func() {
 		})
 	})
 
+	Context("when the context is cancelled", func() {
+		var ctx context.Context
+
+		BeforeEach(func() {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithCancel(context.Background())
+			cancel()
+		})
+
+		It("returns an error", func() {
+			_, err := imagePuller.Pull(ctx, logger, imagepuller.ImageSpec{})
+			Expect(err).To(MatchError(ContainSubstring("context canceled")))
+		})
+
+		It("does not unpack any layer", func() {
+			_, _ = imagePuller.Pull(ctx, logger, imagepuller.ImageSpec{})
+			Expect(fakeVolumeDriver.UnpackCallCount()).To(Equal(0))
+		})
+
+		It("passes the context to the fetcher", func() {
+			_, _ = imagePuller.Pull(ctx, logger, imagepuller.ImageSpec{})
+			Expect(fakeFetcher.ImageInfoCallCount()).To(Equal(1))
+			fetcherCtx, _ := fakeFetcher.ImageInfoArgsForCall(0)
+			Expect(fetcherCtx.Err()).To(MatchError(context.Canceled))
+		})
+
+		Context("when layers are downloaded in parallel", func() {
+			BeforeEach(func() {
+				imagePuller = imagepuller.NewImagePuller(fakeFetcher, fakeVolumeDriver, 3, fakeProgressReporter)
+			})
+
+			It("returns an error", func() {
+				_, err := imagePuller.Pull(ctx, logger, imagepuller.ImageSpec{})
+				Expect(err).To(MatchError(ContainSubstring("context canceled")))
+			})
+
+			It("does not unpack any layer", func() {
+				_, _ = imagePuller.Pull(ctx, logger, imagepuller.ImageSpec{})
+				Expect(fakeVolumeDriver.UnpackCallCount()).To(Equal(0))
+			})
+		})
+	})
+
 	Context("when fetching the list of layers fails", func() {
 		BeforeEach(func() {
 			fakeFetcher.ImageInfoReturns(imagepuller.ImageInfo{
@@ -185,7 +769,7 @@ var _ = Describe("Image Puller", func() {
 		})
 
 		It("returns an error", func() {
-			_, err := imagePuller.Pull(logger, imagepuller.ImageSpec{})
+			_, err := imagePuller.Pull(context.Background(), logger, imagepuller.ImageSpec{})
 			Expect(err).To(MatchError(ContainSubstring("failed to get list of layers")))
 		})
 	})
@@ -196,7 +780,7 @@ var _ = Describe("Image Puller", func() {
 		})
 
 		It("returns an error", func() {
-			_, err := imagePuller.Pull(logger, imagepuller.ImageSpec{})
+			_, err := imagePuller.Pull(context.Background(), logger, imagepuller.ImageSpec{})
 			Expect(err).To(MatchError(ContainSubstring("failed to create volume")))
 		})
 	})
@@ -211,11 +795,102 @@ var _ = Describe("Image Puller", func() {
 		})
 
 		It("returns an error", func() {
-			_, err := imagePuller.Pull(logger, imagepuller.ImageSpec{})
+			_, err := imagePuller.Pull(context.Background(), logger, imagepuller.ImageSpec{})
 			Expect(err).To(MatchError(ContainSubstring("failed to stream blob")))
 		})
 	})
 
+	Context("when the driver stops reading at the end of the tar archive", func() {
+		var (
+			verificationErr error
+			trailerRead     bool
+		)
+
+		BeforeEach(func() {
+			verificationErr = nil
+			trailerRead = false
+
+			fakeFetcher.StreamBlobStub = func(_ context.Context, _ lager.Logger, layerInfo imagepuller.LayerInfo) (io.ReadCloser, int64, error) {
+				archive := new(bytes.Buffer)
+				tarWriter := tar.NewWriter(archive)
+				Expect(tarWriter.WriteHeader(&tar.Header{Name: "file", Mode: 0600, Size: 4})).To(Succeed())
+				_, err := tarWriter.Write([]byte("data"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(tarWriter.Close()).To(Succeed())
+
+				// Like compressed blobs, the stream only ends, and is only
+				// verified, after the end of the archive
+				trailer := &verifyingTrailer{err: verificationErr, onRead: func() { trailerRead = true }}
+				return io.NopCloser(io.MultiReader(archive, bytes.NewReader(make([]byte, 512)), trailer)), 0, nil
+			}
+
+			fakeVolumeDriver.UnpackStub = func(_ lager.Logger, _ string, _ []string, layerTar io.Reader) (int64, error) {
+				tarReader := tar.NewReader(layerTar)
+				for {
+					if _, err := tarReader.Next(); err == io.EOF {
+						return 4, nil
+					} else if err != nil {
+						return 0, err
+					}
+				}
+			}
+		})
+
+		It("reads the rest of the stream so that the blob is verified", func() {
+			_, err := imagePuller.Pull(context.Background(), logger, imagepuller.ImageSpec{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(trailerRead).To(BeTrue())
+		})
+
+		Context("when the blob fails verification", func() {
+			BeforeEach(func() {
+				verificationErr = errors.New("layerID digest mismatch")
+			})
+
+			It("fails the pull", func() {
+				_, err := imagePuller.Pull(context.Background(), logger, imagepuller.ImageSpec{})
+				Expect(err).To(MatchError(ContainSubstring("layerID digest mismatch")))
+				Expect(fakeVolumeDriver.UnpackCallCount()).To(Equal(1))
+			})
+
+			It("does not report the layer as unpacked", func() {
+				_, err := imagePuller.Pull(context.Background(), logger, imagepuller.ImageSpec{})
+				Expect(err).To(HaveOccurred())
+				Expect(reportedProgress(fakeProgressReporter)).NotTo(ContainElement(HaveField("Event", imagepuller.ProgressUnpackFinished)))
+			})
+
+			Context("when the driver can delete layers", func() {
+				var layerDeleter *imagepullerfakes.FakeLayerDeleter
+
+				BeforeEach(func() {
+					layerDeleter = new(imagepullerfakes.FakeLayerDeleter)
+					imagePuller = imagepuller.NewImagePuller(fakeFetcher, &deletingVolumeDriver{FakeVolumeDriver: fakeVolumeDriver, FakeLayerDeleter: layerDeleter}, 1, fakeProgressReporter)
+				})
+
+				It("deletes the unverified layer", func() {
+					_, err := imagePuller.Pull(context.Background(), logger, imagepuller.ImageSpec{})
+					Expect(err).To(HaveOccurred())
+
+					Expect(layerDeleter.DeleteLayerCallCount()).To(Equal(1))
+					_, layerID := layerDeleter.DeleteLayerArgsForCall(0)
+					Expect(layerID).To(Equal("layer-111"))
+				})
+			})
+		})
+
+		Context("when layers are downloaded in parallel", func() {
+			BeforeEach(func() {
+				verificationErr = errors.New("layerID digest mismatch")
+				imagePuller = imagepuller.NewImagePuller(fakeFetcher, fakeVolumeDriver, 3, fakeProgressReporter)
+			})
+
+			It("fails the pull when the blob fails verification", func() {
+				_, err := imagePuller.Pull(context.Background(), logger, imagepuller.ImageSpec{})
+				Expect(err).To(MatchError(ContainSubstring("layerID digest mismatch")))
+			})
+		})
+	})
+
 	Context("when unpacking a child blob fails", func() {
 		BeforeEach(func() {
 			count := 0
@@ -230,8 +905,60 @@ var _ = Describe("Image Puller", func() {
 		})
 
 		It("returns an error", func() {
-			_, err := imagePuller.Pull(logger, imagepuller.ImageSpec{})
+			_, err := imagePuller.Pull(context.Background(), logger, imagepuller.ImageSpec{})
 			Expect(err).To(MatchError(ContainSubstring("failed to unpack the blob")))
 		})
 	})
 })
+
+type closeNotifyingReader struct {
+	io.Reader
+	onClose func()
+}
+
+func (r *closeNotifyingReader) Close() error {
+	r.onClose()
+	return nil
+}
+
+// verifyingTrailer ends a stream with the result of its verification, as
+// the streams of the layer source do
+type verifyingTrailer struct {
+	err    error
+	onRead func()
+}
+
+func (t *verifyingTrailer) Read(p []byte) (int, error) {
+	t.onRead()
+	if t.err != nil {
+		return 0, t.err
+	}
+	return 0, io.EOF
+}
+
+type deletingVolumeDriver struct {
+	*imagepullerfakes.FakeVolumeDriver
+	*imagepullerfakes.FakeLayerDeleter
+}
+
+type checkingVolumeDriver struct {
+	*imagepullerfakes.FakeVolumeDriver
+	*imagepullerfakes.FakeLayerChecker
+}
+
+type checkingFetcher struct {
+	*imagepullerfakes.FakeFetcher
+	*imagepullerfakes.FakeBlobChecker
+}
+
+// reportedProgress returns the reported events without their durations, which
+// depend on how long the test takes
+func reportedProgress(reporter *imagepullerfakes.FakeProgressReporter) []imagepuller.ProgressEvent {
+	events := []imagepuller.ProgressEvent{}
+	for i := 0; i < reporter.ReportCallCount(); i++ {
+		event := reporter.ReportArgsForCall(i)
+		event.Duration = 0
+		events = append(events, event)
+	}
+	return events
+}
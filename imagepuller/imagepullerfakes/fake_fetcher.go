@@ -2,6 +2,7 @@
 package imagepullerfakes
 
 import (
+	"context"
 	"io"
 	"sync"
 
@@ -20,10 +21,11 @@ type FakeFetcher struct {
 	closeReturnsOnCall map[int]struct {
 		result1 error
 	}
-	ImageInfoStub        func(lager.Logger) (imagepuller.ImageInfo, error)
+	ImageInfoStub        func(context.Context, lager.Logger) (imagepuller.ImageInfo, error)
 	imageInfoMutex       sync.RWMutex
 	imageInfoArgsForCall []struct {
-		arg1 lager.Logger
+		arg1 context.Context
+		arg2 lager.Logger
 	}
 	imageInfoReturns struct {
 		result1 imagepuller.ImageInfo
@@ -33,11 +35,12 @@ type FakeFetcher struct {
 		result1 imagepuller.ImageInfo
 		result2 error
 	}
-	StreamBlobStub        func(lager.Logger, imagepuller.LayerInfo) (io.ReadCloser, int64, error)
+	StreamBlobStub        func(context.Context, lager.Logger, imagepuller.LayerInfo) (io.ReadCloser, int64, error)
 	streamBlobMutex       sync.RWMutex
 	streamBlobArgsForCall []struct {
-		arg1 lager.Logger
-		arg2 imagepuller.LayerInfo
+		arg1 context.Context
+		arg2 lager.Logger
+		arg3 imagepuller.LayerInfo
 	}
 	streamBlobReturns struct {
 		result1 io.ReadCloser
@@ -106,18 +109,19 @@ func (fake *FakeFetcher) CloseReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
-func (fake *FakeFetcher) ImageInfo(arg1 lager.Logger) (imagepuller.ImageInfo, error) {
+func (fake *FakeFetcher) ImageInfo(arg1 context.Context, arg2 lager.Logger) (imagepuller.ImageInfo, error) {
 	fake.imageInfoMutex.Lock()
 	ret, specificReturn := fake.imageInfoReturnsOnCall[len(fake.imageInfoArgsForCall)]
 	fake.imageInfoArgsForCall = append(fake.imageInfoArgsForCall, struct {
-		arg1 lager.Logger
-	}{arg1})
+		arg1 context.Context
+		arg2 lager.Logger
+	}{arg1, arg2})
 	stub := fake.ImageInfoStub
 	fakeReturns := fake.imageInfoReturns
-	fake.recordInvocation("ImageInfo", []interface{}{arg1})
+	fake.recordInvocation("ImageInfo", []interface{}{arg1, arg2})
 	fake.imageInfoMutex.Unlock()
 	if stub != nil {
-		return stub(arg1)
+		return stub(arg1, arg2)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
@@ -131,17 +135,17 @@ func (fake *FakeFetcher) ImageInfoCallCount() int {
 	return len(fake.imageInfoArgsForCall)
 }
 
-func (fake *FakeFetcher) ImageInfoCalls(stub func(lager.Logger) (imagepuller.ImageInfo, error)) {
+func (fake *FakeFetcher) ImageInfoCalls(stub func(context.Context, lager.Logger) (imagepuller.ImageInfo, error)) {
 	fake.imageInfoMutex.Lock()
 	defer fake.imageInfoMutex.Unlock()
 	fake.ImageInfoStub = stub
 }
 
-func (fake *FakeFetcher) ImageInfoArgsForCall(i int) lager.Logger {
+func (fake *FakeFetcher) ImageInfoArgsForCall(i int) (context.Context, lager.Logger) {
 	fake.imageInfoMutex.RLock()
 	defer fake.imageInfoMutex.RUnlock()
 	argsForCall := fake.imageInfoArgsForCall[i]
-	return argsForCall.arg1
+	return argsForCall.arg1, argsForCall.arg2
 }
 
 func (fake *FakeFetcher) ImageInfoReturns(result1 imagepuller.ImageInfo, result2 error) {
@@ -170,19 +174,20 @@ func (fake *FakeFetcher) ImageInfoReturnsOnCall(i int, result1 imagepuller.Image
 	}{result1, result2}
 }
 
-func (fake *FakeFetcher) StreamBlob(arg1 lager.Logger, arg2 imagepuller.LayerInfo) (io.ReadCloser, int64, error) {
+func (fake *FakeFetcher) StreamBlob(arg1 context.Context, arg2 lager.Logger, arg3 imagepuller.LayerInfo) (io.ReadCloser, int64, error) {
 	fake.streamBlobMutex.Lock()
 	ret, specificReturn := fake.streamBlobReturnsOnCall[len(fake.streamBlobArgsForCall)]
 	fake.streamBlobArgsForCall = append(fake.streamBlobArgsForCall, struct {
-		arg1 lager.Logger
-		arg2 imagepuller.LayerInfo
-	}{arg1, arg2})
+		arg1 context.Context
+		arg2 lager.Logger
+		arg3 imagepuller.LayerInfo
+	}{arg1, arg2, arg3})
 	stub := fake.StreamBlobStub
 	fakeReturns := fake.streamBlobReturns
-	fake.recordInvocation("StreamBlob", []interface{}{arg1, arg2})
+	fake.recordInvocation("StreamBlob", []interface{}{arg1, arg2, arg3})
 	fake.streamBlobMutex.Unlock()
 	if stub != nil {
-		return stub(arg1, arg2)
+		return stub(arg1, arg2, arg3)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2, ret.result3
@@ -196,17 +201,17 @@ func (fake *FakeFetcher) StreamBlobCallCount() int {
 	return len(fake.streamBlobArgsForCall)
 }
 
-func (fake *FakeFetcher) StreamBlobCalls(stub func(lager.Logger, imagepuller.LayerInfo) (io.ReadCloser, int64, error)) {
+func (fake *FakeFetcher) StreamBlobCalls(stub func(context.Context, lager.Logger, imagepuller.LayerInfo) (io.ReadCloser, int64, error)) {
 	fake.streamBlobMutex.Lock()
 	defer fake.streamBlobMutex.Unlock()
 	fake.StreamBlobStub = stub
 }
 
-func (fake *FakeFetcher) StreamBlobArgsForCall(i int) (lager.Logger, imagepuller.LayerInfo) {
+func (fake *FakeFetcher) StreamBlobArgsForCall(i int) (context.Context, lager.Logger, imagepuller.LayerInfo) {
 	fake.streamBlobMutex.RLock()
 	defer fake.streamBlobMutex.RUnlock()
 	argsForCall := fake.streamBlobArgsForCall[i]
-	return argsForCall.arg1, argsForCall.arg2
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
 }
 
 func (fake *FakeFetcher) StreamBlobReturns(result1 io.ReadCloser, result2 int64, result3 error) {
@@ -27,3 +27,8 @@ func (d *Reader) Close() error {
 	}
 	return d.reader.Close()
 }
+
+// Opened returns whether the reader has been created
+func (d *Reader) Opened() bool {
+	return d.reader != nil
+}
@@ -77,6 +77,19 @@ var _ = Describe("Reader", func() {
 		Expect(r.Close()).To(Succeed())
 	})
 
+	It("is only opened once it is read", func() {
+		r := &Reader{
+			Create: func() (io.ReadCloser, error) {
+				return io.NopCloser(bytes.NewBuffer([]byte("contents"))), nil
+			},
+		}
+
+		Expect(r.Opened()).To(BeFalse())
+		_, err := r.Read(make([]byte, 1))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(r.Opened()).To(BeTrue())
+	})
+
 	Context("when the create function errors", func() {
 		It("bubbles the error on read", func() {
 			r := &Reader{
@@ -0,0 +1,110 @@
+package imagepuller
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Progress events. A layer is resolved first, then either skipped, because
+// the driver already has it, or downloaded, verified and unpacked. Blobs are
+// downloaded while they are unpacked, so download and unpack events of the
+// same layer interleave. Fetchers that fetch a manifest report it before any
+// layer is resolved, and report every retried registry request.
+const (
+	ProgressManifestFetched  = "manifest-fetched"
+	ProgressRetry            = "retry"
+	ProgressLayerResolved    = "layer-resolved"
+	ProgressLayerSkipped     = "layer-skipped"
+	ProgressDownloadStarted  = "download-started"
+	ProgressBytesTransferred = "bytes-transferred"
+	ProgressLayerVerified    = "layer-verified"
+	ProgressUnpackStarted    = "unpack-started"
+	ProgressUnpackFinished   = "unpack-finished"
+	ProgressPullFinished     = "pull-finished"
+)
+
+// ProgressEvent describes the progress of a pull. Layer numbers start at 1,
+// parents first; events reported by fetchers only identify the layer by its
+// blob ID.
+type ProgressEvent struct {
+	Timestamp time.Time `json:"timestamp"`
+	Event     string    `json:"event"`
+	BlobID    string    `json:"blob_id,omitempty"`
+	ChainID   string    `json:"chain_id,omitempty"`
+	Layer     int       `json:"layer,omitempty"`
+	// Layers and TotalSize are the number of layers of the image and the sum
+	// of their blob sizes
+	Layers    int   `json:"layers,omitempty"`
+	TotalSize int64 `json:"total_size,omitempty"`
+	// Size is the size of the blob
+	Size int64 `json:"size,omitempty"`
+	// Bytes is the number of blob bytes transferred so far, the unpacked size
+	// of the layer or, once the pull finished, of the whole image
+	Bytes int64 `json:"bytes,omitempty"`
+	// Cached is set when the blob is read from the blob cache rather than
+	// downloaded
+	Cached bool `json:"cached,omitempty"`
+	// Duration is how many seconds fetching the manifest, downloading and
+	// verifying the blob, unpacking the layer or the whole pull took
+	Duration float64 `json:"duration_seconds,omitempty"`
+	// Attempt is the number of the attempt a retried request is about to make
+	Attempt int `json:"attempt,omitempty"`
+}
+
+// ProgressReporter receives the progress events of a pull. It may be called
+// from several goroutines at the same time.
+type ProgressReporter interface {
+	Report(event ProgressEvent)
+}
+
+// DiscardProgress is a ProgressReporter that ignores all events
+var DiscardProgress ProgressReporter = discardProgress{}
+
+type discardProgress struct{}
+
+func (discardProgress) Report(ProgressEvent) {}
+
+type multiProgressReporter []ProgressReporter
+
+// MultiProgressReporter returns a ProgressReporter that reports every event to
+// all of the given reporters that are not nil
+func MultiProgressReporter(reporters ...ProgressReporter) ProgressReporter {
+	multiReporter := multiProgressReporter{}
+	for _, reporter := range reporters {
+		if reporter != nil {
+			multiReporter = append(multiReporter, reporter)
+		}
+	}
+	return multiReporter
+}
+
+func (r multiProgressReporter) Report(event ProgressEvent) {
+	for _, reporter := range r {
+		reporter.Report(event)
+	}
+}
+
+type jsonProgressReporter struct {
+	mutex   sync.Mutex
+	encoder *json.Encoder
+}
+
+// NewJSONProgressReporter returns a ProgressReporter that writes every event
+// to w as a line of JSON. Events are timestamped when they have no timestamp.
+// Failing to write an event does not fail the pull, so write errors are
+// ignored.
+func NewJSONProgressReporter(w io.Writer) ProgressReporter {
+	return &jsonProgressReporter{encoder: json.NewEncoder(w)}
+}
+
+func (r *jsonProgressReporter) Report(event ProgressEvent) {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now().UTC()
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	_ = r.encoder.Encode(event)
+}
@@ -0,0 +1,70 @@
+package imagepuller_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"code.cloudfoundry.org/groot/imagepuller"
+	"code.cloudfoundry.org/groot/imagepuller/imagepullerfakes"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("JSON progress reporter", func() {
+	var (
+		buffer   *bytes.Buffer
+		reporter imagepuller.ProgressReporter
+	)
+
+	BeforeEach(func() {
+		buffer = new(bytes.Buffer)
+		reporter = imagepuller.NewJSONProgressReporter(buffer)
+	})
+
+	It("writes every event as a line of JSON", func() {
+		reporter.Report(imagepuller.ProgressEvent{Event: imagepuller.ProgressLayerResolved, BlobID: "sha256:blob", Layer: 1, Layers: 2, TotalSize: 300, Size: 100})
+		reporter.Report(imagepuller.ProgressEvent{Event: imagepuller.ProgressPullFinished, Layers: 2, TotalSize: 300, Bytes: 1000})
+
+		lines := bytes.Split(bytes.TrimSpace(buffer.Bytes()), []byte("\n"))
+		Expect(lines).To(HaveLen(2))
+
+		var event map[string]interface{}
+		Expect(json.Unmarshal(lines[0], &event)).To(Succeed())
+		Expect(event).To(HaveKeyWithValue("event", "layer-resolved"))
+		Expect(event).To(HaveKeyWithValue("blob_id", "sha256:blob"))
+		Expect(event).To(HaveKeyWithValue("layer", BeNumerically("==", 1)))
+		Expect(event).To(HaveKeyWithValue("layers", BeNumerically("==", 2)))
+		Expect(event).To(HaveKeyWithValue("total_size", BeNumerically("==", 300)))
+		Expect(event).To(HaveKeyWithValue("size", BeNumerically("==", 100)))
+		Expect(event).NotTo(HaveKey("bytes"))
+
+		Expect(json.Unmarshal(lines[1], &event)).To(Succeed())
+		Expect(event).To(HaveKeyWithValue("event", "pull-finished"))
+		Expect(event).To(HaveKeyWithValue("bytes", BeNumerically("==", 1000)))
+	})
+
+	It("timestamps the events", func() {
+		reporter.Report(imagepuller.ProgressEvent{Event: imagepuller.ProgressLayerResolved})
+
+		var event imagepuller.ProgressEvent
+		Expect(json.Unmarshal(buffer.Bytes(), &event)).To(Succeed())
+		Expect(event.Timestamp).To(BeTemporally("~", time.Now(), time.Minute))
+	})
+})
+
+var _ = Describe("MultiProgressReporter", func() {
+	It("reports every event to all reporters that are not nil", func() {
+		first := new(imagepullerfakes.FakeProgressReporter)
+		second := new(imagepullerfakes.FakeProgressReporter)
+		reporter := imagepuller.MultiProgressReporter(first, nil, second)
+
+		event := imagepuller.ProgressEvent{Event: imagepuller.ProgressRetry, Attempt: 2}
+		reporter.Report(event)
+
+		Expect(first.ReportCallCount()).To(Equal(1))
+		Expect(first.ReportArgsForCall(0)).To(Equal(event))
+		Expect(second.ReportCallCount()).To(Equal(1))
+		Expect(second.ReportArgsForCall(0)).To(Equal(event))
+	})
+})
@@ -0,0 +1,78 @@
+package groot
+
+import (
+	"context"
+
+	imgspec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+// ImageInspection describes an image as it would be pulled
+type ImageInspection struct {
+	ManifestDigest string            `json:"manifest_digest"`
+	MediaType      string            `json:"media_type"`
+	ConfigDigest   string            `json:"config_digest"`
+	Platform       imgspec.Platform  `json:"platform"`
+	Config         ImageConfig       `json:"config"`
+	Layers         []LayerInspection `json:"layers"`
+}
+
+type ImageConfig struct {
+	Env        []string          `json:"env"`
+	User       string            `json:"user"`
+	Entrypoint []string          `json:"entrypoint"`
+	Labels     map[string]string `json:"labels"`
+}
+
+type LayerInspection struct {
+	BlobID    string `json:"blob_id"`
+	DiffID    string `json:"diff_id"`
+	ChainID   string `json:"chain_id"`
+	Size      int64  `json:"size"`
+	MediaType string `json:"media_type"`
+}
+
+func (g *Groot) Inspect(ctx context.Context) (ImageInspection, error) {
+	g.Logger = g.Logger.Session("inspect")
+	g.Logger.Debug("starting")
+	defer g.Logger.Debug("ending")
+
+	imageInfo, err := g.ImagePuller.ImageInfo(ctx, g.Logger)
+	if err != nil {
+		return ImageInspection{}, errors.Wrap(err, "inspecting image")
+	}
+
+	layers := []LayerInspection{}
+	for _, layerInfo := range imageInfo.LayerInfos {
+		layers = append(layers, LayerInspection{
+			BlobID:    layerInfo.BlobID,
+			DiffID:    layerInfo.DiffID,
+			ChainID:   layerInfo.ChainID,
+			Size:      layerInfo.Size,
+			MediaType: layerInfo.MediaType,
+		})
+	}
+
+	return ImageInspection{
+		ManifestDigest: imageInfo.ManifestDigest,
+		MediaType:      imageInfo.MediaType,
+		ConfigDigest:   imageInfo.ConfigDigest,
+		Platform:       imagePlatform(imageInfo.Config),
+		Config: ImageConfig{
+			Env:        imageInfo.Config.Config.Env,
+			User:       imageInfo.Config.Config.User,
+			Entrypoint: imageInfo.Config.Config.Entrypoint,
+			Labels:     imageInfo.Config.Config.Labels,
+		},
+		Layers: layers,
+	}, nil
+}
+
+func imagePlatform(config imgspec.Image) imgspec.Platform {
+	return imgspec.Platform{
+		OS:           config.OS,
+		OSVersion:    config.OSVersion,
+		Architecture: config.Architecture,
+		Variant:      config.Variant,
+	}
+}
@@ -0,0 +1,95 @@
+package groot_test
+
+import (
+	"context"
+
+	"code.cloudfoundry.org/groot"
+	"code.cloudfoundry.org/groot/grootfakes"
+	"code.cloudfoundry.org/groot/imagepuller"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	imgspec "github.com/opencontainers/image-spec/specs-go/v1"
+	errors "github.com/pkg/errors"
+)
+
+var _ = Describe("Inspect", func() {
+	var (
+		imagePuller *grootfakes.FakeImagePuller
+		driver      *grootfakes.FakeDriver
+
+		logger *lagertest.TestLogger
+		g      *groot.Groot
+	)
+
+	BeforeEach(func() {
+		imagePuller = new(grootfakes.FakeImagePuller)
+		driver = new(grootfakes.FakeDriver)
+
+		imagePuller.ImageInfoReturns(imagepuller.ImageInfo{
+			ManifestDigest: "sha256:manifest",
+			MediaType:      imgspec.MediaTypeImageManifest,
+			Config: imgspec.Image{
+				Platform: imgspec.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"},
+				Config: imgspec.ImageConfig{
+					Env:        []string{"PATH=/bin"},
+					User:       "vcap",
+					Entrypoint: []string{"/bin/sh"},
+					Labels:     map[string]string{"some": "label"},
+				},
+			},
+			LayerInfos: []imagepuller.LayerInfo{
+				{BlobID: "sha256:blob-1", DiffID: "diff-1", ChainID: "chain-1", Size: 100, MediaType: imgspec.MediaTypeImageLayerGzip},
+				{BlobID: "sha256:blob-2", DiffID: "diff-2", ChainID: "chain-2", ParentChainID: "chain-1", Size: 200, MediaType: imgspec.MediaTypeImageLayerZstd},
+			},
+		}, nil)
+
+		logger = lagertest.NewTestLogger("groot")
+		g = &groot.Groot{
+			Driver:      driver,
+			Logger:      logger,
+			ImagePuller: imagePuller,
+		}
+	})
+
+	It("describes the image", func() {
+		inspection, err := g.Inspect(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(inspection).To(Equal(groot.ImageInspection{
+			ManifestDigest: "sha256:manifest",
+			MediaType:      imgspec.MediaTypeImageManifest,
+			Platform:       imgspec.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"},
+			Config: groot.ImageConfig{
+				Env:        []string{"PATH=/bin"},
+				User:       "vcap",
+				Entrypoint: []string{"/bin/sh"},
+				Labels:     map[string]string{"some": "label"},
+			},
+			Layers: []groot.LayerInspection{
+				{BlobID: "sha256:blob-1", DiffID: "diff-1", ChainID: "chain-1", Size: 100, MediaType: imgspec.MediaTypeImageLayerGzip},
+				{BlobID: "sha256:blob-2", DiffID: "diff-2", ChainID: "chain-2", Size: 200, MediaType: imgspec.MediaTypeImageLayerZstd},
+			},
+		}))
+	})
+
+	It("does not pull the image", func() {
+		_, err := g.Inspect(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(imagePuller.PullCallCount()).To(BeZero())
+		Expect(driver.UnpackCallCount()).To(BeZero())
+		Expect(driver.BundleCallCount()).To(BeZero())
+	})
+
+	Context("when fetching the image info fails", func() {
+		BeforeEach(func() {
+			imagePuller.ImageInfoReturns(imagepuller.ImageInfo{}, errors.New("failed"))
+		})
+
+		It("returns the error", func() {
+			_, err := g.Inspect(context.Background())
+			Expect(err).To(MatchError("inspecting image: failed"))
+		})
+	})
+})
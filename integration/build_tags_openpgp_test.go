@@ -0,0 +1,8 @@
+//go:build containers_image_openpgp
+
+package integration_test
+
+const (
+	footBuildTags                  = "containers_image_openpgp"
+	signatureVerificationSupported = true
+)
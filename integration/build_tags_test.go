@@ -0,0 +1,11 @@
+//go:build !containers_image_openpgp
+
+package integration_test
+
+// foot is built with the build tags the integration tests are run with, so
+// that signature policies are only enforced when the tests are run with the
+// containers_image_openpgp build tag
+const (
+	footBuildTags                  = ""
+	signatureVerificationSupported = false
+)
@@ -6,6 +6,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"time"
 
 	"code.cloudfoundry.org/groot"
 	"code.cloudfoundry.org/lager/v3"
@@ -25,13 +26,37 @@ func (t *Foot) Unpack(logger lager.Logger, id string, parentIDs []string, layerT
 	}
 
 	layerTarContents, err := io.ReadAll(layerTar)
-	must(err)
+	if err != nil {
+		return 0, err
+	}
 	saveObject([]interface{}{
 		UnpackArgs{ID: id, ParentIDs: parentIDs, LayerTarContents: layerTarContents},
 	}, t.pathTo(UnpackArgsFileName))
 	return int64(len(layerTarContents)), nil
 }
 
+func (t *Foot) LayerExists(logger lager.Logger, id string) (bool, int64, error) {
+	logger.Info("layer-exists-info")
+	logger.Debug("layer-exists-debug")
+
+	if _, exists := os.LookupEnv("FOOT_LAYER_EXISTS_ERROR"); exists {
+		return false, 0, errors.New("layer-exists-err")
+	}
+
+	saveObject([]interface{}{
+		ExistsArgs{LayerID: id},
+	}, t.pathTo(ExistsArgsFileName))
+
+	if _, exists := os.LookupEnv("FOOT_LAYER_EXISTS"); exists {
+		now := time.Now()
+		if err := os.Chtimes(t.pathTo(UnpackArgsFileName), now, now); err != nil && !os.IsNotExist(err) {
+			return false, 0, err
+		}
+		return true, ExistingLayerSize, nil
+	}
+	return false, 0, nil
+}
+
 func (t *Foot) Bundle(logger lager.Logger, id string, layerIDs []string, diskLimit int64) (specs.Spec, error) {
 	logger.Info("bundle-info")
 	logger.Debug("bundle-debug")
@@ -88,6 +113,143 @@ func (t *Foot) WriteMetadata(logger lager.Logger, id string, volumeData groot.Im
 	return nil
 }
 
+// ReadMetadata returns the metadata last written for the bundle
+func (t *Foot) ReadMetadata(logger lager.Logger, id string) (groot.ImageMetadata, error) {
+	logger.Info("read-metadata-info")
+	logger.Debug("read-metadata-debug")
+
+	if _, exists := os.LookupEnv("FOOT_READ_METADATA_ERROR"); exists {
+		return groot.ImageMetadata{}, errors.New("read-metadata-err")
+	}
+
+	var writeMetadataCalls WriteMetadataCalls
+	if err := loadCalls(&writeMetadataCalls, t.pathTo(WriteMetadataArgsFileName)); err != nil {
+		return groot.ImageMetadata{}, err
+	}
+
+	for i := len(writeMetadataCalls) - 1; i >= 0; i-- {
+		if writeMetadataCalls[i].ID == id {
+			return writeMetadataCalls[i].VolumeData, nil
+		}
+	}
+	return groot.ImageMetadata{}, errors.New("bundle not found")
+}
+
+// ListBundles returns the bundles that metadata was written for and that were
+// not deleted since
+func (t *Foot) ListBundles(logger lager.Logger) ([]groot.Bundle, error) {
+	logger.Info("list-bundles-info")
+	logger.Debug("list-bundles-debug")
+
+	if _, exists := os.LookupEnv("FOOT_LIST_BUNDLES_ERROR"); exists {
+		return nil, errors.New("list-bundles-err")
+	}
+
+	var writeMetadataCalls WriteMetadataCalls
+	if err := loadCalls(&writeMetadataCalls, t.pathTo(WriteMetadataArgsFileName)); err != nil {
+		return nil, err
+	}
+
+	var deleteCalls DeleteCalls
+	if err := loadCalls(&deleteCalls, t.pathTo(DeleteArgsFileName)); err != nil {
+		return nil, err
+	}
+
+	deleted := map[string]bool{}
+	for _, call := range deleteCalls {
+		deleted[call.BundleID] = true
+	}
+
+	bundles := []groot.Bundle{}
+	for _, call := range writeMetadataCalls {
+		if !deleted[call.ID] {
+			bundles = append(bundles, groot.Bundle{Handle: call.ID, ImageMetadata: call.VolumeData})
+		}
+	}
+	return bundles, nil
+}
+
+// ListLayers returns the layers that were unpacked and not deleted since. They
+// were all last used when the last layer was unpacked or reported to exist.
+func (t *Foot) ListLayers(logger lager.Logger) ([]groot.Layer, error) {
+	logger.Info("list-layers-info")
+	logger.Debug("list-layers-debug")
+
+	if _, exists := os.LookupEnv("FOOT_LIST_LAYERS_ERROR"); exists {
+		return nil, errors.New("list-layers-err")
+	}
+
+	var unpackCalls UnpackCalls
+	if err := loadCalls(&unpackCalls, t.pathTo(UnpackArgsFileName)); err != nil {
+		return nil, err
+	}
+	if len(unpackCalls) == 0 {
+		return []groot.Layer{}, nil
+	}
+
+	stat, err := os.Stat(t.pathTo(UnpackArgsFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	var deleteLayerCalls DeleteLayerCalls
+	if err := loadCalls(&deleteLayerCalls, t.pathTo(DeleteLayerArgsFileName)); err != nil {
+		return nil, err
+	}
+
+	deleted := map[string]bool{}
+	for _, call := range deleteLayerCalls {
+		deleted[call.LayerID] = true
+	}
+
+	layers := []groot.Layer{}
+	for _, call := range unpackCalls {
+		if deleted[call.ID] {
+			continue
+		}
+
+		layer := groot.Layer{ID: call.ID, Size: int64(len(call.LayerTarContents)), LastUsed: stat.ModTime()}
+		if len(call.ParentIDs) > 0 {
+			layer.ParentID = call.ParentIDs[len(call.ParentIDs)-1]
+		}
+		layers = append(layers, layer)
+	}
+	return layers, nil
+}
+
+// BundleLayerIDs returns the layer IDs the bundle was last created with
+func (t *Foot) BundleLayerIDs(logger lager.Logger, id string) ([]string, error) {
+	logger.Info("bundle-layer-ids-info")
+	logger.Debug("bundle-layer-ids-debug")
+
+	var bundleCalls BundleCalls
+	if err := loadCalls(&bundleCalls, t.pathTo(BundleArgsFileName)); err != nil {
+		return nil, err
+	}
+
+	layerIDs := []string{}
+	for _, call := range bundleCalls {
+		if call.ID == id {
+			layerIDs = call.LayerIDs
+		}
+	}
+	return layerIDs, nil
+}
+
+func (t *Foot) DeleteLayer(logger lager.Logger, id string) error {
+	logger.Info("delete-layer-info")
+	logger.Debug("delete-layer-debug")
+
+	if _, exists := os.LookupEnv("FOOT_DELETE_LAYER_ERROR"); exists {
+		return errors.New("delete-layer-err")
+	}
+
+	saveObject([]interface{}{
+		DeleteLayerArgs{LayerID: id},
+	}, t.pathTo(DeleteLayerArgsFileName))
+	return nil
+}
+
 const (
 	UnpackArgsFileName        = "unpack-args"
 	BundleArgsFileName        = "bundle-args"
@@ -95,8 +257,11 @@ const (
 	DeleteArgsFileName        = "delete-args"
 	StatsArgsFileName         = "stats-args"
 	WriteMetadataArgsFileName = "write-metadata-args"
+	DeleteLayerArgsFileName   = "delete-layer-args"
 )
 
+const ExistingLayerSize = 1000
+
 var (
 	BundleRuntimeSpec   = specs.Spec{Root: &specs.Root{Path: "foot-rootfs-path"}}
 	ReturnedVolumeStats = groot.VolumeStats{DiskUsage: groot.DiskUsage{
@@ -115,6 +280,11 @@ type DeleteArgs struct {
 	BundleID string
 }
 
+type DeleteLayerCalls []DeleteLayerArgs
+type DeleteLayerArgs struct {
+	LayerID string
+}
+
 type UnpackCalls []UnpackArgs
 type UnpackArgs struct {
 	ID               string
@@ -162,6 +332,17 @@ func must(err error) {
 	}
 }
 
+func loadCalls(calls interface{}, pathname string) error {
+	contents, err := os.ReadFile(pathname)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(contents, calls)
+}
+
 func loadObject(obj *[]interface{}, pathname string) {
 	file, err := os.Open(pathname)
 	must(err)
@@ -2,19 +2,44 @@ package integration_test
 
 import (
 	"compress/gzip"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
 	"fmt"
 	"io"
+	"math/big"
+	"net"
+	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"strings"
+	"sync"
 	"time"
 
-	"code.cloudfoundry.org/groot"
+	"code.cloudfoundry.org/groot/imagepuller"
 	"code.cloudfoundry.org/groot/integration/cmd/foot/foot"
+	"code.cloudfoundry.org/groot/testhelpers"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/ghttp"
+	imgspec "github.com/opencontainers/image-spec/specs-go/v1"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	collectortracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
 )
 
 var _ = Describe("create", func() {
@@ -137,7 +162,35 @@ var _ = Describe("create", func() {
 			unmarshalFile(filepath.Join(driverStoreDir, foot.WriteMetadataArgsFileName), &writeMetadataArgs)
 
 			Expect(writeMetadataArgs[0].ID).To(Equal("some-handle"))
-			Expect(writeMetadataArgs[0].VolumeData).To(Equal(groot.ImageMetadata{Size: imageSize}))
+			Expect(writeMetadataArgs[0].VolumeData.Size).To(Equal(imageSize))
+			Expect(writeMetadataArgs[0].VolumeData.ImageURL).To(Equal(rootfsURI))
+		})
+
+		It("calls driver.LayerExists() with the layer ID", func() {
+			var unpackArgs foot.UnpackCalls
+			unmarshalFile(filepath.Join(driverStoreDir, foot.UnpackArgsFileName), &unpackArgs)
+
+			var existsArgs foot.ExistsCalls
+			unmarshalFile(filepath.Join(driverStoreDir, foot.ExistsArgsFileName), &existsArgs)
+			Expect(existsArgs).To(HaveLen(1))
+			Expect(existsArgs[0].LayerID).To(Equal(unpackArgs[0].ID))
+		})
+
+		Context("when the driver already has the layer", func() {
+			BeforeEach(func() {
+				footCmd.Env = append(os.Environ(), "FOOT_LAYER_EXISTS=true")
+			})
+
+			It("does not call driver.Unpack()", func() {
+				Expect(footCmdError).NotTo(HaveOccurred())
+				Expect(filepath.Join(driverStoreDir, foot.UnpackArgsFileName)).NotTo(BeAnExistingFile())
+			})
+
+			It("calls driver.WriteMetadata() with the size of the existing layer", func() {
+				var writeMetadataArgs foot.WriteMetadataCalls
+				unmarshalFile(filepath.Join(driverStoreDir, foot.WriteMetadataArgsFileName), &writeMetadataArgs)
+				Expect(writeMetadataArgs[0].VolumeData.Size).To(Equal(int64(foot.ExistingLayerSize)))
+			})
 		})
 
 		Context("--disk-limit-size-bytes is given", func() {
@@ -145,101 +198,1415 @@ var _ = Describe("create", func() {
 				footCmd = newFootCommand(configFilePath, driverStoreDir, "create", rootfsURI, "some-handle", "--disk-limit-size-bytes", "500")
 			})
 
-			It("calls driver.Bundle() with expected args", func() {
-				var unpackArgs foot.UnpackCalls
-				unmarshalFile(filepath.Join(driverStoreDir, foot.UnpackArgsFileName), &unpackArgs)
+			It("calls driver.Bundle() with expected args", func() {
+				var unpackArgs foot.UnpackCalls
+				unmarshalFile(filepath.Join(driverStoreDir, foot.UnpackArgsFileName), &unpackArgs)
+
+				var bundleArgs foot.BundleCalls
+				unmarshalFile(filepath.Join(driverStoreDir, foot.BundleArgsFileName), &bundleArgs)
+				unpackLayerIds := []string{}
+				for _, call := range unpackArgs {
+					unpackLayerIds = append(unpackLayerIds, call.ID)
+				}
+				Expect(bundleArgs[0].ID).To(Equal("some-handle"))
+				Expect(bundleArgs[0].LayerIDs).To(ConsistOf(unpackLayerIds))
+				Expect(bundleArgs[0].DiskLimit).To(Equal(500 - imageSize))
+			})
+
+			Context("--exclude-image-from-quota is given as well", func() {
+				BeforeEach(func() {
+					footCmd = newFootCommand(configFilePath, driverStoreDir, "create", rootfsURI, "some-handle", "--disk-limit-size-bytes", "500", "--exclude-image-from-quota")
+				})
+
+				It("calls driver.Bundle() with expected args", func() {
+					var unpackArgs foot.UnpackCalls
+					unmarshalFile(filepath.Join(driverStoreDir, foot.UnpackArgsFileName), &unpackArgs)
+
+					var bundleArgs foot.BundleCalls
+					unmarshalFile(filepath.Join(driverStoreDir, foot.BundleArgsFileName), &bundleArgs)
+					unpackLayerIds := []string{}
+					for _, call := range unpackArgs {
+						unpackLayerIds = append(unpackLayerIds, call.ID)
+					}
+					Expect(bundleArgs[0].ID).To(Equal("some-handle"))
+					Expect(bundleArgs[0].LayerIDs).To(ConsistOf(unpackLayerIds))
+					Expect(bundleArgs[0].DiskLimit).To(Equal(int64(500)))
+				})
+			})
+		})
+	})
+
+	Describe("Remote images", func() {
+		var workDir string
+
+		BeforeEach(func() {
+			var err error
+			workDir, err = os.Getwd()
+			Expect(err).NotTo(HaveOccurred())
+			rootfsURI = fmt.Sprintf("oci:///%s/oci-test-images/opq-whiteouts-busybox:latest", workDir)
+		})
+
+		Context("when the image is pulled", func() {
+			BeforeEach(func() {
+				footCmd = newFootCommand(configFilePath, driverStoreDir, "create", rootfsURI, "some-handle")
+			})
+
+			It("records the image digests in the metadata", func() {
+				Expect(footCmdError).NotTo(HaveOccurred())
+
+				var writeMetadataArgs foot.WriteMetadataCalls
+				unmarshalFile(filepath.Join(driverStoreDir, foot.WriteMetadataArgsFileName), &writeMetadataArgs)
+				metadata := writeMetadataArgs[0].VolumeData
+				Expect(metadata.ImageURL).To(Equal(rootfsURI))
+				Expect(metadata.ManifestDigest).To(Equal("sha256:9c90ae0cffa9d1426e83a516183f0267e03edbb765efc5fb0c0dccc8edca4f15"))
+				Expect(metadata.ConfigDigest).To(Equal("sha256:10c8f0eb9d1af08fe6e3b8dbd29e5aa2b6ecfa491ecd04ed90de19a4ac22de7b"))
+				Expect(metadata.ChainIDs).To(Equal([]string{
+					"e88b3f82283bc59d5e0df427c824e9f95557e661fcb0ea15fb0fb6f97760f9d9",
+					"43041102f82e9755441c9732a60ef1c7f6ef6516ad6cd5e07f9a49f8544c3b57",
+				}))
+				Expect(metadata.Platform).To(Equal(imgspec.Platform{OS: "linux", Architecture: "amd64"}))
+				Expect(metadata.CreatedAt).To(BeTemporally("~", time.Now(), time.Minute))
+			})
+
+			It("applies the image config to the returned runtime spec", func() {
+				out, err := newFootCommand(configFilePath, driverStoreDir, "create", rootfsURI, "another-handle").Output()
+				Expect(err).NotTo(HaveOccurred())
+
+				var runtimeSpec specs.Spec
+				Expect(json.Unmarshal(out, &runtimeSpec)).To(Succeed())
+				Expect(runtimeSpec.Process.Args).To(Equal([]string{"sh"}))
+				Expect(runtimeSpec.Process.Env).To(ContainElement("PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"))
+			})
+		})
+
+		Context("when the image has multiple layers", func() {
+			BeforeEach(func() {
+				footCmd = newFootCommand(configFilePath, driverStoreDir, "create", rootfsURI, "some-handle")
+			})
+
+			It("correctly passes parent IDs to each driver.Unpack() call", func() {
+				var args foot.UnpackCalls
+				unmarshalFile(filepath.Join(driverStoreDir, foot.UnpackArgsFileName), &args)
+
+				chainIDs := []string{}
+				for _, a := range args {
+					Expect(a.ParentIDs).To(Equal(chainIDs))
+					chainIDs = append(chainIDs, a.ID)
+				}
+			})
+
+			It("calls driver.LayerExists() with the chain ID of every layer", func() {
+				var unpackArgs foot.UnpackCalls
+				unmarshalFile(filepath.Join(driverStoreDir, foot.UnpackArgsFileName), &unpackArgs)
+				Expect(unpackArgs).To(HaveLen(2))
+
+				var existsArgs foot.ExistsCalls
+				unmarshalFile(filepath.Join(driverStoreDir, foot.ExistsArgsFileName), &existsArgs)
+				Expect(existsArgs).To(Equal(foot.ExistsCalls{{LayerID: unpackArgs[0].ID}, {LayerID: unpackArgs[1].ID}}))
+			})
+		})
+
+		Context("when the image is a multi-architecture image", func() {
+			BeforeEach(func() {
+				rootfsURI = fmt.Sprintf("oci:///%s/oci-test-images/multi-arch:latest", workDir)
+				footCmd = newFootCommand(configFilePath, driverStoreDir, "create", rootfsURI, "some-handle", "--platform", "linux/arm64/v8")
+			})
+
+			It("unpacks the layers of the image for the given platform", func() {
+				Expect(footCmdError).NotTo(HaveOccurred())
+
+				var args foot.UnpackCalls
+				unmarshalFile(filepath.Join(driverStoreDir, foot.UnpackArgsFileName), &args)
+				Expect(args).To(HaveLen(1))
+			})
+
+			Context("when the platform is set in the config", func() {
+				BeforeEach(func() {
+					writeFile(configFilePath, "platform:\n  os: linux\n  architecture: arm64\n  variant: v8\n")
+					footCmd = newFootCommand(configFilePath, driverStoreDir, "create", rootfsURI, "some-handle")
+				})
+
+				It("unpacks the layers of the image for that platform", func() {
+					Expect(footCmdError).NotTo(HaveOccurred())
+
+					var args foot.UnpackCalls
+					unmarshalFile(filepath.Join(driverStoreDir, foot.UnpackArgsFileName), &args)
+					Expect(args).To(HaveLen(1))
+				})
+
+				Context("when --platform is given as well", func() {
+					BeforeEach(func() {
+						footCmd = newFootCommand(configFilePath, driverStoreDir, "create", rootfsURI, "some-handle", "--platform", "linux/amd64")
+					})
+
+					It("uses the platform from the flag", func() {
+						Expect(footCmdError).NotTo(HaveOccurred())
+
+						var args foot.UnpackCalls
+						unmarshalFile(filepath.Join(driverStoreDir, foot.UnpackArgsFileName), &args)
+						Expect(args).To(HaveLen(2))
+					})
+				})
+			})
+
+			Context("when the image has no match for the platform", func() {
+				BeforeEach(func() {
+					footCmd = newFootCommand(configFilePath, driverStoreDir, "create", rootfsURI, "some-handle", "--platform", "linux/s390x")
+				})
+
+				It("returns an error listing the available platforms", func() {
+					expectErrorOutput("no image found for platform linux/s390x, available platforms: linux/amd64, linux/arm64/v8")
+				})
+			})
+
+			Context("when the platform is invalid", func() {
+				BeforeEach(func() {
+					footCmd = newFootCommand(configFilePath, driverStoreDir, "create", rootfsURI, "some-handle", "--platform", "linux")
+				})
+
+				It("returns an error", func() {
+					expectErrorOutput(`invalid platform "linux", expected os/architecture\[/variant\]`)
+				})
+			})
+		})
+
+		Context("when the image has zstd compressed and uncompressed layers", func() {
+			BeforeEach(func() {
+				rootfsURI = fmt.Sprintf("oci:///%s/oci-test-images/zstd-busybox:latest", workDir)
+				footCmd = newFootCommand(configFilePath, driverStoreDir, "create", rootfsURI, "some-handle")
+			})
+
+			It("unpacks the uncompressed layers", func() {
+				Expect(footCmdError).NotTo(HaveOccurred())
+
+				var args foot.UnpackCalls
+				unmarshalFile(filepath.Join(driverStoreDir, foot.UnpackArgsFileName), &args)
+				Expect(args).To(HaveLen(2))
+
+				blobsPath := fmt.Sprintf("%s/oci-test-images/opq-whiteouts-busybox/blobs/sha256", workDir)
+				firstBlobSize := getUncompressedBlobSize(filepath.Join(blobsPath, "56bec22e355981d8ba0878c6c2f23b21f422f30ab0aba188b54f1ffeff59c190"))
+				Expect(args[0].LayerTarContents).To(HaveLen(int(firstBlobSize)))
+				Expect(args[1].LayerTarContents).To(HaveLen(2048))
+			})
+		})
+
+		Context("when layers are downloaded in parallel", func() {
+			BeforeEach(func() {
+				writeFile(configFilePath, "layer_download_workers: 4")
+				footCmd = newFootCommand(configFilePath, driverStoreDir, "create", rootfsURI, "some-handle")
+			})
+
+			It("unpacks the layers in order with the correct parent IDs", func() {
+				Expect(footCmdError).NotTo(HaveOccurred())
+
+				var args foot.UnpackCalls
+				unmarshalFile(filepath.Join(driverStoreDir, foot.UnpackArgsFileName), &args)
+				Expect(args).To(HaveLen(2))
+
+				chainIDs := []string{}
+				for _, a := range args {
+					Expect(a.ParentIDs).To(Equal(chainIDs))
+					chainIDs = append(chainIDs, a.ID)
+				}
+			})
+		})
+
+		Context("when blobs are streamed", func() {
+			BeforeEach(func() {
+				writeFile(configFilePath, "stream_blobs: true")
+				footCmd = newFootCommand(configFilePath, driverStoreDir, "create", rootfsURI, "some-handle")
+			})
+
+			It("unpacks the uncompressed layers", func() {
+				Expect(footCmdError).NotTo(HaveOccurred())
+
+				var args foot.UnpackCalls
+				unmarshalFile(filepath.Join(driverStoreDir, foot.UnpackArgsFileName), &args)
+				Expect(args).To(HaveLen(2))
+
+				blobsPath := fmt.Sprintf("%s/oci-test-images/opq-whiteouts-busybox/blobs/sha256", workDir)
+				firstBlobSize := getUncompressedBlobSize(filepath.Join(blobsPath, "56bec22e355981d8ba0878c6c2f23b21f422f30ab0aba188b54f1ffeff59c190"))
+				Expect(args[0].LayerTarContents).To(HaveLen(int(firstBlobSize)))
+			})
+		})
+
+		Context("when the pull times out", func() {
+			BeforeEach(func() {
+				writeFile(configFilePath, "pull_timeout: 1ns")
+				footCmd = newFootCommand(configFilePath, driverStoreDir, "create", rootfsURI, "some-handle")
+			})
+
+			It("returns an error", func() {
+				expectErrorOutput("context deadline exceeded")
+			})
+
+			It("does not unpack any layer", func() {
+				Expect(filepath.Join(driverStoreDir, foot.UnpackArgsFileName)).NotTo(BeAnExistingFile())
+			})
+		})
+
+		Context("when --disk-limit-size-bytes is less than compressed image size and exclude-image-from-quota is set", func() {
+			BeforeEach(func() {
+				footCmd = newFootCommand(configFilePath, driverStoreDir, "create", rootfsURI, "some-handle", "--disk-limit-size-bytes", "1", "--exclude-image-from-quota")
+			})
+
+			It("succeeds", func() {
+				var args foot.UnpackCalls
+				unmarshalFile(filepath.Join(driverStoreDir, foot.UnpackArgsFileName), &args)
+				Expect(len(args)).NotTo(Equal(0))
+			})
+		})
+
+		Context("when --disk-limit-size-bytes is sufficiently large", func() {
+			BeforeEach(func() {
+				footCmd = newFootCommand(configFilePath, driverStoreDir, "create", rootfsURI, "some-handle", "--disk-limit-size-bytes", "99999999")
+			})
+
+			It("succeeds", func() {
+				var unpackArgs foot.UnpackCalls
+				unmarshalFile(filepath.Join(driverStoreDir, foot.UnpackArgsFileName), &unpackArgs)
+				Expect(len(unpackArgs)).NotTo(Equal(0))
+			})
+
+			It("calculates the disk limit based on uncompressed layer sizes", func() {
+				var bundleArgs foot.BundleCalls
+				unmarshalFile(filepath.Join(driverStoreDir, foot.BundleArgsFileName), &bundleArgs)
+
+				blobsPath := fmt.Sprintf("%s/oci-test-images/opq-whiteouts-busybox/blobs/sha256", workDir)
+
+				// yuck, this is white-box because we know this is how foot calculates size
+				firstBlobSize := getUncompressedBlobSize(filepath.Join(blobsPath, "56bec22e355981d8ba0878c6c2f23b21f422f30ab0aba188b54f1ffeff59c190"))
+				secondBlobSize := getUncompressedBlobSize(filepath.Join(blobsPath, "ed2d7b0f6d7786230b71fd60de08a553680a9a96ab216183bcc49c71f06033ab"))
+
+				Expect(bundleArgs[0].DiskLimit).To(BeEquivalentTo(99999999 - (firstBlobSize + secondBlobSize)))
+			})
+		})
+	})
+
+	Describe("Registry images", func() {
+		var registry *ghttp.Server
+
+		BeforeEach(func() {
+			registry = ghttp.NewServer()
+			registry.RouteToHandler("GET", regexp.MustCompile(".*"), ghttp.RespondWith(http.StatusServiceUnavailable, ""))
+			footCmd = newFootCommand(configFilePath, driverStoreDir, "create", fmt.Sprintf("docker://%s/some/image:latest", registry.Addr()), "some-handle")
+		})
+
+		AfterEach(func() {
+			registry.Close()
+		})
+
+		Context("when the registry keeps failing", func() {
+			BeforeEach(func() {
+				writeFile(configFilePath, fmt.Sprintf("insecure_registries: [%q]\nretry:\n  attempts: 2\n  initial_backoff: 1ms\n", registry.Addr()))
+			})
+
+			It("returns an error", func() {
+				expectErrorOutput("503 Service Unavailable")
+			})
+
+			It("retries as many times as configured", func() {
+				Expect(registry.ReceivedRequests()).To(HaveLen(2))
+			})
+		})
+
+		Context("when the configured status codes are not retryable", func() {
+			BeforeEach(func() {
+				writeFile(configFilePath, fmt.Sprintf("insecure_registries: [%q]\nretry:\n  retryable_status_codes: [429]\n", registry.Addr()))
+			})
+
+			It("does not retry", func() {
+				Expect(footCmdError).To(HaveOccurred())
+				Expect(registry.ReceivedRequests()).To(HaveLen(1))
+			})
+		})
+	})
+
+	Describe("Registry authentication", func() {
+		var (
+			registry      *ghttp.Server
+			helperDir     string
+			challenge     string
+			refreshTokens []string
+		)
+
+		basicAuth := func(username, password string) string {
+			return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+		}
+
+		authorizations := func() []string {
+			authorizations := []string{}
+			for _, req := range registry.ReceivedRequests() {
+				if authorization := req.Header.Get("Authorization"); authorization != "" {
+					authorizations = append(authorizations, authorization)
+				}
+			}
+			return authorizations
+		}
+
+		BeforeEach(func() {
+			challenge = `Basic realm="groot"`
+			refreshTokens = nil
+
+			registry = ghttp.NewServer()
+			registry.RouteToHandler("POST", "/token", func(w http.ResponseWriter, req *http.Request) {
+				Expect(req.ParseForm()).To(Succeed())
+				refreshTokens = append(refreshTokens, req.PostForm.Get("refresh_token"))
+				w.WriteHeader(http.StatusUnauthorized)
+			})
+			registry.RouteToHandler("GET", regexp.MustCompile(".*"), func(w http.ResponseWriter, req *http.Request) {
+				if req.Header.Get("Authorization") == "" {
+					w.Header().Set("WWW-Authenticate", challenge)
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				w.WriteHeader(http.StatusNotFound)
+			})
+
+			helperDir = tempDir("", "credential-helpers")
+			writeFile(filepath.Join(helperDir, "docker-credential-groot-test"), `#!/bin/sh
+read registry
+if [ "$1" != "get" ]; then exit 1; fi
+if [ -n "$HELPER_NOT_LOGGED_IN" ]; then echo "credentials not found in native keychain"; exit 1; fi
+echo '{"ServerURL":"'$registry'","Username":"helper-user","Secret":"helper-password"}'
+`)
+			Expect(os.Chmod(filepath.Join(helperDir, "docker-credential-groot-test"), 0700)).To(Succeed())
+
+			writeFile(configFilePath, fmt.Sprintf("insecure_registries: [%q]\n", registry.Addr()))
+		})
+
+		JustBeforeEach(func() {
+			Expect(footCmdError).To(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			registry.Close()
+			Expect(os.RemoveAll(helperDir)).To(Succeed())
+		})
+
+		newFootCommandWithCredentials := func(args ...string) *exec.Cmd {
+			args = append([]string{"create", fmt.Sprintf("docker://%s/some/image:latest", registry.Addr()), "some-handle"}, args...)
+			cmd := newFootCommand(configFilePath, driverStoreDir, args...)
+			cmd.Env = append(os.Environ(), fmt.Sprintf("PATH=%s%c%s", helperDir, os.PathListSeparator, os.Getenv("PATH")))
+			return cmd
+		}
+
+		Context("when credentials are only given on the command line", func() {
+			BeforeEach(func() {
+				footCmd = newFootCommandWithCredentials("--username", "flag-user", "--password", "flag-password")
+			})
+
+			It("uses them", func() {
+				Expect(authorizations()).To(ConsistOf(basicAuth("flag-user", "flag-password")))
+			})
+		})
+
+		Context("when the password is given in a file", func() {
+			BeforeEach(func() {
+				passwordFile := filepath.Join(driverStoreDir, "password")
+				writeFile(passwordFile, "file-password\n")
+				footCmd = newFootCommandWithCredentials("--username", "flag-user", "--password-file", passwordFile)
+			})
+
+			It("uses it", func() {
+				Expect(authorizations()).To(ConsistOf(basicAuth("flag-user", "file-password")))
+			})
+		})
+
+		Context("when the password file does not exist", func() {
+			BeforeEach(func() {
+				footCmd = newFootCommandWithCredentials("--username", "flag-user", "--password-file", "/does/not/exist")
+			})
+
+			It("returns an error", func() {
+				expectErrorOutput("reading password file")
+				Expect(registry.ReceivedRequests()).To(BeEmpty())
+			})
+		})
+
+		Context("when both a password and a password file are given", func() {
+			BeforeEach(func() {
+				footCmd = newFootCommandWithCredentials("--username", "flag-user", "--password", "flag-password", "--password-file", "/some/file")
+			})
+
+			It("returns an error", func() {
+				expectErrorOutput("only one of --password, --password-file and --credentials-stdin can be given")
+			})
+		})
+
+		Context("when credentials are given on stdin", func() {
+			BeforeEach(func() {
+				footCmd = newFootCommandWithCredentials("--credentials-stdin")
+				footCmd.Stdin = strings.NewReader(`{"username": "stdin-user", "password": "stdin-password"}`)
+			})
+
+			It("uses them", func() {
+				Expect(authorizations()).To(ConsistOf(basicAuth("stdin-user", "stdin-password")))
+			})
+
+			Context("when they are not valid JSON", func() {
+				BeforeEach(func() {
+					footCmd.Stdin = strings.NewReader("stdin-password")
+				})
+
+				It("returns an error", func() {
+					expectErrorOutput("reading credentials from stdin")
+				})
+			})
+
+			Context("when they hold an identity token", func() {
+				BeforeEach(func() {
+					challenge = fmt.Sprintf(`Bearer realm="%s/token",service="groot"`, registry.URL())
+					footCmd.Stdin = strings.NewReader(`{"identity_token": "some-identity-token"}`)
+				})
+
+				It("exchanges it for an access token", func() {
+					Expect(refreshTokens).To(ContainElement("some-identity-token"))
+				})
+			})
+
+			Context("when they hold a registry token", func() {
+				BeforeEach(func() {
+					challenge = fmt.Sprintf(`Bearer realm="%s/token",service="groot"`, registry.URL())
+					footCmd.Stdin = strings.NewReader(`{"registry_token": "some-registry-token"}`)
+				})
+
+				It("sends it to the registry", func() {
+					Expect(authorizations()).To(ContainElement("Bearer some-registry-token"))
+				})
+			})
+		})
+
+		Context("when an auth file is configured", func() {
+			var authFilePath string
+
+			BeforeEach(func() {
+				authFilePath = filepath.Join(driverStoreDir, "auth.json")
+				writeFile(configFilePath, fmt.Sprintf("insecure_registries: [%q]\nauth_file: %s\n", registry.Addr(), authFilePath))
+				footCmd = newFootCommandWithCredentials("--username", "flag-user", "--password", "flag-password")
+			})
+
+			Context("when the auth file has credentials for the registry", func() {
+				BeforeEach(func() {
+					writeFile(authFilePath, fmt.Sprintf(`{"auths":{%q:{"auth":%q}}}`, registry.Addr(), base64.StdEncoding.EncodeToString([]byte("file-user:file-password"))))
+				})
+
+				It("uses the credentials from the auth file", func() {
+					Expect(authorizations()).To(ConsistOf(basicAuth("file-user", "file-password")))
+				})
+			})
+
+			Context("when the auth file names a credential helper for the registry", func() {
+				BeforeEach(func() {
+					writeFile(authFilePath, fmt.Sprintf(`{"credHelpers":{%q:"groot-test"}}`, registry.Addr()))
+				})
+
+				It("uses the credentials from the credential helper", func() {
+					Expect(authorizations()).To(ConsistOf(basicAuth("helper-user", "helper-password")))
+				})
+			})
+
+			Context("when the auth file has no credentials for the registry", func() {
+				BeforeEach(func() {
+					writeFile(authFilePath, `{"auths":{"some.other.registry":{"auth":"Zm9vOmJhcg=="}}}`)
+				})
+
+				It("falls back to the credentials given on the command line", func() {
+					Expect(authorizations()).To(ConsistOf(basicAuth("flag-user", "flag-password")))
+				})
+			})
+		})
+
+		Context("when a credential helper is configured for the registry", func() {
+			BeforeEach(func() {
+				writeFile(configFilePath, fmt.Sprintf("insecure_registries: [%q]\ncredential_helpers:\n  %q: groot-test\n", registry.Addr(), registry.Addr()))
+				footCmd = newFootCommandWithCredentials("--username", "flag-user", "--password", "flag-password")
+			})
+
+			It("uses the credentials from the credential helper", func() {
+				Expect(authorizations()).To(ConsistOf(basicAuth("helper-user", "helper-password")))
+			})
+
+			Context("when the credential helper has no credentials for the registry", func() {
+				BeforeEach(func() {
+					footCmd.Env = append(footCmd.Env, "HELPER_NOT_LOGGED_IN=true")
+				})
+
+				It("falls back to the credentials given on the command line", func() {
+					Expect(authorizations()).To(ConsistOf(basicAuth("flag-user", "flag-password")))
+				})
+			})
+
+			Context("when the credential helper fails", func() {
+				BeforeEach(func() {
+					Expect(os.Remove(filepath.Join(helperDir, "docker-credential-groot-test"))).To(Succeed())
+				})
+
+				It("returns an error", func() {
+					expectErrorOutput("getting credentials for `127.0.0.1:[0-9]+` from credential helper `groot-test`")
+				})
+			})
+		})
+	})
+
+	Describe("Registry TLS", func() {
+		var (
+			registry *ghttp.Server
+			certDir  string
+			tmpDir   string
+		)
+
+		BeforeEach(func() {
+			registry = ghttp.NewUnstartedServer()
+			registry.HTTPTestServer.StartTLS()
+			registry.RouteToHandler("GET", regexp.MustCompile(".*"), ghttp.RespondWith(http.StatusNotFound, ""))
+
+			certDir = tempDir("", "registry-certs")
+			writeFile(filepath.Join(certDir, "ca.pem"), string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: registry.HTTPTestServer.Certificate().Raw})))
+
+			tmpDir = tempDir("", "groot-tmp")
+			footCmd = newFootCommand(configFilePath, driverStoreDir, "create", fmt.Sprintf("docker://%s/some/image:latest", registry.Addr()), "some-handle")
+			footCmd.Env = append(os.Environ(), "TMPDIR="+tmpDir)
+		})
+
+		AfterEach(func() {
+			registry.Close()
+			Expect(os.RemoveAll(certDir)).To(Succeed())
+			Expect(os.RemoveAll(tmpDir)).To(Succeed())
+		})
+
+		Context("when the registry is not configured", func() {
+			It("does not trust the registry certificate", func() {
+				expectErrorOutput("certificate signed by unknown authority")
+				Expect(registry.ReceivedRequests()).To(BeEmpty())
+			})
+		})
+
+		Context("when a CA bundle is configured for the registry", func() {
+			BeforeEach(func() {
+				writeFile(configFilePath, fmt.Sprintf("registries:\n  %q:\n    ca_file: %s\n", registry.Addr(), filepath.Join(certDir, "ca.pem")))
+			})
+
+			It("trusts the registry certificate", func() {
+				Expect(registry.ReceivedRequests()).NotTo(BeEmpty())
+			})
+
+			It("cleans up after itself", func() {
+				Expect(os.ReadDir(tmpDir)).To(BeEmpty())
+			})
+
+			Context("when the CA bundle does not exist", func() {
+				BeforeEach(func() {
+					Expect(os.Remove(filepath.Join(certDir, "ca.pem"))).To(Succeed())
+				})
+
+				It("returns an error", func() {
+					expectErrorOutput("configuring TLS for registry `127.0.0.1:[0-9]+`")
+				})
+			})
+		})
+
+		Context("when the registry requires a client certificate", func() {
+			BeforeEach(func() {
+				clientCert := writeClientCertificate(filepath.Join(certDir, "client.pem"), filepath.Join(certDir, "client-key.pem"))
+				clientCAs := x509.NewCertPool()
+				clientCAs.AddCert(clientCert)
+				registry.HTTPTestServer.TLS.ClientAuth = tls.RequireAndVerifyClientCert
+				registry.HTTPTestServer.TLS.ClientCAs = clientCAs
+			})
+
+			Context("when a client certificate is configured", func() {
+				BeforeEach(func() {
+					writeFile(configFilePath, fmt.Sprintf("registries:\n  %q:\n    ca_file: %s\n    cert_file: %s\n    key_file: %s\n",
+						registry.Addr(), filepath.Join(certDir, "ca.pem"), filepath.Join(certDir, "client.pem"), filepath.Join(certDir, "client-key.pem")))
+				})
+
+				It("presents it to the registry", func() {
+					Expect(registry.ReceivedRequests()).NotTo(BeEmpty())
+				})
+			})
+
+			Context("when no client certificate is configured", func() {
+				BeforeEach(func() {
+					writeFile(configFilePath, fmt.Sprintf("registries:\n  %q:\n    ca_file: %s\n", registry.Addr(), filepath.Join(certDir, "ca.pem")))
+				})
+
+				It("cannot connect to the registry", func() {
+					Expect(footCmdError).To(HaveOccurred())
+					Expect(registry.ReceivedRequests()).To(BeEmpty())
+				})
+			})
+
+			Context("when the client key is missing", func() {
+				BeforeEach(func() {
+					writeFile(configFilePath, fmt.Sprintf("registries:\n  %q:\n    ca_file: %s\n    cert_file: %s\n",
+						registry.Addr(), filepath.Join(certDir, "ca.pem"), filepath.Join(certDir, "client.pem")))
+				})
+
+				It("returns an error", func() {
+					expectErrorOutput("registry `127.0.0.1:[0-9]+` needs both a cert_file and a key_file")
+				})
+			})
+		})
+
+		Context("when a minimum TLS version is configured", func() {
+			BeforeEach(func() {
+				writeFile(configFilePath, fmt.Sprintf("registries:\n  %q:\n    ca_file: %s\n    min_tls_version: \"1.3\"\n", registry.Addr(), filepath.Join(certDir, "ca.pem")))
+			})
+
+			It("connects to registries that support it", func() {
+				Expect(registry.ReceivedRequests()).NotTo(BeEmpty())
+			})
+
+			Context("when the registry does not support it", func() {
+				BeforeEach(func() {
+					registry.HTTPTestServer.TLS.MaxVersion = tls.VersionTLS12
+				})
+
+				It("returns an error", func() {
+					expectErrorOutput("connecting to registry `127.0.0.1:[0-9]+` with TLS 1.3 or later")
+					Expect(registry.ReceivedRequests()).To(BeEmpty())
+				})
+			})
+		})
+
+		Context("when the registry does not complete the TLS handshake", func() {
+			var (
+				listener net.Listener
+				started  time.Time
+			)
+
+			BeforeEach(func() {
+				started = time.Now()
+
+				var err error
+				listener, err = net.Listen("tcp", "127.0.0.1:0")
+				Expect(err).NotTo(HaveOccurred())
+
+				writeFile(configFilePath, fmt.Sprintf("pull_timeout: 1s\nregistries:\n  %q:\n    min_tls_version: \"1.3\"\n", listener.Addr()))
+				footCmd = newFootCommand(configFilePath, driverStoreDir, "create", fmt.Sprintf("docker://%s/cfgarden/empty:v0.1.0", listener.Addr()), "some-handle")
+			})
+
+			AfterEach(func() {
+				Expect(listener.Close()).To(Succeed())
+			})
+
+			It("gives up on the minimum TLS version check once the pull times out", func() {
+				Expect(footCmdError).To(HaveOccurred())
+				Expect(footCmdOutput).To(gbytes.Say("with TLS 1.3 or later"))
+				Expect(time.Since(started)).To(BeNumerically("<", 10*time.Second))
+			})
+		})
+
+		Context("when the registry of Docker Hub images is configured", func() {
+			BeforeEach(func() {
+				writeFile(configFilePath, fmt.Sprintf("registries:\n  docker.io:\n    cert_file: %s\n", filepath.Join(certDir, "client.pem")))
+				footCmd = newFootCommand(configFilePath, driverStoreDir, "create", "docker:///cfgarden/empty:v0.1.0", "some-handle")
+			})
+
+			It("uses the configuration", func() {
+				expectErrorOutput("registry `docker.io` needs both a cert_file and a key_file")
+			})
+		})
+
+		Context("when the minimum TLS version is invalid", func() {
+			BeforeEach(func() {
+				writeFile(configFilePath, fmt.Sprintf("registries:\n  %q:\n    ca_file: %s\n    min_tls_version: \"2\"\n", registry.Addr(), filepath.Join(certDir, "ca.pem")))
+			})
+
+			It("returns an error", func() {
+				expectErrorOutput(`invalid min_tls_version "2"`)
+			})
+		})
+	})
+
+	Describe("Registry mirrors", func() {
+		var (
+			registry *ghttp.Server
+			mirror   *ghttp.Server
+		)
+
+		requestPaths := func(server *ghttp.Server) []string {
+			paths := []string{}
+			for _, req := range server.ReceivedRequests() {
+				paths = append(paths, req.URL.Path)
+			}
+			return paths
+		}
+
+		BeforeEach(func() {
+			workDir, err := os.Getwd()
+			Expect(err).NotTo(HaveOccurred())
+			registry = testhelpers.NewOCIRegistry(filepath.Join(workDir, "oci-test-images", "opq-whiteouts-busybox"))
+			mirror = testhelpers.NewOCIRegistry(filepath.Join(workDir, "oci-test-images", "opq-whiteouts-busybox"))
+
+			writeFile(configFilePath, fmt.Sprintf("insecure_registries: [%q, %q]\nmirrors:\n  %q: [%q]\n", registry.Addr(), mirror.Addr(), registry.Addr(), mirror.Addr()+"/cache"))
+			footCmd = newFootCommand(configFilePath, driverStoreDir, "create", fmt.Sprintf("docker://%s/some/image:latest", registry.Addr()), "some-handle")
+		})
+
+		AfterEach(func() {
+			registry.Close()
+			mirror.Close()
+		})
+
+		It("pulls the image from the mirror", func() {
+			Expect(footCmdError).NotTo(HaveOccurred())
+			Expect(requestPaths(mirror)).To(ContainElement("/v2/cache/some/image/manifests/latest"))
+			Expect(registry.ReceivedRequests()).To(BeEmpty())
+		})
+
+		It("logs that the mirror was used", func() {
+			Expect(footCmdOutput).To(gbytes.Say(fmt.Sprintf("using-endpoint.*docker://%s/cache/some/image:latest", mirror.Addr())))
+			Expect(footCmdOutput).To(gbytes.Say(fmt.Sprintf("got-blob.*docker://%s/cache/some/image:latest", mirror.Addr())))
+		})
+
+		Context("when the mirror does not have the image", func() {
+			BeforeEach(func() {
+				mirror.Close()
+				mirror = ghttp.NewServer()
+				mirror.RouteToHandler("GET", regexp.MustCompile(".*"), ghttp.RespondWith(http.StatusNotFound, ""))
+				writeFile(configFilePath, fmt.Sprintf("insecure_registries: [%q, %q]\nmirrors:\n  %q: [%q]\n", registry.Addr(), mirror.Addr(), registry.Addr(), mirror.Addr()+"/cache"))
+			})
+
+			It("falls back to the registry", func() {
+				Expect(footCmdError).NotTo(HaveOccurred())
+				Expect(mirror.ReceivedRequests()).NotTo(BeEmpty())
+				Expect(requestPaths(registry)).To(ContainElement("/v2/some/image/manifests/latest"))
+			})
+
+			It("logs that the registry was used", func() {
+				Expect(footCmdOutput).To(gbytes.Say(fmt.Sprintf("using-endpoint.*docker://%s/some/image:latest", registry.Addr())))
+			})
+		})
+
+		Context("when credentials are given on the command line", func() {
+			BeforeEach(func() {
+				footCmd = newFootCommand(configFilePath, driverStoreDir, "create", fmt.Sprintf("docker://%s/some/image:latest", registry.Addr()), "some-handle", "--username", "user", "--password", "secret")
+			})
+
+			It("does not send them to the mirror", func() {
+				Expect(footCmdError).NotTo(HaveOccurred())
+				for _, req := range mirror.ReceivedRequests() {
+					Expect(req.Header.Get("Authorization")).To(BeEmpty())
+				}
+			})
+		})
+	})
+
+	Describe("Blob cache", func() {
+		var (
+			registry *ghttp.Server
+			cacheDir string
+		)
+
+		layerBlobRequests := func() int {
+			count := 0
+			for _, req := range registry.ReceivedRequests() {
+				if regexp.MustCompile("/blobs/sha256:(56bec22e|ed2d7b0f)").MatchString(req.URL.Path) {
+					count++
+				}
+			}
+			return count
+		}
+
+		BeforeEach(func() {
+			workDir, err := os.Getwd()
+			Expect(err).NotTo(HaveOccurred())
+			registry = testhelpers.NewOCIRegistry(filepath.Join(workDir, "oci-test-images", "opq-whiteouts-busybox"))
+			cacheDir = tempDir("", "blob-cache")
+
+			writeFile(configFilePath, fmt.Sprintf("insecure_registries: [%q]\nblob_cache:\n  path: %s\n", registry.Addr(), cacheDir))
+			footCmd = newFootCommand(configFilePath, driverStoreDir, "create", fmt.Sprintf("docker://%s/some/image:latest", registry.Addr()), "some-handle")
+		})
+
+		AfterEach(func() {
+			registry.Close()
+			Expect(os.RemoveAll(cacheDir)).To(Succeed())
+		})
+
+		It("caches the layer blobs", func() {
+			Expect(footCmdError).NotTo(HaveOccurred())
+			Expect(filepath.Join(cacheDir, "blobs", "sha256", "56bec22e355981d8ba0878c6c2f23b21f422f30ab0aba188b54f1ffeff59c190")).To(BeARegularFile())
+			Expect(filepath.Join(cacheDir, "blobs", "sha256", "ed2d7b0f6d7786230b71fd60de08a553680a9a96ab216183bcc49c71f06033ab")).To(BeARegularFile())
+		})
+
+		It("does not download cached layers again", func() {
+			Expect(footCmdError).NotTo(HaveOccurred())
+			Expect(layerBlobRequests()).To(Equal(2))
+
+			out, err := newFootCommand(configFilePath, driverStoreDir, "create", fmt.Sprintf("docker://%s/some/image:latest", registry.Addr()), "another-handle").CombinedOutput()
+			Expect(err).NotTo(HaveOccurred(), string(out))
+			Expect(layerBlobRequests()).To(Equal(2))
+			Expect(string(out)).To(ContainSubstring(`"endpoint":"blob-cache"`))
+		})
+	})
+
+	Describe("Image policy", func() {
+		var registry *ghttp.Server
+
+		writePolicy := func(policy string) {
+			writeFile(configFilePath, fmt.Sprintf("insecure_registries: [%q]\nimage_policy:\n%s", registry.Addr(), policy))
+		}
+
+		BeforeEach(func() {
+			workDir, err := os.Getwd()
+			Expect(err).NotTo(HaveOccurred())
+			registry = testhelpers.NewOCIRegistry(filepath.Join(workDir, "oci-test-images", "opq-whiteouts-busybox"))
+			footCmd = newFootCommand(configFilePath, driverStoreDir, "create", fmt.Sprintf("docker://%s/cf/busybox:latest", registry.Addr()), "some-handle")
+		})
+
+		AfterEach(func() {
+			registry.Close()
+		})
+
+		Context("when the image matches an allow rule", func() {
+			BeforeEach(func() {
+				writePolicy(fmt.Sprintf("  allow:\n  - registry: %q\n    repository: cf/*\n", registry.Addr()))
+			})
+
+			It("creates the image", func() {
+				Expect(footCmdError).NotTo(HaveOccurred(), string(footCmdOutput.Contents()))
+			})
+		})
+
+		Context("when the image matches no allow rule", func() {
+			BeforeEach(func() {
+				writePolicy("  allow:\n  - repository: library/*\n")
+			})
+
+			It("fails without contacting the registry", func() {
+				Expect(footCmdError).To(HaveOccurred())
+				Expect(footCmdOutput).To(gbytes.Say(`image .*/cf/busybox:latest. is not allowed by the image policy`))
+				Expect(registry.ReceivedRequests()).To(BeEmpty())
+			})
+		})
+
+		Context("when the image matches a deny rule", func() {
+			BeforeEach(func() {
+				writePolicy("  allow:\n  - repository: cf/*\n  deny:\n  - tag: latest\n")
+			})
+
+			It("fails without contacting the registry", func() {
+				Expect(footCmdError).To(HaveOccurred())
+				Expect(footCmdOutput).To(gbytes.Say(`image .*/cf/busybox:latest. is denied by the image policy`))
+				Expect(registry.ReceivedRequests()).To(BeEmpty())
+			})
+		})
 
-				var bundleArgs foot.BundleCalls
-				unmarshalFile(filepath.Join(driverStoreDir, foot.BundleArgsFileName), &bundleArgs)
-				unpackLayerIds := []string{}
-				for _, call := range unpackArgs {
-					unpackLayerIds = append(unpackLayerIds, call.ID)
-				}
-				Expect(bundleArgs[0].ID).To(Equal("some-handle"))
-				Expect(bundleArgs[0].LayerIDs).To(ConsistOf(unpackLayerIds))
-				Expect(bundleArgs[0].DiskLimit).To(Equal(500 - imageSize))
+		Context("when the registry requires digests", func() {
+			BeforeEach(func() {
+				writePolicy("  require_digest: [\"127.0.0.1:*\"]\n")
 			})
 
-			Context("--exclude-image-from-quota is given as well", func() {
+			It("fails for images referred to by tag", func() {
+				Expect(footCmdError).To(HaveOccurred())
+				Expect(footCmdOutput).To(gbytes.Say("have to be referred to by digest"))
+				Expect(registry.ReceivedRequests()).To(BeEmpty())
+			})
+
+			Context("when the image is referred to by digest", func() {
 				BeforeEach(func() {
-					footCmd = newFootCommand(configFilePath, driverStoreDir, "create", rootfsURI, "some-handle", "--disk-limit-size-bytes", "500", "--exclude-image-from-quota")
+					imageURL := fmt.Sprintf("docker://%s/cf/busybox@sha256:9c90ae0cffa9d1426e83a516183f0267e03edbb765efc5fb0c0dccc8edca4f15", registry.Addr())
+					footCmd = newFootCommand(configFilePath, driverStoreDir, "create", imageURL, "some-handle")
 				})
 
-				It("calls driver.Bundle() with expected args", func() {
-					var unpackArgs foot.UnpackCalls
-					unmarshalFile(filepath.Join(driverStoreDir, foot.UnpackArgsFileName), &unpackArgs)
+				It("creates the image", func() {
+					Expect(footCmdError).NotTo(HaveOccurred(), string(footCmdOutput.Contents()))
+				})
+			})
+		})
 
-					var bundleArgs foot.BundleCalls
-					unmarshalFile(filepath.Join(driverStoreDir, foot.BundleArgsFileName), &bundleArgs)
-					unpackLayerIds := []string{}
-					for _, call := range unpackArgs {
-						unpackLayerIds = append(unpackLayerIds, call.ID)
+		Context("when a pattern is invalid", func() {
+			BeforeEach(func() {
+				writePolicy("  deny:\n  - repository: \"[\"\n")
+			})
+
+			It("fails", func() {
+				Expect(footCmdError).To(HaveOccurred())
+				Expect(footCmdOutput).To(gbytes.Say("invalid image policy pattern"))
+			})
+		})
+	})
+
+	Describe("Signature policy", func() {
+		BeforeEach(func() {
+			workDir, err := os.Getwd()
+			Expect(err).NotTo(HaveOccurred())
+			rootfsURI = fmt.Sprintf("oci:///%s/oci-test-images/opq-whiteouts-busybox:latest", workDir)
+
+			policyPath := filepath.Join(driverStoreDir, "policy.json")
+			writeFile(policyPath, `{"default": [{"type": "reject"}]}`)
+			writeFile(configFilePath, fmt.Sprintf("signature_policy_file: %s\n", policyPath))
+			footCmd = newFootCommand(configFilePath, driverStoreDir, "create", rootfsURI, "some-handle")
+		})
+
+		It("does not pull images the policy rejects", func() {
+			Expect(footCmdError).To(HaveOccurred())
+			if signatureVerificationSupported {
+				Expect(footCmdOutput).To(gbytes.Say("rejected by signature policy"))
+			} else {
+				Expect(footCmdOutput).To(gbytes.Say("signature verification is not supported by this build"))
+			}
+
+			_, err := os.Stat(filepath.Join(driverStoreDir, foot.UnpackArgsFileName))
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+
+		It("reports when this build cannot verify signatures", func() {
+			if signatureVerificationSupported {
+				Skip("signatures can be verified with the containers_image_openpgp build tag")
+			}
+			Expect(footCmdOutput).To(gbytes.Say("signature-policy-not-supported"))
+		})
+
+		Context("when the image is not from a registry or an OCI layout", func() {
+			BeforeEach(func() {
+				rootfsURI = filepath.Join(driverStoreDir, "rootfs.tar")
+				footCmd = newFootCommand(configFilePath, driverStoreDir, "create", rootfsURI, "some-handle")
+			})
+
+			It("does not pull it", func() {
+				Expect(footCmdError).To(HaveOccurred())
+				Expect(footCmdOutput).To(gbytes.Say("cannot be verified by the signature policy"))
+
+				_, err := os.Stat(filepath.Join(driverStoreDir, foot.UnpackArgsFileName))
+				Expect(os.IsNotExist(err)).To(BeTrue())
+			})
+		})
+
+		Context("when the policy accepts anything", func() {
+			BeforeEach(func() {
+				if !signatureVerificationSupported {
+					Skip("signature policies are only enforced with the containers_image_openpgp build tag")
+				}
+				writeFile(filepath.Join(driverStoreDir, "policy.json"), `{"default": [{"type": "insecureAcceptAnything"}]}`)
+			})
+
+			It("creates the image", func() {
+				Expect(footCmdError).NotTo(HaveOccurred(), string(footCmdOutput.Contents()))
+			})
+		})
+	})
+
+	Describe("Progress", func() {
+		var progressPath string
+
+		progressEvents := func() []imagepuller.ProgressEvent {
+			events := []imagepuller.ProgressEvent{}
+			for _, line := range strings.Split(strings.TrimSpace(string(readFile(progressPath))), "\n") {
+				var event imagepuller.ProgressEvent
+				Expect(json.Unmarshal([]byte(line), &event)).To(Succeed())
+				events = append(events, event)
+			}
+			return events
+		}
+
+		BeforeEach(func() {
+			workDir, err := os.Getwd()
+			Expect(err).NotTo(HaveOccurred())
+			rootfsURI = fmt.Sprintf("oci:///%s/oci-test-images/opq-whiteouts-busybox:latest", workDir)
+			progressPath = filepath.Join(driverStoreDir, "progress.jsonl")
+
+			writeFile(configFilePath, fmt.Sprintf("progress:\n  file: %s\n", progressPath))
+			footCmd = newFootCommand(configFilePath, driverStoreDir, "create", rootfsURI, "some-handle")
+		})
+
+		It("writes the progress of every layer to the file", func() {
+			Expect(footCmdError).NotTo(HaveOccurred())
+
+			events := progressEvents()
+			Expect(events[0].Event).To(Equal(imagepuller.ProgressManifestFetched))
+			Expect(events[1].Event).To(Equal(imagepuller.ProgressLayerResolved))
+			Expect(events[1].Layers).To(Equal(2))
+			Expect(events[len(events)-1].Event).To(Equal(imagepuller.ProgressPullFinished))
+
+			eventNames := []string{}
+			for _, event := range events {
+				eventNames = append(eventNames, event.Event)
+			}
+			for _, eventName := range []string{
+				imagepuller.ProgressDownloadStarted,
+				imagepuller.ProgressBytesTransferred,
+				imagepuller.ProgressLayerVerified,
+				imagepuller.ProgressUnpackStarted,
+				imagepuller.ProgressUnpackFinished,
+			} {
+				Expect(eventNames).To(ContainElement(eventName))
+			}
+		})
+
+		Context("when the layers already exist", func() {
+			BeforeEach(func() {
+				footCmd.Env = append(os.Environ(), "FOOT_LAYER_EXISTS=true")
+			})
+
+			It("reports them as skipped", func() {
+				Expect(footCmdError).NotTo(HaveOccurred())
+
+				events := progressEvents()
+				Expect(events).To(HaveLen(6))
+				Expect(events[3].Event).To(Equal(imagepuller.ProgressLayerSkipped))
+				Expect(events[4].Event).To(Equal(imagepuller.ProgressLayerSkipped))
+			})
+		})
+
+		Context("when a file descriptor is configured", func() {
+			BeforeEach(func() {
+				progressFile, err := os.Create(progressPath)
+				Expect(err).NotTo(HaveOccurred())
+				DeferCleanup(progressFile.Close)
+
+				writeFile(configFilePath, "progress:\n  fd: 3\n")
+				footCmd.ExtraFiles = []*os.File{progressFile}
+			})
+
+			It("writes the progress to it", func() {
+				Expect(footCmdError).NotTo(HaveOccurred())
+				Expect(progressEvents()[0].Event).To(Equal(imagepuller.ProgressManifestFetched))
+			})
+		})
+
+		Context("when both a file and a file descriptor are configured", func() {
+			BeforeEach(func() {
+				writeFile(configFilePath, fmt.Sprintf("progress:\n  file: %s\n  fd: 3\n", progressPath))
+			})
+
+			It("fails", func() {
+				expectErrorOutput("only one of progress.file and progress.fd can be set")
+			})
+		})
+	})
+
+	Describe("Metrics", func() {
+		var metricsDir string
+
+		metricFamilies := func() map[string]*dto.MetricFamily {
+			metricsFile, err := os.Open(filepath.Join(metricsDir, "groot_pulls.prom"))
+			Expect(err).NotTo(HaveOccurred())
+			defer metricsFile.Close()
+
+			var parser expfmt.TextParser
+			families, err := parser.TextToMetricFamilies(metricsFile)
+			Expect(err).NotTo(HaveOccurred())
+			return families
+		}
+
+		BeforeEach(func() {
+			workDir, err := os.Getwd()
+			Expect(err).NotTo(HaveOccurred())
+			rootfsURI = fmt.Sprintf("oci:///%s/oci-test-images/opq-whiteouts-busybox:latest", workDir)
+			metricsDir = tempDir(driverStoreDir, "metrics")
+
+			writeFile(configFilePath, fmt.Sprintf("metrics:\n  textfile_dir: %s\n", metricsDir))
+			footCmd = newFootCommand(configFilePath, driverStoreDir, "create", rootfsURI, "some-handle")
+		})
+
+		It("writes the metrics of the pull to the textfile directory", func() {
+			Expect(footCmdError).NotTo(HaveOccurred())
+
+			families := metricFamilies()
+			Expect(families["groot_pull_success"].Metric[0].GetGauge().GetValue()).To(Equal(1.0))
+			Expect(families["groot_manifest_fetch_duration_seconds"].Metric[0].GetGauge().GetValue()).To(BeNumerically(">", 0))
+			Expect(families["groot_layer_download_duration_seconds"].Metric[0].GetHistogram().GetSampleCount()).To(Equal(uint64(2)))
+			Expect(families["groot_layer_unpack_duration_seconds"].Metric[0].GetHistogram().GetSampleCount()).To(Equal(uint64(2)))
+			Expect(families["groot_downloaded_bytes_total"].Metric[0].GetCounter().GetValue()).To(Equal(668151.0 + 124.0))
+			Expect(families["groot_cached_bytes_total"].Metric[0].GetCounter().GetValue()).To(BeZero())
+			Expect(families["groot_quota_rejections_total"].Metric[0].GetCounter().GetValue()).To(BeZero())
+		})
+
+		It("labels the metrics with the registry only", func() {
+			Expect(footCmdError).NotTo(HaveOccurred())
+
+			labels := map[string]string{}
+			for _, label := range metricFamilies()["groot_pull_success"].Metric[0].Label {
+				labels[label.GetName()] = label.GetValue()
+			}
+			Expect(labels).To(Equal(map[string]string{"registry": "oci"}))
+		})
+
+		Context("when registries have not been pulled from for longer than max_series_age", func() {
+			BeforeEach(func() {
+				writeFile(configFilePath, fmt.Sprintf("metrics:\n  textfile_dir: %s\n  max_series_age: 1h\n", metricsDir))
+				writeFile(filepath.Join(metricsDir, "groot_pulls.prom"), fmt.Sprintf(`# TYPE groot_pull_success gauge
+groot_pull_success{registry="stale.example.com"} 1
+groot_pull_success{registry="recent.example.com"} 1
+# TYPE groot_pull_timestamp_seconds gauge
+groot_pull_timestamp_seconds{registry="stale.example.com"} %d
+groot_pull_timestamp_seconds{registry="recent.example.com"} %d
+`, time.Now().Add(-2*time.Hour).Unix(), time.Now().Add(-time.Minute).Unix()))
+			})
+
+			It("removes their metrics", func() {
+				Expect(footCmdError).NotTo(HaveOccurred())
+
+				registries := []string{}
+				for _, metric := range metricFamilies()["groot_pull_timestamp_seconds"].Metric {
+					for _, label := range metric.Label {
+						registries = append(registries, label.GetValue())
 					}
-					Expect(bundleArgs[0].ID).To(Equal("some-handle"))
-					Expect(bundleArgs[0].LayerIDs).To(ConsistOf(unpackLayerIds))
-					Expect(bundleArgs[0].DiskLimit).To(Equal(int64(500)))
-				})
+				}
+				Expect(registries).To(ConsistOf("oci", "recent.example.com"))
+			})
+		})
+
+		Context("when the image is pulled again", func() {
+			JustBeforeEach(func() {
+				Expect(footCmdError).NotTo(HaveOccurred())
+
+				out, err := newFootCommand(configFilePath, driverStoreDir, "create", rootfsURI, "another-handle").CombinedOutput()
+				Expect(err).NotTo(HaveOccurred(), string(out))
+				out, err = newFootCommand(configFilePath, driverStoreDir, "pull", rootfsURI).CombinedOutput()
+				Expect(err).NotTo(HaveOccurred(), string(out))
+			})
+
+			It("adds the metrics of every pull to the same file", func() {
+				files, err := filepath.Glob(filepath.Join(metricsDir, "*.prom"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(files).To(ConsistOf(filepath.Join(metricsDir, "groot_pulls.prom")))
+
+				families := metricFamilies()
+				Expect(families["groot_pull_success"].Metric).To(HaveLen(1))
+				Expect(families["groot_pull_success"].Metric[0].GetGauge().GetValue()).To(Equal(1.0))
+				Expect(families["groot_layer_download_duration_seconds"].Metric[0].GetHistogram().GetSampleCount()).To(Equal(uint64(6)))
+				Expect(families["groot_downloaded_bytes_total"].Metric[0].GetCounter().GetValue()).To(Equal(3 * (668151.0 + 124.0)))
+			})
+		})
+
+		Context("when the layers already exist", func() {
+			BeforeEach(func() {
+				footCmd.Env = append(os.Environ(), "FOOT_LAYER_EXISTS=true")
+			})
+
+			It("counts the skipped layers", func() {
+				Expect(footCmdError).NotTo(HaveOccurred())
+
+				families := metricFamilies()
+				Expect(families["groot_skipped_layers_total"].Metric[0].GetCounter().GetValue()).To(Equal(2.0))
+				Expect(families["groot_downloaded_bytes_total"].Metric[0].GetCounter().GetValue()).To(BeZero())
+			})
+		})
+
+		Context("when the image does not fit in the disk limit", func() {
+			BeforeEach(func() {
+				footCmd = newFootCommand(configFilePath, driverStoreDir, "create", rootfsURI, "some-handle", "--disk-limit-size-bytes", "1")
+			})
+
+			It("records the quota rejection", func() {
+				Expect(footCmdError).To(HaveOccurred())
+
+				families := metricFamilies()
+				Expect(families["groot_pull_success"].Metric[0].GetGauge().GetValue()).To(BeZero())
+				Expect(families["groot_quota_rejections_total"].Metric[0].GetCounter().GetValue()).To(Equal(1.0))
 			})
 		})
 	})
 
-	Describe("Remote images", func() {
-		var workDir string
+	Describe("Tracing", func() {
+		const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+		var (
+			collector     *ghttp.Server
+			spansMutex    sync.Mutex
+			exportedSpans []*tracepb.Span
+		)
+
+		spanNames := func() []string {
+			spansMutex.Lock()
+			defer spansMutex.Unlock()
+
+			names := []string{}
+			for _, span := range exportedSpans {
+				names = append(names, span.Name)
+			}
+			return names
+		}
 
 		BeforeEach(func() {
-			var err error
-			workDir, err = os.Getwd()
+			exportedSpans = nil
+			collector = ghttp.NewServer()
+			collector.RouteToHandler("POST", "/v1/traces", func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				Expect(err).NotTo(HaveOccurred())
+
+				var request collectortracepb.ExportTraceServiceRequest
+				Expect(proto.Unmarshal(body, &request)).To(Succeed())
+
+				spansMutex.Lock()
+				defer spansMutex.Unlock()
+				for _, resourceSpans := range request.ResourceSpans {
+					for _, scopeSpans := range resourceSpans.ScopeSpans {
+						exportedSpans = append(exportedSpans, scopeSpans.Spans...)
+					}
+				}
+			})
+
+			workDir, err := os.Getwd()
 			Expect(err).NotTo(HaveOccurred())
 			rootfsURI = fmt.Sprintf("oci:///%s/oci-test-images/opq-whiteouts-busybox:latest", workDir)
+
+			writeFile(configFilePath, fmt.Sprintf("tracing:\n  endpoint: %s\n", collector.URL()))
+			footCmd = newFootCommand(configFilePath, driverStoreDir, "create", rootfsURI, "some-handle")
+			footCmd.Env = append(os.Environ(), "TRACEPARENT="+traceparent)
 		})
 
-		Context("when the image has multiple layers", func() {
-			It("correctly passes parent IDs to each driver.Unpack() call", func() {
-				var args foot.UnpackCalls
-				unmarshalFile(filepath.Join(driverStoreDir, foot.UnpackArgsFileName), &args)
+		AfterEach(func() {
+			collector.Close()
+		})
 
-				chainIDs := []string{}
-				for _, a := range args {
-					Expect(a.ParentIDs).To(Equal(chainIDs))
-					chainIDs = append(chainIDs, a.ID)
+		It("exports the spans of the create before exiting", func() {
+			Expect(footCmdError).NotTo(HaveOccurred())
+
+			Expect(spanNames()).To(ContainElements(
+				"groot.Create", "source.Manifest", "imagepuller.buildLayer",
+				"driver.Unpack", "driver.Bundle", "driver.WriteMetadata",
+			))
+		})
+
+		It("continues the trace given in TRACEPARENT", func() {
+			Expect(footCmdError).NotTo(HaveOccurred())
+
+			spansMutex.Lock()
+			defer spansMutex.Unlock()
+			Expect(exportedSpans).NotTo(BeEmpty())
+			for _, span := range exportedSpans {
+				Expect(hex.EncodeToString(span.TraceId)).To(Equal("4bf92f3577b34da6a3ce929d0e0e4736"))
+				if span.Name == "groot.Create" {
+					Expect(hex.EncodeToString(span.ParentSpanId)).To(Equal("00f067aa0ba902b7"))
 				}
+			}
+		})
+
+		Context("when the endpoint is invalid", func() {
+			BeforeEach(func() {
+				writeFile(configFilePath, "tracing:\n  endpoint: not-a-url\n")
+			})
+
+			It("fails", func() {
+				Expect(footCmdError).To(HaveOccurred())
+				Expect(footCmdOutput).To(gbytes.Say("invalid tracing.endpoint"))
 			})
 		})
 
-		Context("when --disk-limit-size-bytes is less than compressed image size and exclude-image-from-quota is set", func() {
+		Context("when the collector is unavailable", func() {
 			BeforeEach(func() {
-				footCmd = newFootCommand(configFilePath, driverStoreDir, "create", rootfsURI, "some-handle", "--disk-limit-size-bytes", "1", "--exclude-image-from-quota")
+				collector.Close()
 			})
 
-			It("succeeds", func() {
-				var args foot.UnpackCalls
-				unmarshalFile(filepath.Join(driverStoreDir, foot.UnpackArgsFileName), &args)
-				Expect(len(args)).NotTo(Equal(0))
+			It("still creates the bundle", func() {
+				Expect(footCmdError).NotTo(HaveOccurred())
 			})
 		})
+	})
 
-		Context("when --disk-limit-size-bytes is sufficiently large", func() {
+	Describe("Offline", func() {
+		var (
+			registry *ghttp.Server
+			cacheDir string
+			imageURL string
+		)
+
+		offlineCreate := func(storeDir string) ([]byte, error) {
+			return newFootCommand(configFilePath, storeDir, "create", imageURL, "offline-handle", "--offline").CombinedOutput()
+		}
+
+		BeforeEach(func() {
+			workDir, err := os.Getwd()
+			Expect(err).NotTo(HaveOccurred())
+			registry = testhelpers.NewOCIRegistry(filepath.Join(workDir, "oci-test-images", "opq-whiteouts-busybox"))
+			cacheDir = tempDir("", "blob-cache")
+			imageURL = fmt.Sprintf("docker://%s/some/image:latest", registry.Addr())
+
+			writeFile(configFilePath, fmt.Sprintf("insecure_registries: [%q]\nblob_cache:\n  path: %s\n", registry.Addr(), cacheDir))
+			footCmd = newFootCommand(configFilePath, driverStoreDir, "create", imageURL, "some-handle")
+		})
+
+		AfterEach(func() {
+			registry.Close()
+			Expect(os.RemoveAll(cacheDir)).To(Succeed())
+		})
+
+		It("creates the image from the blob cache without contacting the registry", func() {
+			Expect(footCmdError).NotTo(HaveOccurred())
+			onlineRequests := len(registry.ReceivedRequests())
+
+			storeDir := tempDir("", "offline-store")
+			defer os.RemoveAll(storeDir)
+			out, err := offlineCreate(storeDir)
+			Expect(err).NotTo(HaveOccurred(), string(out))
+			Expect(registry.ReceivedRequests()).To(HaveLen(onlineRequests))
+
+			var bundleArgs foot.BundleCalls
+			unmarshalFile(filepath.Join(storeDir, foot.BundleArgsFileName), &bundleArgs)
+			Expect(bundleArgs[0].LayerIDs).To(HaveLen(2))
+		})
+
+		It("can be enabled in the config file", func() {
+			Expect(footCmdError).NotTo(HaveOccurred())
+			onlineRequests := len(registry.ReceivedRequests())
+			writeFile(configFilePath, fmt.Sprintf("offline: true\nblob_cache:\n  path: %s\n", cacheDir))
+
+			storeDir := tempDir("", "offline-store")
+			defer os.RemoveAll(storeDir)
+			out, err := newFootCommand(configFilePath, storeDir, "create", imageURL, "offline-handle").CombinedOutput()
+			Expect(err).NotTo(HaveOccurred(), string(out))
+			Expect(registry.ReceivedRequests()).To(HaveLen(onlineRequests))
+		})
+
+		Context("when a layer blob is not in the blob cache", func() {
+			JustBeforeEach(func() {
+				Expect(footCmdError).NotTo(HaveOccurred())
+				Expect(os.Remove(filepath.Join(cacheDir, "blobs", "sha256", "56bec22e355981d8ba0878c6c2f23b21f422f30ab0aba188b54f1ffeff59c190"))).To(Succeed())
+			})
+
+			It("fails listing the missing blobs", func() {
+				storeDir := tempDir("", "offline-store")
+				defer os.RemoveAll(storeDir)
+				out, err := offlineCreate(storeDir)
+				Expect(err).To(HaveOccurred())
+				Expect(string(out)).To(ContainSubstring("missing blobs: sha256:56bec22e355981d8ba0878c6c2f23b21f422f30ab0aba188b54f1ffeff59c190"))
+				Expect(string(out)).NotTo(ContainSubstring("ed2d7b0f6d7786230b71fd60de08a553680a9a96ab216183bcc49c71f06033ab"))
+			})
+
+			It("succeeds when the driver already has the layer", func() {
+				cmd := newFootCommand(configFilePath, driverStoreDir, "create", imageURL, "offline-handle", "--offline")
+				cmd.Env = append(os.Environ(), "FOOT_LAYER_EXISTS=true")
+				out, err := cmd.CombinedOutput()
+				Expect(err).NotTo(HaveOccurred(), string(out))
+			})
+		})
+
+		Context("when the image was never pulled", func() {
 			BeforeEach(func() {
-				footCmd = newFootCommand(configFilePath, driverStoreDir, "create", rootfsURI, "some-handle", "--disk-limit-size-bytes", "99999999")
+				footCmd = newFootCommand(configFilePath, driverStoreDir, "create", imageURL, "some-handle", "--offline")
 			})
 
-			It("succeeds", func() {
-				var unpackArgs foot.UnpackCalls
-				unmarshalFile(filepath.Join(driverStoreDir, foot.UnpackArgsFileName), &unpackArgs)
-				Expect(len(unpackArgs)).NotTo(Equal(0))
+			It("fails without contacting the registry", func() {
+				expectErrorOutput("no manifest for docker://.*/some/image:latest in the blob cache")
+				Expect(registry.ReceivedRequests()).To(BeEmpty())
 			})
+		})
 
-			It("calculates the disk limit based on uncompressed layer sizes", func() {
-				var bundleArgs foot.BundleCalls
-				unmarshalFile(filepath.Join(driverStoreDir, foot.BundleArgsFileName), &bundleArgs)
+		Context("when there is no blob cache", func() {
+			BeforeEach(func() {
+				writeFile(configFilePath, fmt.Sprintf("insecure_registries: [%q]\n", registry.Addr()))
+				footCmd = newFootCommand(configFilePath, driverStoreDir, "create", imageURL, "some-handle", "--offline")
+			})
 
-				blobsPath := fmt.Sprintf("%s/oci-test-images/opq-whiteouts-busybox/blobs/sha256", workDir)
+			It("fails without contacting the registry", func() {
+				expectErrorOutput("registry images can only be pulled offline from a blob cache")
+				Expect(registry.ReceivedRequests()).To(BeEmpty())
+			})
+		})
 
-				// yuck, this is white-box because we know this is how foot calculates size
-				firstBlobSize := getUncompressedBlobSize(filepath.Join(blobsPath, "56bec22e355981d8ba0878c6c2f23b21f422f30ab0aba188b54f1ffeff59c190"))
-				secondBlobSize := getUncompressedBlobSize(filepath.Join(blobsPath, "ed2d7b0f6d7786230b71fd60de08a553680a9a96ab216183bcc49c71f06033ab"))
+		Context("when the image is an OCI layout", func() {
+			BeforeEach(func() {
+				workDir, err := os.Getwd()
+				Expect(err).NotTo(HaveOccurred())
+				footCmd = newFootCommand(configFilePath, driverStoreDir, "create", fmt.Sprintf("oci:///%s/oci-test-images/opq-whiteouts-busybox:latest", workDir), "some-handle", "--offline")
+			})
 
-				Expect(bundleArgs[0].DiskLimit).To(BeEquivalentTo(99999999 - (firstBlobSize + secondBlobSize)))
+			It("creates the image", func() {
+				Expect(footCmdError).NotTo(HaveOccurred(), string(footCmdOutput.Contents()))
 			})
 		})
 	})
@@ -315,6 +1682,16 @@ var _ = Describe("create", func() {
 			})
 		})
 
+		Context("when driver.LayerExists() returns an error", func() {
+			BeforeEach(func() {
+				footCmd.Env = append(os.Environ(), "FOOT_LAYER_EXISTS_ERROR=true")
+			})
+
+			It("prints the error", func() {
+				expectErrorOutput("layer-exists-err")
+			})
+		})
+
 		Context("when driver.Bundle() returns an error", func() {
 			BeforeEach(func() {
 				footCmd.Env = append(os.Environ(), "FOOT_BUNDLE_ERROR=true")
@@ -382,3 +1759,31 @@ func getUncompressedBlobSize(path string) int64 {
 
 	return int64(len(bytes))
 }
+
+// writeClientCertificate writes a self-signed client certificate and its key
+func writeClientCertificate(certPath, keyPath string) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "groot-test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	Expect(err).NotTo(HaveOccurred())
+	cert, err := x509.ParseCertificate(der)
+	Expect(err).NotTo(HaveOccurred())
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	Expect(err).NotTo(HaveOccurred())
+
+	writeFile(certPath, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})))
+	writeFile(keyPath, string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})))
+	return cert
+}
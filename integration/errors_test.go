@@ -0,0 +1,187 @@
+package integration_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"code.cloudfoundry.org/groot"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("errors", func() {
+	var (
+		rootfsURI      string
+		footCmd        *exec.Cmd
+		driverStoreDir string
+		configFilePath string
+		stdout         *bytes.Buffer
+		stderr         *bytes.Buffer
+		exitCode       int
+	)
+
+	BeforeEach(func() {
+		driverStoreDir = tempDir("", "groot-integration-tests")
+		configFilePath = filepath.Join(driverStoreDir, "groot-config.yml")
+		rootfsURI = filepath.Join(driverStoreDir, "rootfs.tar")
+
+		writeFile(configFilePath, "")
+		writeFile(rootfsURI, "a-rootfs")
+
+		footCmd = newFootCommand(configFilePath, driverStoreDir, "create", rootfsURI, "some-handle")
+	})
+
+	JustBeforeEach(func() {
+		stdout = new(bytes.Buffer)
+		stderr = new(bytes.Buffer)
+		footCmd.Stdout = stdout
+		footCmd.Stderr = stderr
+
+		err := footCmd.Run()
+		Expect(err).To(HaveOccurred())
+		exitErr, ok := err.(*exec.ExitError)
+		Expect(ok).To(BeTrue())
+		exitCode = exitErr.ExitCode()
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(driverStoreDir)).To(Succeed())
+	})
+
+	Context("when the incorrect number of args is given", func() {
+		BeforeEach(func() {
+			footCmd = newFootCommand(configFilePath, driverStoreDir, "create", rootfsURI)
+		})
+
+		It("exits with the invalid argument exit code", func() {
+			Expect(exitCode).To(Equal(groot.ExitCodeInvalidArgument))
+		})
+	})
+
+	Context("when the config file is invalid", func() {
+		BeforeEach(func() {
+			writeFile(configFilePath, "not-yaml: [")
+		})
+
+		It("exits with the invalid argument exit code", func() {
+			Expect(exitCode).To(Equal(groot.ExitCodeInvalidArgument))
+		})
+	})
+
+	Context("when the image does not fit in the disk limit", func() {
+		BeforeEach(func() {
+			footCmd = newFootCommand(configFilePath, driverStoreDir, "create", rootfsURI, "some-handle", "--disk-limit-size-bytes", "1")
+		})
+
+		It("exits with the quota exceeded exit code", func() {
+			Expect(exitCode).To(Equal(groot.ExitCodeQuotaExceeded))
+		})
+	})
+
+	Context("when the local image does not exist", func() {
+		BeforeEach(func() {
+			Expect(os.Remove(rootfsURI)).To(Succeed())
+		})
+
+		It("exits with the image not found exit code", func() {
+			Expect(exitCode).To(Equal(groot.ExitCodeImageNotFound))
+		})
+	})
+
+	Context("when the image policy does not allow the image", func() {
+		BeforeEach(func() {
+			writeFile(configFilePath, "image_policy:\n  deny:\n  - repository: cf/*\n")
+			footCmd = newFootCommand(configFilePath, driverStoreDir, "create", "docker://127.0.0.1:1/cf/busybox:latest", "some-handle")
+		})
+
+		It("exits with the policy rejected exit code", func() {
+			Expect(exitCode).To(Equal(groot.ExitCodePolicyRejected))
+		})
+
+		Context("when --json-errors is given", func() {
+			BeforeEach(func() {
+				footCmd = exec.Command(footBinPath, "--config", configFilePath, "--json-errors", "--driver-store", driverStoreDir, "create", "docker://127.0.0.1:1/cf/busybox:latest", "some-handle")
+			})
+
+			It("reports the policy_rejected kind", func() {
+				lines := bytes.Split(bytes.TrimSpace(stderr.Bytes()), []byte("\n"))
+				var jsonError groot.JSONError
+				Expect(json.Unmarshal(lines[len(lines)-1], &jsonError)).To(Succeed())
+				Expect(jsonError.Kind).To(Equal("policy_rejected"))
+				Expect(jsonError.ExitCode).To(Equal(groot.ExitCodePolicyRejected))
+			})
+		})
+	})
+
+	Context("when a pattern of the image policy is invalid", func() {
+		BeforeEach(func() {
+			writeFile(configFilePath, "image_policy:\n  deny:\n  - repository: \"[\"\n")
+			footCmd = newFootCommand(configFilePath, driverStoreDir, "create", "docker://127.0.0.1:1/cf/busybox:latest", "some-handle")
+		})
+
+		It("exits with the invalid argument exit code", func() {
+			Expect(exitCode).To(Equal(groot.ExitCodeInvalidArgument))
+		})
+	})
+
+	Context("when the signature policy rejects the image", func() {
+		BeforeEach(func() {
+			if !signatureVerificationSupported {
+				Skip("signature policies are only enforced with the containers_image_openpgp build tag")
+			}
+
+			workDir, err := os.Getwd()
+			Expect(err).NotTo(HaveOccurred())
+			policyPath := filepath.Join(driverStoreDir, "policy.json")
+			writeFile(policyPath, `{"default": [{"type": "reject"}]}`)
+			writeFile(configFilePath, fmt.Sprintf("signature_policy_file: %s\n", policyPath))
+			footCmd = newFootCommand(configFilePath, driverStoreDir, "create", fmt.Sprintf("oci:///%s/oci-test-images/opq-whiteouts-busybox:latest", workDir), "some-handle")
+		})
+
+		It("exits with the policy rejected exit code", func() {
+			Expect(exitCode).To(Equal(groot.ExitCodePolicyRejected))
+		})
+	})
+
+	Context("when the driver fails", func() {
+		BeforeEach(func() {
+			footCmd.Env = append(os.Environ(), "FOOT_BUNDLE_ERROR=true")
+		})
+
+		It("exits with the driver failure exit code", func() {
+			Expect(exitCode).To(Equal(groot.ExitCodeDriverFailure))
+		})
+
+		It("prints the error to stdout", func() {
+			Expect(stdout.String()).To(ContainSubstring("bundle-err"))
+		})
+
+		It("does not print JSON to stderr", func() {
+			Expect(stderr.String()).NotTo(ContainSubstring(`"kind"`))
+		})
+
+		Context("when --json-errors is given", func() {
+			BeforeEach(func() {
+				footCmd = exec.Command(footBinPath, "--config", configFilePath, "--json-errors", "--driver-store", driverStoreDir, "create", rootfsURI, "some-handle")
+				footCmd.Env = append(os.Environ(), "FOOT_BUNDLE_ERROR=true")
+			})
+
+			It("prints the error to stderr as JSON", func() {
+				lines := bytes.Split(bytes.TrimSpace(stderr.Bytes()), []byte("\n"))
+				var jsonError groot.JSONError
+				Expect(json.Unmarshal(lines[len(lines)-1], &jsonError)).To(Succeed())
+				Expect(jsonError.Error).To(ContainSubstring("bundle-err"))
+				Expect(jsonError.Kind).To(Equal("driver_failure"))
+				Expect(jsonError.ExitCode).To(Equal(groot.ExitCodeDriverFailure))
+			})
+
+			It("still prints the error to stdout", func() {
+				Expect(stdout.String()).To(ContainSubstring("bundle-err"))
+			})
+		})
+	})
+})
@@ -0,0 +1,191 @@
+package integration_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/groot"
+	"code.cloudfoundry.org/groot/integration/cmd/foot/foot"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("gc", func() {
+	var (
+		footCmd        *exec.Cmd
+		driverStoreDir string
+		configFilePath string
+		rootfsLayerID  string
+	)
+
+	BeforeEach(func() {
+		driverStoreDir = tempDir("", "groot-integration-tests")
+		configFilePath = filepath.Join(driverStoreDir, "groot-config.yml")
+		rootfsURI := filepath.Join(driverStoreDir, "rootfs.tar")
+		writeFile(configFilePath, "")
+		writeFile(rootfsURI, "a-rootfs")
+
+		workDir, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		out, err := newFootCommand(configFilePath, driverStoreDir, "create", fmt.Sprintf("oci:///%s/oci-test-images/opq-whiteouts-busybox:latest", workDir), "some-handle").CombinedOutput()
+		Expect(err).NotTo(HaveOccurred(), string(out))
+		out, err = newFootCommand(configFilePath, driverStoreDir, "create", rootfsURI, "deleted-handle").CombinedOutput()
+		Expect(err).NotTo(HaveOccurred(), string(out))
+		out, err = newFootCommand(configFilePath, driverStoreDir, "delete", "deleted-handle").CombinedOutput()
+		Expect(err).NotTo(HaveOccurred(), string(out))
+
+		var bundleArgs foot.BundleCalls
+		unmarshalFile(filepath.Join(driverStoreDir, foot.BundleArgsFileName), &bundleArgs)
+		rootfsLayerID = bundleArgs[1].LayerIDs[0]
+
+		writeFile(configFilePath, "gc:\n  grace_period: 0s\n")
+		footCmd = newFootCommand(configFilePath, driverStoreDir, "gc")
+	})
+
+	JustBeforeEach(func() {
+		var out []byte
+		out, footCmdError = footCmd.Output()
+		footCmdOutput = gbytes.BufferWithBytes(out)
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(driverStoreDir)).To(Succeed())
+	})
+
+	gcResult := func() groot.GCResult {
+		var result groot.GCResult
+		Expect(json.Unmarshal(footCmdOutput.Contents(), &result)).To(Succeed())
+		return result
+	}
+
+	Describe("success", func() {
+		It("deletes the layers of deleted bundles only", func() {
+			Expect(footCmdError).NotTo(HaveOccurred())
+
+			var deleteLayerArgs foot.DeleteLayerCalls
+			unmarshalFile(filepath.Join(driverStoreDir, foot.DeleteLayerArgsFileName), &deleteLayerArgs)
+			Expect(deleteLayerArgs).To(Equal(foot.DeleteLayerCalls{{LayerID: rootfsLayerID}}))
+		})
+
+		It("prints the collected layers as json", func() {
+			Expect(footCmdError).NotTo(HaveOccurred())
+			Expect(gcResult()).To(Equal(groot.GCResult{
+				Layers:         []string{rootfsLayerID},
+				ReclaimedBytes: int64(len("a-rootfs")),
+			}))
+		})
+
+		Context("when --dry-run is given", func() {
+			BeforeEach(func() {
+				footCmd = newFootCommand(configFilePath, driverStoreDir, "gc", "--dry-run")
+			})
+
+			It("prints the layers that would be collected without deleting them", func() {
+				Expect(footCmdError).NotTo(HaveOccurred())
+				Expect(gcResult()).To(Equal(groot.GCResult{
+					Layers:         []string{rootfsLayerID},
+					ReclaimedBytes: int64(len("a-rootfs")),
+					DryRun:         true,
+				}))
+				Expect(filepath.Join(driverStoreDir, foot.DeleteLayerArgsFileName)).NotTo(BeAnExistingFile())
+			})
+		})
+
+		Context("when the layers were used within the grace period", func() {
+			BeforeEach(func() {
+				writeFile(configFilePath, "gc:\n  grace_period: 1h\n")
+			})
+
+			It("does not delete them", func() {
+				Expect(footCmdError).NotTo(HaveOccurred())
+				Expect(gcResult().Layers).To(BeEmpty())
+				Expect(filepath.Join(driverStoreDir, foot.DeleteLayerArgsFileName)).NotTo(BeAnExistingFile())
+			})
+		})
+
+		Context("when no grace period is configured", func() {
+			BeforeEach(func() {
+				writeFile(configFilePath, "")
+			})
+
+			It("does not delete the layers used within the last hour", func() {
+				Expect(footCmdError).NotTo(HaveOccurred())
+				Expect(gcResult().Layers).To(BeEmpty())
+			})
+
+			Context("when the layers were used longer ago", func() {
+				BeforeEach(func() {
+					longAgo := time.Now().Add(-2 * time.Hour)
+					Expect(os.Chtimes(filepath.Join(driverStoreDir, foot.UnpackArgsFileName), longAgo, longAgo)).To(Succeed())
+				})
+
+				It("deletes them", func() {
+					Expect(footCmdError).NotTo(HaveOccurred())
+					Expect(gcResult().Layers).To(Equal([]string{rootfsLayerID}))
+				})
+
+				Context("when a create that has not bundled them yet found them to exist since", func() {
+					BeforeEach(func() {
+						createCmd := newFootCommand(configFilePath, driverStoreDir, "create", filepath.Join(driverStoreDir, "rootfs.tar"), "another-handle")
+						createCmd.Env = append(os.Environ(), "FOOT_LAYER_EXISTS=true", "FOOT_BUNDLE_ERROR=true")
+						Expect(createCmd.Run()).NotTo(Succeed())
+					})
+
+					It("does not delete them", func() {
+						Expect(footCmdError).NotTo(HaveOccurred())
+						Expect(gcResult().Layers).To(BeEmpty())
+					})
+				})
+			})
+		})
+
+		Context("when the layers are below the threshold", func() {
+			BeforeEach(func() {
+				writeFile(configFilePath, "gc:\n  threshold_bytes: 100000000\n")
+			})
+
+			It("does not delete them", func() {
+				Expect(footCmdError).NotTo(HaveOccurred())
+				Expect(gcResult().Layers).To(BeEmpty())
+				Expect(filepath.Join(driverStoreDir, foot.DeleteLayerArgsFileName)).NotTo(BeAnExistingFile())
+			})
+		})
+	})
+
+	Describe("failure", func() {
+		Context("when driver.DeleteLayer() returns an error", func() {
+			BeforeEach(func() {
+				footCmd.Env = append(os.Environ(), "FOOT_DELETE_LAYER_ERROR=true")
+			})
+
+			It("prints the error", func() {
+				expectErrorOutput("delete-layer-err")
+			})
+		})
+
+		Context("when driver.ListLayers() returns an error", func() {
+			BeforeEach(func() {
+				footCmd.Env = append(os.Environ(), "FOOT_LIST_LAYERS_ERROR=true")
+			})
+
+			It("prints the error", func() {
+				expectErrorOutput("list-layers-err")
+			})
+		})
+
+		Context("when args are given", func() {
+			BeforeEach(func() {
+				footCmd = newFootCommand(configFilePath, driverStoreDir, "gc", "some-handle")
+			})
+
+			It("prints an error", func() {
+				expectErrorOutput("Incorrect number of args. Expect 0, got 1")
+			})
+		})
+	})
+})
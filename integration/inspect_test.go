@@ -0,0 +1,157 @@
+package integration_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"code.cloudfoundry.org/groot"
+	"code.cloudfoundry.org/groot/integration/cmd/foot/foot"
+	"code.cloudfoundry.org/groot/testhelpers"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/ghttp"
+	imgspec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+var _ = Describe("inspect", func() {
+	var (
+		footCmd        *exec.Cmd
+		driverStoreDir string
+		configFilePath string
+		workDir        string
+		inspection     groot.ImageInspection
+	)
+
+	BeforeEach(func() {
+		driverStoreDir = tempDir("", "groot-integration-tests")
+		configFilePath = filepath.Join(driverStoreDir, "groot-config.yml")
+		writeFile(configFilePath, "")
+
+		var err error
+		workDir, err = os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		footCmd = newFootCommand(configFilePath, driverStoreDir, "inspect", fmt.Sprintf("oci:///%s/oci-test-images/opq-whiteouts-busybox:latest", workDir))
+	})
+
+	JustBeforeEach(func() {
+		var out []byte
+		out, footCmdError = footCmd.Output()
+		footCmdOutput = gbytes.BufferWithBytes(out)
+
+		inspection = groot.ImageInspection{}
+		if footCmdError == nil {
+			Expect(json.Unmarshal(out, &inspection)).To(Succeed())
+		}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(driverStoreDir)).To(Succeed())
+	})
+
+	expectBusyboxInspection := func() {
+		Expect(footCmdError).NotTo(HaveOccurred())
+		Expect(inspection.ManifestDigest).To(Equal("sha256:9c90ae0cffa9d1426e83a516183f0267e03edbb765efc5fb0c0dccc8edca4f15"))
+		Expect(inspection.MediaType).To(Equal(imgspec.MediaTypeImageManifest))
+		Expect(inspection.Platform).To(Equal(imgspec.Platform{OS: "linux", Architecture: "amd64"}))
+		Expect(inspection.Config.Env).To(ConsistOf("PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"))
+		Expect(inspection.Layers).To(Equal([]groot.LayerInspection{
+			{
+				BlobID:    "sha256:56bec22e355981d8ba0878c6c2f23b21f422f30ab0aba188b54f1ffeff59c190",
+				DiffID:    "e88b3f82283bc59d5e0df427c824e9f95557e661fcb0ea15fb0fb6f97760f9d9",
+				ChainID:   "e88b3f82283bc59d5e0df427c824e9f95557e661fcb0ea15fb0fb6f97760f9d9",
+				Size:      668151,
+				MediaType: imgspec.MediaTypeImageLayerGzip,
+			},
+			{
+				BlobID:    "sha256:ed2d7b0f6d7786230b71fd60de08a553680a9a96ab216183bcc49c71f06033ab",
+				DiffID:    "1e664bbd066a13dc6e8d9503fe0d439e89617eaac0558a04240bcbf4bd969ff9",
+				ChainID:   "43041102f82e9755441c9732a60ef1c7f6ef6516ad6cd5e07f9a49f8544c3b57",
+				Size:      124,
+				MediaType: imgspec.MediaTypeImageLayerGzip,
+			},
+		}))
+	}
+
+	Describe("OCI images", func() {
+		It("prints the image metadata as json", func() {
+			expectBusyboxInspection()
+		})
+
+		It("does not unpack or bundle anything", func() {
+			Expect(footCmdError).NotTo(HaveOccurred())
+			Expect(filepath.Join(driverStoreDir, foot.UnpackArgsFileName)).NotTo(BeAnExistingFile())
+			Expect(filepath.Join(driverStoreDir, foot.BundleArgsFileName)).NotTo(BeAnExistingFile())
+		})
+	})
+
+	Describe("Remote images", func() {
+		var registry *ghttp.Server
+
+		BeforeEach(func() {
+			registry = testhelpers.NewOCIRegistry(filepath.Join(workDir, "oci-test-images", "opq-whiteouts-busybox"))
+			writeFile(configFilePath, fmt.Sprintf("insecure_registries: [%q]\n", registry.Addr()))
+			footCmd = newFootCommand(configFilePath, driverStoreDir, "inspect", fmt.Sprintf("docker://%s/some/image:latest", registry.Addr()))
+		})
+
+		AfterEach(func() {
+			registry.Close()
+		})
+
+		It("prints the image metadata as json", func() {
+			expectBusyboxInspection()
+		})
+
+		It("does not download any layer", func() {
+			Expect(footCmdError).NotTo(HaveOccurred())
+			for _, req := range registry.ReceivedRequests() {
+				Expect(req.URL.Path).NotTo(ContainSubstring("56bec22e"))
+				Expect(req.URL.Path).NotTo(ContainSubstring("ed2d7b0f"))
+			}
+		})
+	})
+
+	Describe("Local images", func() {
+		var rootfsURI string
+
+		BeforeEach(func() {
+			rootfsURI = filepath.Join(driverStoreDir, "rootfs.tar")
+			writeFile(rootfsURI, "a-rootfs")
+			footCmd = newFootCommand(configFilePath, driverStoreDir, "inspect", rootfsURI)
+		})
+
+		It("prints the single layer of the image", func() {
+			Expect(footCmdError).NotTo(HaveOccurred())
+			Expect(inspection.ManifestDigest).To(BeEmpty())
+			Expect(inspection.Layers).To(HaveLen(1))
+			Expect(inspection.Layers[0].BlobID).To(Equal(rootfsURI))
+			Expect(inspection.Layers[0].ChainID).NotTo(BeEmpty())
+			Expect(inspection.Layers[0].Size).To(Equal(int64(len("a-rootfs"))))
+		})
+	})
+
+	Describe("failure", func() {
+		Context("when the image does not exist", func() {
+			BeforeEach(func() {
+				footCmd = newFootCommand(configFilePath, driverStoreDir, "inspect", fmt.Sprintf("oci:///%s/oci-test-images/not-here:latest", workDir))
+			})
+
+			It("prints an error", func() {
+				expectErrorOutput("inspecting image")
+			})
+		})
+
+		Context("when the incorrect number of args is given", func() {
+			BeforeEach(func() {
+				footCmd = newFootCommand(configFilePath, driverStoreDir, "inspect")
+			})
+
+			It("prints an error", func() {
+				expectErrorOutput("Incorrect number of args. Expect 1, got 0")
+			})
+		})
+	})
+})
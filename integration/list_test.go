@@ -0,0 +1,104 @@
+package integration_test
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"code.cloudfoundry.org/groot"
+	"code.cloudfoundry.org/groot/integration/cmd/foot/foot"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("list", func() {
+	var (
+		footCmd        *exec.Cmd
+		driverStoreDir string
+		configFilePath string
+	)
+
+	BeforeEach(func() {
+		driverStoreDir = tempDir("", "groot-integration-tests")
+		configFilePath = filepath.Join(driverStoreDir, "groot-config.yml")
+		rootfsURI := filepath.Join(driverStoreDir, "rootfs.tar")
+		writeFile(configFilePath, "")
+		writeFile(rootfsURI, "a-rootfs")
+
+		for _, handle := range []string{"some-handle", "another-handle", "deleted-handle"} {
+			out, err := newFootCommand(configFilePath, driverStoreDir, "create", rootfsURI, handle).CombinedOutput()
+			Expect(err).NotTo(HaveOccurred(), string(out))
+		}
+		out, err := newFootCommand(configFilePath, driverStoreDir, "delete", "deleted-handle").CombinedOutput()
+		Expect(err).NotTo(HaveOccurred(), string(out))
+
+		footCmd = newFootCommand(configFilePath, driverStoreDir, "list")
+	})
+
+	JustBeforeEach(func() {
+		var out []byte
+		out, footCmdError = footCmd.Output()
+		footCmdOutput = gbytes.BufferWithBytes(out)
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(driverStoreDir)).To(Succeed())
+	})
+
+	Describe("success", func() {
+		It("prints every bundle with its metadata and stats as json", func() {
+			Expect(footCmdError).NotTo(HaveOccurred())
+
+			var bundles []groot.BundleInfo
+			Expect(json.Unmarshal(footCmdOutput.Contents(), &bundles)).To(Succeed())
+			Expect(bundles).To(HaveLen(2))
+			Expect([]string{bundles[0].Handle, bundles[1].Handle}).To(ConsistOf("some-handle", "another-handle"))
+			for _, bundle := range bundles {
+				Expect(bundle.ImageMetadata.Size).To(Equal(int64(len("a-rootfs"))))
+				Expect(bundle.Stats).To(Equal(foot.ReturnedVolumeStats))
+			}
+		})
+
+		It("calls driver.Stats() for every bundle", func() {
+			Expect(footCmdError).NotTo(HaveOccurred())
+
+			var statsArgs foot.StatsCalls
+			unmarshalFile(filepath.Join(driverStoreDir, foot.StatsArgsFileName), &statsArgs)
+			Expect(statsArgs).To(ConsistOf(foot.StatsArgs{ID: "some-handle"}, foot.StatsArgs{ID: "another-handle"}))
+		})
+	})
+
+	Describe("failure", func() {
+		Context("when driver.ListBundles() returns an error", func() {
+			BeforeEach(func() {
+				footCmd.Env = append(os.Environ(), "FOOT_LIST_BUNDLES_ERROR=true")
+			})
+
+			It("prints the error", func() {
+				expectErrorOutput("list-bundles-err")
+			})
+		})
+
+		Context("when driver.Stats() returns an error", func() {
+			BeforeEach(func() {
+				footCmd.Env = append(os.Environ(), "FOOT_STATS_ERROR=true")
+			})
+
+			It("prints the error", func() {
+				expectErrorOutput("stats-err")
+			})
+		})
+
+		Context("when args are given", func() {
+			BeforeEach(func() {
+				footCmd = newFootCommand(configFilePath, driverStoreDir, "list", "some-handle")
+			})
+
+			It("prints an error", func() {
+				expectErrorOutput("Incorrect number of args. Expect 0, got 1")
+			})
+		})
+	})
+})
@@ -0,0 +1,195 @@
+package integration_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/groot"
+	"code.cloudfoundry.org/groot/testhelpers"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("logging", func() {
+	var (
+		footCmd        *exec.Cmd
+		driverStoreDir string
+		configFilePath string
+		rootfsURI      string
+		stderr         *bytes.Buffer
+		runErr         error
+	)
+
+	logLines := func(logs []byte) []map[string]interface{} {
+		lines := []map[string]interface{}{}
+		for _, line := range bytes.Split(bytes.TrimSpace(logs), []byte("\n")) {
+			var log map[string]interface{}
+			Expect(json.Unmarshal(line, &log)).To(Succeed(), string(line))
+			lines = append(lines, log)
+		}
+		return lines
+	}
+
+	BeforeEach(func() {
+		driverStoreDir = tempDir("", "groot-integration-tests")
+		configFilePath = filepath.Join(driverStoreDir, "groot-config.yml")
+		rootfsURI = filepath.Join(driverStoreDir, "rootfs.tar")
+
+		writeFile(configFilePath, "log_level: debug\n")
+		writeFile(rootfsURI, "a-rootfs")
+
+		footCmd = newFootCommand(configFilePath, driverStoreDir, "create", rootfsURI, "some-handle")
+	})
+
+	JustBeforeEach(func() {
+		stderr = new(bytes.Buffer)
+		footCmd.Stderr = stderr
+		runErr = footCmd.Run()
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(driverStoreDir)).To(Succeed())
+	})
+
+	It("writes lager's pretty format to stderr by default", func() {
+		Expect(runErr).NotTo(HaveOccurred())
+
+		logs := logLines(stderr.Bytes())
+		Expect(logs).NotTo(BeEmpty())
+		for _, log := range logs {
+			Expect(log).To(HaveKeyWithValue("level", BeAssignableToTypeOf("")))
+			Expect(log).To(HaveKey("message"))
+			Expect(time.Parse(time.RFC3339Nano, log["timestamp"].(string))).To(BeTemporally("~", time.Now(), time.Minute))
+		}
+	})
+
+	Context("when the log format is json", func() {
+		BeforeEach(func() {
+			writeFile(configFilePath, "log_level: debug\nlog_format: json\n")
+		})
+
+		It("writes a JSON object with an RFC3339 timestamp per line", func() {
+			Expect(runErr).NotTo(HaveOccurred())
+
+			logs := logLines(stderr.Bytes())
+			Expect(logs).NotTo(BeEmpty())
+			for _, log := range logs {
+				Expect(log).To(HaveKey("msg"))
+				Expect(log).To(HaveKey("level"))
+				Expect(time.Parse(time.RFC3339Nano, log["time"].(string))).To(BeTemporally("~", time.Now(), time.Minute))
+			}
+			Expect(logs).To(ContainElement(HaveKeyWithValue("msg", "groot.create.starting")))
+		})
+	})
+
+	Context("when the log format is lager", func() {
+		BeforeEach(func() {
+			writeFile(configFilePath, "log_level: debug\nlog_format: lager\n")
+		})
+
+		It("writes lager's default format", func() {
+			Expect(runErr).NotTo(HaveOccurred())
+
+			for _, log := range logLines(stderr.Bytes()) {
+				Expect(log).To(HaveKeyWithValue("log_level", BeNumerically(">=", 0)))
+				Expect(log).To(HaveKeyWithValue("timestamp", MatchRegexp(`^\d+\.\d+$`)))
+			}
+		})
+	})
+
+	Context("when the log format is invalid", func() {
+		BeforeEach(func() {
+			writeFile(configFilePath, "log_format: xml\n")
+		})
+
+		It("exits with the invalid argument exit code", func() {
+			Expect(runErr).To(HaveOccurred())
+			Expect(runErr.(*exec.ExitError).ExitCode()).To(Equal(groot.ExitCodeInvalidArgument))
+		})
+	})
+
+	Context("when a log file is given", func() {
+		var logFilePath string
+
+		BeforeEach(func() {
+			logFilePath = filepath.Join(driverStoreDir, "groot.log")
+			writeFile(configFilePath, fmt.Sprintf("log_level: debug\nlog_file:\n  path: %s\n", logFilePath))
+		})
+
+		It("writes the logs to the file instead of stderr", func() {
+			Expect(runErr).NotTo(HaveOccurred())
+
+			Expect(stderr.String()).To(BeEmpty())
+			Expect(logLines(readFile(logFilePath))).To(ContainElement(HaveKeyWithValue("message", "groot.create.starting")))
+		})
+
+		It("appends to the file", func() {
+			Expect(runErr).NotTo(HaveOccurred())
+			firstRunLogs := readFile(logFilePath)
+
+			Expect(newFootCommand(configFilePath, driverStoreDir, "delete", "some-handle").Run()).To(Succeed())
+			Expect(readFile(logFilePath)).To(HavePrefix(string(firstRunLogs)))
+			Expect(len(readFile(logFilePath))).To(BeNumerically(">", len(firstRunLogs)))
+		})
+
+		Context("when a maximum size is given", func() {
+			const maxSize = 1024
+
+			BeforeEach(func() {
+				writeFile(configFilePath, fmt.Sprintf("log_level: debug\nlog_file:\n  path: %s\n  max_size_bytes: %d\n  max_backups: 2\n", logFilePath, maxSize))
+			})
+
+			It("rotates the file before it grows larger than the maximum size", func() {
+				Expect(runErr).NotTo(HaveOccurred())
+				Expect(newFootCommand(configFilePath, driverStoreDir, "delete", "some-handle").Run()).To(Succeed())
+
+				for _, path := range []string{logFilePath, logFilePath + ".1", logFilePath + ".2"} {
+					logs := readFile(path)
+					Expect(len(logs)).To(BeNumerically("<=", maxSize), path)
+					logLines(logs)
+				}
+				Expect(logFilePath + ".3").NotTo(BeAnExistingFile())
+			})
+		})
+
+		Context("when the log file cannot be opened", func() {
+			BeforeEach(func() {
+				writeFile(configFilePath, fmt.Sprintf("log_file:\n  path: %s\n", filepath.Join(driverStoreDir, "missing", "groot.log")))
+			})
+
+			It("fails", func() {
+				Expect(runErr).To(HaveOccurred())
+			})
+		})
+	})
+
+	Context("when the image URL has credentials", func() {
+		var registry *ghttp.Server
+
+		BeforeEach(func() {
+			workDir, err := os.Getwd()
+			Expect(err).NotTo(HaveOccurred())
+			registry = testhelpers.NewOCIRegistry(filepath.Join(workDir, "oci-test-images", "opq-whiteouts-busybox"))
+
+			writeFile(configFilePath, fmt.Sprintf("log_level: debug\ninsecure_registries: [%q]\n", registry.Addr()))
+			footCmd = newFootCommand(configFilePath, driverStoreDir, "create", fmt.Sprintf("docker://user:hunter2@%s/some/image:latest", registry.Addr()), "some-handle")
+		})
+
+		AfterEach(func() {
+			registry.Close()
+		})
+
+		It("redacts them from the logs", func() {
+			Expect(runErr).NotTo(HaveOccurred())
+
+			Expect(stderr.String()).To(ContainSubstring("docker://*REDACTED*@"))
+			Expect(stderr.String()).NotTo(ContainSubstring("hunter2"))
+		})
+	})
+})
@@ -0,0 +1,80 @@
+package integration_test
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"code.cloudfoundry.org/groot"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("metadata", func() {
+	var (
+		footCmd        *exec.Cmd
+		driverStoreDir string
+		configFilePath string
+		rootfsURI      string
+	)
+
+	BeforeEach(func() {
+		driverStoreDir = tempDir("", "groot-integration-tests")
+		configFilePath = filepath.Join(driverStoreDir, "groot-config.yml")
+		rootfsURI = filepath.Join(driverStoreDir, "rootfs.tar")
+		writeFile(configFilePath, "")
+		writeFile(rootfsURI, "a-rootfs")
+
+		out, err := newFootCommand(configFilePath, driverStoreDir, "create", rootfsURI, "some-handle").CombinedOutput()
+		Expect(err).NotTo(HaveOccurred(), string(out))
+
+		footCmd = newFootCommand(configFilePath, driverStoreDir, "metadata", "some-handle")
+	})
+
+	JustBeforeEach(func() {
+		var out []byte
+		out, footCmdError = footCmd.Output()
+		footCmdOutput = gbytes.BufferWithBytes(out)
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(driverStoreDir)).To(Succeed())
+	})
+
+	Describe("success", func() {
+		It("prints the metadata written when the bundle was created as json", func() {
+			Expect(footCmdError).NotTo(HaveOccurred())
+
+			var metadata groot.ImageMetadata
+			Expect(json.Unmarshal(footCmdOutput.Contents(), &metadata)).To(Succeed())
+			Expect(metadata.ImageURL).To(Equal(rootfsURI))
+			Expect(metadata.Size).To(Equal(int64(len("a-rootfs"))))
+			Expect(metadata.ChainIDs).To(HaveLen(1))
+			Expect(metadata.CreatedAt).NotTo(BeZero())
+		})
+	})
+
+	Describe("failure", func() {
+		Context("when driver.ReadMetadata() returns an error", func() {
+			BeforeEach(func() {
+				footCmd.Env = append(os.Environ(), "FOOT_READ_METADATA_ERROR=true")
+			})
+
+			It("prints the error", func() {
+				expectErrorOutput("read-metadata-err")
+			})
+		})
+
+		Context("when the incorrect number of args is given", func() {
+			BeforeEach(func() {
+				footCmd = newFootCommand(configFilePath, driverStoreDir, "metadata")
+			})
+
+			It("prints an error", func() {
+				expectErrorOutput("Incorrect number of args. Expect 1, got 0")
+			})
+		})
+	})
+})
@@ -1,6 +1,7 @@
 package integration_test
 
 import (
+	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -116,6 +117,23 @@ var _ = Describe("pull", func() {
 		})
 	})
 
+	Describe("Multi-architecture images", func() {
+		BeforeEach(func() {
+			workDir, err := os.Getwd()
+			Expect(err).NotTo(HaveOccurred())
+			rootfsURI = fmt.Sprintf("oci:///%s/oci-test-images/multi-arch:latest", workDir)
+			footCmd = newFootCommand(configFilePath, driverStoreDir, "pull", rootfsURI, "--platform", "linux/arm64/v8")
+		})
+
+		It("unpacks the layers of the image for the given platform", func() {
+			Expect(footCmdError).NotTo(HaveOccurred())
+
+			var args foot.UnpackCalls
+			unmarshalFile(filepath.Join(driverStoreDir, foot.UnpackArgsFileName), &args)
+			Expect(args).To(HaveLen(1))
+		})
+	})
+
 	Describe("failure", func() {
 		Context("when driver.Unpack() returns an error", func() {
 			BeforeEach(func() {
@@ -0,0 +1,38 @@
+package groot
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+func (g *Groot) List(ctx context.Context) ([]BundleInfo, error) {
+	g.Logger = g.Logger.Session("list")
+	g.Logger.Debug("starting")
+	defer g.Logger.Debug("ending")
+
+	lister, ok := g.Driver.(BundleLister)
+	if !ok {
+		return nil, driverFailure(errors.New("driver does not support listing bundles"))
+	}
+
+	bundles, err := lister.ListBundles(g.Logger)
+	if err != nil {
+		return nil, driverFailure(errors.Wrap(err, "listing bundles"))
+	}
+
+	bundleInfos := []BundleInfo{}
+	for _, bundle := range bundles {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		stats, err := g.Driver.Stats(g.Logger, bundle.Handle)
+		if err != nil {
+			return nil, driverFailure(errors.Wrapf(err, "getting stats of bundle `%s`", bundle.Handle))
+		}
+		bundleInfos = append(bundleInfos, BundleInfo{Bundle: bundle, Stats: stats})
+	}
+
+	return bundleInfos, nil
+}
@@ -0,0 +1,124 @@
+package groot_test
+
+import (
+	"context"
+
+	"code.cloudfoundry.org/groot"
+	"code.cloudfoundry.org/groot/grootfakes"
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	errors "github.com/pkg/errors"
+)
+
+var _ = Describe("List", func() {
+	var (
+		driver       *grootfakes.FakeDriver
+		bundleLister *grootfakes.FakeBundleLister
+
+		logger *lagertest.TestLogger
+		g      *groot.Groot
+	)
+
+	BeforeEach(func() {
+		driver = new(grootfakes.FakeDriver)
+		bundleLister = new(grootfakes.FakeBundleLister)
+
+		bundleLister.ListBundlesReturns([]groot.Bundle{
+			{Handle: "handle-1", ImageMetadata: groot.ImageMetadata{Size: 100}},
+			{Handle: "handle-2", ImageMetadata: groot.ImageMetadata{Size: 200}},
+		}, nil)
+		driver.StatsStub = func(_ lager.Logger, handle string) (groot.VolumeStats, error) {
+			if handle == "handle-1" {
+				return groot.VolumeStats{DiskUsage: groot.DiskUsage{TotalBytesUsed: 110, ExclusiveBytesUsed: 10}}, nil
+			}
+			return groot.VolumeStats{DiskUsage: groot.DiskUsage{TotalBytesUsed: 220, ExclusiveBytesUsed: 20}}, nil
+		}
+
+		logger = lagertest.NewTestLogger("groot")
+		g = &groot.Groot{
+			Driver: &listingDriver{FakeDriver: driver, FakeBundleLister: bundleLister},
+			Logger: logger,
+		}
+	})
+
+	It("returns every bundle with its metadata and stats", func() {
+		bundles, err := g.List(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(bundles).To(Equal([]groot.BundleInfo{
+			{
+				Bundle: groot.Bundle{Handle: "handle-1", ImageMetadata: groot.ImageMetadata{Size: 100}},
+				Stats:  groot.VolumeStats{DiskUsage: groot.DiskUsage{TotalBytesUsed: 110, ExclusiveBytesUsed: 10}},
+			},
+			{
+				Bundle: groot.Bundle{Handle: "handle-2", ImageMetadata: groot.ImageMetadata{Size: 200}},
+				Stats:  groot.VolumeStats{DiskUsage: groot.DiskUsage{TotalBytesUsed: 220, ExclusiveBytesUsed: 20}},
+			},
+		}))
+	})
+
+	Context("when there are no bundles", func() {
+		BeforeEach(func() {
+			bundleLister.ListBundlesReturns(nil, nil)
+		})
+
+		It("returns an empty list", func() {
+			bundles, err := g.List(context.Background())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(bundles).NotTo(BeNil())
+			Expect(bundles).To(BeEmpty())
+		})
+	})
+
+	Context("when listing the bundles fails", func() {
+		BeforeEach(func() {
+			bundleLister.ListBundlesReturns(nil, errors.New("failed"))
+		})
+
+		It("returns the error", func() {
+			_, err := g.List(context.Background())
+			Expect(err).To(MatchError("listing bundles: failed"))
+		})
+	})
+
+	Context("when getting the stats of a bundle fails", func() {
+		BeforeEach(func() {
+			driver.StatsStub = nil
+			driver.StatsReturns(groot.VolumeStats{}, errors.New("failed"))
+		})
+
+		It("returns the error", func() {
+			_, err := g.List(context.Background())
+			Expect(err).To(MatchError("getting stats of bundle `handle-1`: failed"))
+		})
+	})
+
+	Context("when the context is cancelled", func() {
+		It("returns the error without getting stats", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			_, err := g.List(ctx)
+			Expect(err).To(MatchError(context.Canceled))
+			Expect(driver.StatsCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when the driver cannot list bundles", func() {
+		BeforeEach(func() {
+			g.Driver = driver
+		})
+
+		It("returns an error", func() {
+			_, err := g.List(context.Background())
+			Expect(err).To(MatchError("driver does not support listing bundles"))
+		})
+	})
+})
+
+type listingDriver struct {
+	*grootfakes.FakeDriver
+	*grootfakes.FakeBundleLister
+}
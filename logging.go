@@ -0,0 +1,245 @@
+package groot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"regexp"
+	"sync"
+
+	"code.cloudfoundry.org/lager/v3"
+	"github.com/pkg/errors"
+)
+
+// Log formats. Every format writes one JSON object per line. The pretty
+// format is lager's, with RFC3339 timestamps and level names; the json format
+// is the one of log/slog, with the data of the log at the top level; the lager
+// format is lager's default, with Unix timestamps and numeric levels.
+const (
+	logFormatPretty = "pretty"
+	logFormatJSON   = "json"
+	logFormatLager  = "lager"
+)
+
+// logFileConfig sends the logs to a file, which is appended to, instead of
+// stderr. The file is rotated before it grows larger than MaxSizeBytes, unless
+// that is not set, keeping MaxBackups rotated files, or one when it is not
+// set. The most recent rotated file is named after the file with .1 appended.
+type logFileConfig struct {
+	Path         string `yaml:"path"`
+	MaxSizeBytes int64  `yaml:"max_size_bytes"`
+	MaxBackups   int    `yaml:"max_backups"`
+}
+
+// credentialKeys matches the keys of log data whose values are credentials
+var credentialKeys = regexp.MustCompile(`(?i)passw|pwd|secret|token|credential|authorization`)
+
+// urlUserinfo matches the userinfo of URLs, such as user:password@, in any
+// string
+var urlUserinfo = regexp.MustCompile(`([a-zA-Z][a-zA-Z0-9+.-]*://)[^/?#\s@]+@`)
+
+const redacted = "*REDACTED*"
+
+// newLogger returns the logger of groot and the log file it writes to, if any,
+// which should be closed once groot is done logging
+func newLogger(conf config) (lager.Logger, io.Closer, error) {
+	logLevels := map[string]lager.LogLevel{
+		"debug": lager.DEBUG,
+		"info":  lager.INFO,
+		"error": lager.ERROR,
+		"fatal": lager.FATAL,
+	}
+
+	logLevel, ok := logLevels[conf.LogLevel]
+	if !ok {
+		return nil, nil, invalidArgument(fmt.Errorf("invalid log level: %s", conf.LogLevel))
+	}
+
+	var (
+		writer  io.Writer = os.Stderr
+		logFile io.Closer
+	)
+	if conf.LogFile.Path != "" {
+		file, err := openRotatingFile(conf.LogFile.Path, conf.LogFile.MaxSizeBytes, conf.LogFile.MaxBackups)
+		if err != nil {
+			return nil, nil, err
+		}
+		writer, logFile = file, file
+	}
+
+	var sink lager.Sink
+	switch conf.LogFormat {
+	case logFormatPretty:
+		sink = lager.NewPrettySink(writer, lager.DEBUG)
+	case logFormatJSON:
+		sink = lager.NewSlogSink(slog.New(slog.NewJSONHandler(writer, &slog.HandlerOptions{
+			Level:       slog.LevelDebug,
+			ReplaceAttr: utcTimestamps,
+		})))
+	case logFormatLager:
+		sink = lager.NewWriterSink(writer, lager.DEBUG)
+	default:
+		if logFile != nil {
+			_ = logFile.Close()
+		}
+		return nil, nil, invalidArgument(fmt.Errorf("invalid log format: %s", conf.LogFormat))
+	}
+
+	logger := lager.NewLogger("groot")
+	logger.RegisterSink(lager.NewReconfigurableSink(redactingSink{sink: sink}, logLevel))
+
+	return logger, logFile, nil
+}
+
+func utcTimestamps(groups []string, attr slog.Attr) slog.Attr {
+	if attr.Key == slog.TimeKey && len(groups) == 0 {
+		attr.Value = slog.TimeValue(attr.Value.Time().UTC())
+	}
+	return attr
+}
+
+// redactingSink removes credentials from the data of logs before they are
+// written. The values of keys that name credentials, such as password or
+// registry_token, are replaced, and so is the userinfo of URLs in any value.
+type redactingSink struct {
+	sink lager.Sink
+}
+
+func (s redactingSink) Log(log lager.LogFormat) {
+	log.Data = redactData(log.Data)
+	s.sink.Log(log)
+}
+
+// redactData converts the data to JSON values, so that credentials nested in
+// structs are found too
+func redactData(data lager.Data) lager.Data {
+	if len(data) == 0 {
+		return data
+	}
+
+	urlsAsStrings := lager.Data{}
+	for key, value := range data {
+		switch u := value.(type) {
+		case *url.URL:
+			value = u.String()
+		case url.URL:
+			value = u.String()
+		}
+		urlsAsStrings[key] = value
+	}
+
+	contents, err := json.Marshal(urlsAsStrings)
+	if err != nil {
+		return lager.Data{"redaction-error": err.Error()}
+	}
+
+	var redactedData map[string]interface{}
+	if err := json.Unmarshal(contents, &redactedData); err != nil {
+		return lager.Data{"redaction-error": err.Error()}
+	}
+	return lager.Data(redactValue(redactedData).(map[string]interface{}))
+}
+
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, nested := range v {
+			if credentialKeys.MatchString(key) {
+				v[key] = redacted
+			} else {
+				v[key] = redactValue(nested)
+			}
+		}
+	case []interface{}:
+		for i, nested := range v {
+			v[i] = redactValue(nested)
+		}
+	case string:
+		return urlUserinfo.ReplaceAllString(v, "${1}"+redacted+"@")
+	}
+	return value
+}
+
+// rotatingFile appends to a log file, rotating it before it grows larger than
+// maxSize. Several groot processes may log to the same file at once, so the
+// size of the file is checked before every write, and the file is reopened
+// instead of rotated again when another process has already rotated it.
+type rotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	mutex sync.Mutex
+	file  *os.File
+}
+
+func openRotatingFile(path string, maxSize int64, maxBackups int) (*rotatingFile, error) {
+	if maxBackups < 1 {
+		maxBackups = 1
+	}
+
+	f := &rotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return errors.Wrap(err, "opening log file")
+	}
+	f.file = file
+	return nil
+}
+
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.maxSize > 0 {
+		info, err := f.file.Stat()
+		if err == nil && info.Size() > 0 && info.Size()+int64(len(p)) > f.maxSize {
+			if err := f.rotate(info); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	return f.file.Write(p)
+}
+
+// rotate renames the file to the first backup, shifting the other backups
+// along and dropping the oldest one. Failing to rename the file is not an
+// error, as the logs can still be appended to it.
+func (f *rotatingFile) rotate(info os.FileInfo) error {
+	current, err := os.Stat(f.path)
+	rotatedElsewhere := err != nil || !os.SameFile(info, current)
+
+	// Open files cannot be renamed on Windows
+	_ = f.file.Close()
+
+	if !rotatedElsewhere {
+		for i := f.maxBackups; i > 1; i-- {
+			_ = os.Rename(f.backupPath(i-1), f.backupPath(i))
+		}
+		_ = os.Rename(f.path, f.backupPath(1))
+	}
+
+	return f.open()
+}
+
+func (f *rotatingFile) backupPath(i int) string {
+	return fmt.Sprintf("%s.%d", f.path, i)
+}
+
+func (f *rotatingFile) Close() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.file.Close()
+}
@@ -0,0 +1,25 @@
+package groot
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+func (g *Groot) Metadata(ctx context.Context, handle string) (ImageMetadata, error) {
+	g.Logger = g.Logger.Session("metadata")
+	g.Logger.Debug("starting")
+	defer g.Logger.Debug("ending")
+
+	if err := ctx.Err(); err != nil {
+		return ImageMetadata{}, err
+	}
+
+	reader, ok := g.Driver.(MetadataReader)
+	if !ok {
+		return ImageMetadata{}, driverFailure(errors.New("driver does not support reading metadata"))
+	}
+
+	metadata, err := reader.ReadMetadata(g.Logger, handle)
+	return metadata, driverFailure(err)
+}
@@ -0,0 +1,90 @@
+package groot_test
+
+import (
+	"context"
+
+	"code.cloudfoundry.org/groot"
+	"code.cloudfoundry.org/groot/grootfakes"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	errors "github.com/pkg/errors"
+)
+
+var _ = Describe("Metadata", func() {
+	var (
+		driver         *grootfakes.FakeDriver
+		metadataReader *grootfakes.FakeMetadataReader
+
+		logger *lagertest.TestLogger
+		g      *groot.Groot
+
+		expectedMetadata groot.ImageMetadata
+	)
+
+	BeforeEach(func() {
+		driver = new(grootfakes.FakeDriver)
+		metadataReader = new(grootfakes.FakeMetadataReader)
+
+		expectedMetadata = groot.ImageMetadata{
+			Size:           1000,
+			ImageURL:       "docker:///some/image:latest",
+			ManifestDigest: "sha256:manifest",
+		}
+		metadataReader.ReadMetadataReturns(expectedMetadata, nil)
+
+		logger = lagertest.NewTestLogger("groot")
+		g = &groot.Groot{
+			Driver: &metadataReadingDriver{FakeDriver: driver, FakeMetadataReader: metadataReader},
+			Logger: logger,
+		}
+	})
+
+	It("returns the metadata from the driver", func() {
+		metadata, err := g.Metadata(context.Background(), "some-handle")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(metadata).To(Equal(expectedMetadata))
+
+		Expect(metadataReader.ReadMetadataCallCount()).To(Equal(1))
+		_, handle := metadataReader.ReadMetadataArgsForCall(0)
+		Expect(handle).To(Equal("some-handle"))
+	})
+
+	Context("when the driver fails to read the metadata", func() {
+		BeforeEach(func() {
+			metadataReader.ReadMetadataReturns(groot.ImageMetadata{}, errors.New("failed"))
+		})
+
+		It("returns the error", func() {
+			_, err := g.Metadata(context.Background(), "some-handle")
+			Expect(err).To(MatchError("failed"))
+		})
+	})
+
+	Context("when the context is cancelled", func() {
+		It("returns the error without calling the driver", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			_, err := g.Metadata(ctx, "some-handle")
+			Expect(err).To(MatchError(context.Canceled))
+			Expect(metadataReader.ReadMetadataCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when the driver cannot read metadata", func() {
+		BeforeEach(func() {
+			g.Driver = driver
+		})
+
+		It("returns an error", func() {
+			_, err := g.Metadata(context.Background(), "some-handle")
+			Expect(err).To(MatchError("driver does not support reading metadata"))
+		})
+	})
+})
+
+type metadataReadingDriver struct {
+	*grootfakes.FakeDriver
+	*grootfakes.FakeMetadataReader
+}
@@ -0,0 +1,366 @@
+package groot
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/groot/imagepuller"
+	"code.cloudfoundry.org/lager/v3"
+	"github.com/containers/storage/pkg/lockfile"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"google.golang.org/protobuf/proto"
+)
+
+// metricsConfig enables the Prometheus metrics of pulls. Every create and
+// pull adds its metrics to a single file in TextfileDir, which is meant to be
+// read by the textfile collector of the node exporter. The metrics of
+// registries that have not been pulled from for MaxSeriesAge are removed from
+// the file, which defaults to defaultMetricsMaxSeriesAge.
+type metricsConfig struct {
+	TextfileDir  string        `yaml:"textfile_dir"`
+	MaxSeriesAge time.Duration `yaml:"max_series_age"`
+}
+
+const defaultMetricsMaxSeriesAge = 7 * 24 * time.Hour
+
+// metricsFileName is the file in the textfile directory that the metrics of
+// all pulls are aggregated in. It is guarded by a lock file of the same name
+// with .lock appended, which the textfile collector ignores.
+const metricsFileName = "groot_pulls.prom"
+
+// layerDurationBuckets range from 50ms to about 100s
+var layerDurationBuckets = prometheus.ExponentialBuckets(0.05, 2, 12)
+
+// pullMetrics aggregates the progress events of a single pull. All metrics
+// are labelled with the registry only, so that the number of series is bound
+// by the number of registries rather than images. When they are written,
+// counters and histograms are added to those of earlier pulls from the same
+// registry, and gauges replace them, so that they describe the last pull.
+type pullMetrics struct {
+	registry *prometheus.Registry
+
+	manifestFetchDuration prometheus.Gauge
+	layerDownloadDuration prometheus.Histogram
+	layerUnpackDuration   prometheus.Histogram
+	downloadedBytes       prometheus.Counter
+	cachedBytes           prometheus.Counter
+	skippedLayers         prometheus.Counter
+	retries               prometheus.Counter
+	quotaRejections       prometheus.Counter
+	pullDuration          prometheus.Gauge
+	pullSuccess           prometheus.Gauge
+	pullTimestamp         prometheus.Gauge
+}
+
+func newPullMetrics(imageURL string) *pullMetrics {
+	labels := prometheus.Labels{
+		"registry": metricsRegistry(imageURL),
+	}
+
+	m := &pullMetrics{
+		registry: prometheus.NewRegistry(),
+
+		manifestFetchDuration: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name:        "groot_manifest_fetch_duration_seconds",
+			Help:        "How long fetching the manifest and config of the image took in the last pull.",
+			ConstLabels: labels,
+		}),
+		layerDownloadDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:        "groot_layer_download_duration_seconds",
+			Help:        "How long downloading and verifying the blobs of layers took.",
+			ConstLabels: labels,
+			Buckets:     layerDurationBuckets,
+		}),
+		layerUnpackDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:        "groot_layer_unpack_duration_seconds",
+			Help:        "How long the driver took to unpack layers.",
+			ConstLabels: labels,
+			Buckets:     layerDurationBuckets,
+		}),
+		downloadedBytes: prometheus.NewCounter(prometheus.CounterOpts{
+			Name:        "groot_downloaded_bytes_total",
+			Help:        "Blob bytes downloaded from registries or OCI layouts.",
+			ConstLabels: labels,
+		}),
+		cachedBytes: prometheus.NewCounter(prometheus.CounterOpts{
+			Name:        "groot_cached_bytes_total",
+			Help:        "Blob bytes read from the blob cache.",
+			ConstLabels: labels,
+		}),
+		skippedLayers: prometheus.NewCounter(prometheus.CounterOpts{
+			Name:        "groot_skipped_layers_total",
+			Help:        "Layers that were not fetched because the driver already had them.",
+			ConstLabels: labels,
+		}),
+		retries: prometheus.NewCounter(prometheus.CounterOpts{
+			Name:        "groot_registry_retries_total",
+			Help:        "Registry requests that were retried.",
+			ConstLabels: labels,
+		}),
+		quotaRejections: prometheus.NewCounter(prometheus.CounterOpts{
+			Name:        "groot_quota_rejections_total",
+			Help:        "Pulls that failed because the image does not fit in the disk limit.",
+			ConstLabels: labels,
+		}),
+		pullDuration: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name:        "groot_pull_duration_seconds",
+			Help:        "How long the last pull took, whether it succeeded or not.",
+			ConstLabels: labels,
+		}),
+		pullSuccess: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name:        "groot_pull_success",
+			Help:        "Whether the last pull succeeded.",
+			ConstLabels: labels,
+		}),
+		pullTimestamp: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name:        "groot_pull_timestamp_seconds",
+			Help:        "When the last pull finished, in seconds since the epoch.",
+			ConstLabels: labels,
+		}),
+	}
+
+	m.registry.MustRegister(
+		m.manifestFetchDuration,
+		m.layerDownloadDuration,
+		m.layerUnpackDuration,
+		m.downloadedBytes,
+		m.cachedBytes,
+		m.skippedLayers,
+		m.retries,
+		m.quotaRejections,
+		m.pullDuration,
+		m.pullSuccess,
+		m.pullTimestamp,
+	)
+	return m
+}
+
+func (m *pullMetrics) Report(event imagepuller.ProgressEvent) {
+	switch event.Event {
+	case imagepuller.ProgressManifestFetched:
+		m.manifestFetchDuration.Set(event.Duration)
+	case imagepuller.ProgressRetry:
+		m.retries.Inc()
+	case imagepuller.ProgressLayerSkipped:
+		m.skippedLayers.Inc()
+	case imagepuller.ProgressLayerVerified:
+		if event.Cached {
+			m.cachedBytes.Add(float64(event.Bytes))
+		} else {
+			m.downloadedBytes.Add(float64(event.Bytes))
+			m.layerDownloadDuration.Observe(event.Duration)
+		}
+	case imagepuller.ProgressUnpackFinished:
+		m.layerUnpackDuration.Observe(event.Duration)
+	}
+}
+
+// write adds the metrics of the pull to the metrics file, which is replaced
+// atomically. Nothing is written when no textfile directory is configured.
+func (m *pullMetrics) write(conf metricsConfig, duration time.Duration, pullErr error) error {
+	if conf.TextfileDir == "" {
+		return nil
+	}
+
+	now := time.Now()
+	m.pullDuration.Set(duration.Seconds())
+	m.pullTimestamp.Set(float64(now.Unix()))
+	if pullErr == nil {
+		m.pullSuccess.Set(1)
+	}
+	if errors.Is(pullErr, ErrQuotaExceeded) {
+		m.quotaRejections.Inc()
+	}
+
+	families, err := m.registry.Gather()
+	if err != nil {
+		return errors.Wrap(err, "gathering metrics")
+	}
+
+	path := filepath.Join(conf.TextfileDir, metricsFileName)
+	lock, err := lockfile.GetLockFile(path + ".lock")
+	if err != nil {
+		return errors.Wrap(err, "locking metrics")
+	}
+	lock.Lock()
+	defer lock.Unlock()
+
+	maxSeriesAge := conf.MaxSeriesAge
+	if maxSeriesAge <= 0 {
+		maxSeriesAge = defaultMetricsMaxSeriesAge
+	}
+	existing := removeStaleSeries(readMetricsFile(path), now.Add(-maxSeriesAge))
+	merged := mergeMetricFamilies(existing, families)
+	gatherer := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		return merged, nil
+	})
+	return errors.Wrap(prometheus.WriteToTextfile(path, gatherer), "writing metrics")
+}
+
+// writeOrLog writes the metrics, logging rather than returning failures, as
+// they should not fail the pull
+func (m *pullMetrics) writeOrLog(logger lager.Logger, conf metricsConfig, duration time.Duration, pullErr error) {
+	if err := m.write(conf, duration, pullErr); err != nil {
+		logger.Error("writing-metrics-failed", err)
+	}
+}
+
+// readMetricsFile returns the metrics written by earlier pulls. A file that
+// does not exist or cannot be parsed is treated as empty, so that it is
+// replaced.
+func readMetricsFile(path string) map[string]*dto.MetricFamily {
+	file, err := os.Open(path)
+	if err != nil {
+		return map[string]*dto.MetricFamily{}
+	}
+	defer file.Close()
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(file)
+	if err != nil {
+		return map[string]*dto.MetricFamily{}
+	}
+	return families
+}
+
+// removeStaleSeries removes the metrics of every label set whose last pull
+// finished before cutoff
+func removeStaleSeries(families map[string]*dto.MetricFamily, cutoff time.Time) map[string]*dto.MetricFamily {
+	timestamps, ok := families["groot_pull_timestamp_seconds"]
+	if !ok {
+		return families
+	}
+
+	stale := map[string]bool{}
+	for _, metric := range timestamps.Metric {
+		if metric.GetGauge().GetValue() < float64(cutoff.Unix()) {
+			stale[labelsKey(metric)] = true
+		}
+	}
+	if len(stale) == 0 {
+		return families
+	}
+
+	for name, family := range families {
+		metrics := []*dto.Metric{}
+		for _, metric := range family.Metric {
+			if !stale[labelsKey(metric)] {
+				metrics = append(metrics, metric)
+			}
+		}
+		if len(metrics) == 0 {
+			delete(families, name)
+			continue
+		}
+		family.Metric = metrics
+	}
+	return families
+}
+
+// mergeMetricFamilies adds the metrics of a pull to the existing ones, and
+// returns the result sorted by name
+func mergeMetricFamilies(existing map[string]*dto.MetricFamily, families []*dto.MetricFamily) []*dto.MetricFamily {
+	for _, family := range families {
+		existingFamily, ok := existing[family.GetName()]
+		if !ok || existingFamily.GetType() != family.GetType() {
+			existing[family.GetName()] = family
+			continue
+		}
+
+		for _, metric := range family.Metric {
+			existingFamily.Metric = mergeMetric(existingFamily.Metric, metric, family.GetType())
+		}
+	}
+
+	merged := []*dto.MetricFamily{}
+	for _, family := range existing {
+		merged = append(merged, family)
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		return merged[i].GetName() < merged[j].GetName()
+	})
+	return merged
+}
+
+func mergeMetric(metrics []*dto.Metric, metric *dto.Metric, metricType dto.MetricType) []*dto.Metric {
+	for i, existing := range metrics {
+		if labelsKey(existing) != labelsKey(metric) {
+			continue
+		}
+
+		switch metricType {
+		case dto.MetricType_COUNTER:
+			metric.Counter.Value = proto.Float64(existing.GetCounter().GetValue() + metric.GetCounter().GetValue())
+		case dto.MetricType_HISTOGRAM:
+			mergeHistogram(existing.GetHistogram(), metric.GetHistogram())
+		}
+		metrics[i] = metric
+		return metrics
+	}
+
+	return append(metrics, metric)
+}
+
+// mergeHistogram adds the observations of existing to histogram. Both have
+// the same buckets, except for the +Inf bucket that parsed histograms have,
+// which is implied by the sample count.
+func mergeHistogram(existing, histogram *dto.Histogram) {
+	histogram.SampleCount = proto.Uint64(existing.GetSampleCount() + histogram.GetSampleCount())
+	histogram.SampleSum = proto.Float64(existing.GetSampleSum() + histogram.GetSampleSum())
+
+	counts := map[float64]uint64{}
+	for _, bucket := range existing.Bucket {
+		counts[bucket.GetUpperBound()] = bucket.GetCumulativeCount()
+	}
+	for _, bucket := range histogram.Bucket {
+		bucket.CumulativeCount = proto.Uint64(bucket.GetCumulativeCount() + counts[bucket.GetUpperBound()])
+	}
+}
+
+func labelsKey(metric *dto.Metric) string {
+	pairs := []string{}
+	for _, label := range metric.Label {
+		pairs = append(pairs, label.GetName()+"="+label.GetValue())
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// metricsRegistry returns the registry of registry images, and the transport
+// of other images
+func metricsRegistry(imageURL string) string {
+	parsedURL, err := url.Parse(imageURL)
+	if err != nil {
+		return "unknown"
+	}
+
+	switch parsedURL.Scheme {
+	case "docker":
+		if parsedURL.Host == "" {
+			return dockerHubRegistry
+		}
+		return parsedURL.Host
+	case "oci":
+		return "oci"
+	}
+	return "local"
+}
+
+// redactURL removes credentials from image URLs, which are put in spans and
+// the image metadata
+func redactURL(imageURL string) string {
+	parsedURL, err := url.Parse(imageURL)
+	if err != nil || parsedURL.User == nil {
+		return imageURL
+	}
+
+	parsedURL.User = nil
+	return parsedURL.String()
+}
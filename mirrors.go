@@ -0,0 +1,66 @@
+package groot
+
+import (
+	"context"
+	"net/url"
+	"path"
+	"strings"
+
+	"code.cloudfoundry.org/groot/fetcher/layerfetcher/source"
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/types"
+	"github.com/pkg/errors"
+)
+
+// registryMirrors returns the mirrors configured for the registry of
+// imageURL, pointing at the same repository and tag or digest. Mirrors are
+// configured as a host, optionally followed by a path that the repositories
+// are nested under, as with the proxy cache projects of Harbor.
+func registryMirrors(ctx context.Context, systemContext types.SystemContext, imageURL *url.URL, conf config, dockerConfig DockerConfig) ([]source.Mirror, error) {
+	registry := imageURL.Host
+	if registry == "" {
+		registry = dockerHubRegistry
+	}
+
+	locations := conf.Mirrors[registry]
+	if len(locations) == 0 {
+		return nil, nil
+	}
+
+	ref, err := reference.ParseNormalizedNamed(registry + imageURL.Path)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing image reference")
+	}
+
+	suffix := ""
+	if tagged, ok := ref.(reference.Tagged); ok {
+		suffix += ":" + tagged.Tag()
+	}
+	if digested, ok := ref.(reference.Digested); ok {
+		suffix += "@" + digested.Digest().String()
+	}
+
+	// The credentials given on the command line are meant for the registry
+	// of the image, so they are not sent to its mirrors
+	mirrorDockerConfig := DockerConfig{InsecureRegistries: dockerConfig.InsecureRegistries}
+
+	mirrors := []source.Mirror{}
+	for _, location := range locations {
+		host, prefix, _ := strings.Cut(strings.Trim(location, "/"), "/")
+		mirrorURL := &url.URL{
+			Scheme: "docker",
+			Host:   host,
+			Path:   "/" + path.Join(prefix, reference.Path(ref)) + suffix,
+		}
+
+		mirrorSystemContext, err := registrySystemContext(ctx, systemContext, mirrorURL, conf, mirrorDockerConfig)
+		if err != nil {
+			removeCertDirs(certDirs(types.SystemContext{}, mirrors))
+			return nil, errors.Wrapf(err, "configuring mirror `%s`", location)
+		}
+
+		mirrors = append(mirrors, source.Mirror{URL: mirrorURL, SystemContext: mirrorSystemContext})
+	}
+
+	return mirrors, nil
+}
@@ -1,15 +1,21 @@
 package groot
 
 import (
+	"context"
+
 	"code.cloudfoundry.org/groot/imagepuller"
+	"code.cloudfoundry.org/groot/tracing"
 	"github.com/pkg/errors"
 )
 
-func (g *Groot) Pull() error {
+func (g *Groot) Pull(ctx context.Context) (err error) {
 	g.Logger = g.Logger.Session("pull")
 	g.Logger.Debug("starting")
 	defer g.Logger.Debug("ending")
 
-	_, err := g.ImagePuller.Pull(g.Logger, imagepuller.ImageSpec{})
+	ctx, span := tracing.Start(ctx, "groot.Pull")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = g.ImagePuller.Pull(ctx, g.Logger, imagepuller.ImageSpec{})
 	return errors.Wrap(err, "pulling image")
 }
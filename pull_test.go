@@ -2,6 +2,7 @@ package groot_test
 
 import (
 	"bytes"
+	"context"
 	"io"
 
 	"code.cloudfoundry.org/groot"
@@ -12,6 +13,12 @@ import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	errors "github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 var _ = Describe("Pull", func() {
@@ -55,12 +62,12 @@ var _ = Describe("Pull", func() {
 		})
 
 		JustBeforeEach(func() {
-			Expect(g.Pull()).To(Succeed())
+			Expect(g.Pull(context.Background())).To(Succeed())
 		})
 
 		It("calls the image puller with the expected args", func() {
 			Expect(imagePuller.PullCallCount()).To(Equal(1))
-			_, spec := imagePuller.PullArgsForCall(0)
+			_, _, spec := imagePuller.PullArgsForCall(0)
 			Expect(spec).To(Equal(imagepuller.ImageSpec{}))
 		})
 	})
@@ -71,7 +78,7 @@ var _ = Describe("Pull", func() {
 		)
 
 		JustBeforeEach(func() {
-			pullErr = g.Pull()
+			pullErr = g.Pull(context.Background())
 		})
 
 		Context("when image puller returns an error", func() {
@@ -84,4 +91,30 @@ var _ = Describe("Pull", func() {
 			})
 		})
 	})
+
+	Describe("tracing", func() {
+		var exporter *tracetest.InMemoryExporter
+
+		BeforeEach(func() {
+			exporter = tracetest.NewInMemoryExporter()
+			otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+			imagePuller.PullReturns(imagepuller.Image{}, errors.New("pull-failed"))
+		})
+
+		AfterEach(func() {
+			otel.SetTracerProvider(noop.NewTracerProvider())
+		})
+
+		It("pulls the image within a pull span that records the failure", func() {
+			Expect(g.Pull(context.Background())).NotTo(Succeed())
+
+			spans := exporter.GetSpans()
+			Expect(spans).To(HaveLen(1))
+			Expect(spans[0].Name).To(Equal("groot.Pull"))
+			Expect(spans[0].Status.Code).To(Equal(codes.Error))
+
+			ctx, _, _ := imagePuller.PullArgsForCall(0)
+			Expect(trace.SpanContextFromContext(ctx).SpanID()).To(Equal(spans[0].SpanContext.SpanID()))
+		})
+	})
 })
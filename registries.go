@@ -0,0 +1,213 @@
+package groot
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/groot/fetcher/layerfetcher"
+	"code.cloudfoundry.org/groot/fetcher/layerfetcher/source"
+	"github.com/containers/image/v5/types"
+	"github.com/pkg/errors"
+)
+
+const tlsCheckTimeout = 30 * time.Second
+
+// dockerHubEndpoint is where the registry of Docker Hub images is served
+const dockerHubEndpoint = "registry-1.docker.io"
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// registryCertDir lays the CA bundle and client certificate of a registry out
+// the way containers/image expects to find them in DockerCertPath: CAs in
+// *.crt files, and client certificates in *.cert files next to a *.key file
+// of the same name. The caller is responsible for removing the directory.
+func registryCertDir(registry string, conf registryConfig) (string, error) {
+	if (conf.CertFile == "") != (conf.KeyFile == "") {
+		return "", errors.Errorf("registry `%s` needs both a cert_file and a key_file", registry)
+	}
+
+	certs := map[string]string{
+		"ca.crt":      conf.CAFile,
+		"client.cert": conf.CertFile,
+		"client.key":  conf.KeyFile,
+	}
+
+	certDir, err := os.MkdirTemp("", "groot-certs-")
+	if err != nil {
+		return "", errors.Wrap(err, "creating certificate directory")
+	}
+
+	for name, path := range certs {
+		if path == "" {
+			continue
+		}
+
+		if err := linkCert(path, filepath.Join(certDir, name)); err != nil {
+			_ = os.RemoveAll(certDir)
+			return "", errors.Wrapf(err, "configuring TLS for registry `%s`", registry)
+		}
+	}
+
+	return certDir, nil
+}
+
+func linkCert(path, link string) error {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(absPath); err != nil {
+		return err
+	}
+
+	return os.Symlink(absPath, link)
+}
+
+// checkMinTLSVersion makes sure the registry can be reached with at least the
+// configured TLS version, by opening a connection of its own before the pull.
+// containers/image does not let us set the minimum version of its own
+// connections, but Go clients always negotiate the highest version both ends
+// support, so a registry that passes this check will not be talked to with an
+// older one. The check gives up when ctx is done.
+func checkMinTLSVersion(ctx context.Context, registry string, conf registryConfig, insecure bool) error {
+	if conf.MinTLSVersion == "" {
+		return nil
+	}
+
+	minVersion, ok := tlsVersions[conf.MinTLSVersion]
+	if !ok {
+		return errors.Errorf("invalid min_tls_version %q for registry `%s`, expected one of 1.0, 1.1, 1.2 or 1.3", conf.MinTLSVersion, registry)
+	}
+
+	tlsConfig, err := registryTLSConfig(conf)
+	if err != nil {
+		return errors.Wrapf(err, "configuring TLS for registry `%s`", registry)
+	}
+	tlsConfig.MinVersion = minVersion
+	// #nosec - G402 - only when the registry is listed in insecure_registries
+	tlsConfig.InsecureSkipVerify = insecure
+
+	address := registry
+	if registry == dockerHubRegistry {
+		address = dockerHubEndpoint
+	}
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, "443")
+	}
+
+	dialer := &tls.Dialer{NetDialer: &net.Dialer{Timeout: tlsCheckTimeout}, Config: tlsConfig}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return errors.Wrapf(err, "connecting to registry `%s` with TLS %s or later", registry, conf.MinTLSVersion)
+	}
+
+	return conn.Close()
+}
+
+func registryTLSConfig(conf registryConfig) (*tls.Config, error) {
+	// #nosec - G402 - MinVersion is set by the caller
+	tlsConfig := &tls.Config{}
+
+	if conf.CAFile != "" {
+		rootCAs, err := x509.SystemCertPool()
+		if err != nil {
+			rootCAs = x509.NewCertPool()
+		}
+
+		caBundle, err := os.ReadFile(conf.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		if !rootCAs.AppendCertsFromPEM(caBundle) {
+			return nil, errors.Errorf("no certificates found in `%s`", conf.CAFile)
+		}
+		tlsConfig.RootCAs = rootCAs
+	}
+
+	if conf.CertFile != "" && conf.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(conf.CertFile, conf.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// registrySystemContext configures how to connect to the registry of
+// imageURL, starting from the platform choice in systemContext
+func registrySystemContext(ctx context.Context, systemContext types.SystemContext, imageURL *url.URL, conf config, dockerConfig DockerConfig) (types.SystemContext, error) {
+	var err error
+	systemContext.DockerInsecureSkipTLSVerify = types.NewOptionalBool(skipTLSValidation(imageURL, dockerConfig.InsecureRegistries))
+	systemContext.DockerAuthConfig, err = registryCredentials(imageURL, conf, dockerConfig)
+	if err != nil {
+		return types.SystemContext{}, err
+	}
+	systemContext.DockerBearerRegistryToken = dockerConfig.RegistryToken
+
+	registry := imageURL.Host
+	if registry == "" {
+		registry = dockerHubRegistry
+	}
+
+	registryConf, ok := conf.Registries[registry]
+	if !ok {
+		return systemContext, nil
+	}
+
+	if err := checkMinTLSVersion(ctx, registry, registryConf, systemContext.DockerInsecureSkipTLSVerify == types.OptionalBoolTrue); err != nil {
+		return types.SystemContext{}, err
+	}
+
+	if registryConf.CAFile != "" || registryConf.CertFile != "" || registryConf.KeyFile != "" {
+		if systemContext.DockerCertPath, err = registryCertDir(registry, registryConf); err != nil {
+			return types.SystemContext{}, err
+		}
+	}
+
+	return systemContext, nil
+}
+
+func certDirs(systemContext types.SystemContext, mirrors []source.Mirror) []string {
+	dirs := []string{}
+	if systemContext.DockerCertPath != "" {
+		dirs = append(dirs, systemContext.DockerCertPath)
+	}
+	for _, mirror := range mirrors {
+		if mirror.SystemContext.DockerCertPath != "" {
+			dirs = append(dirs, mirror.SystemContext.DockerCertPath)
+		}
+	}
+	return dirs
+}
+
+func removeCertDirs(dirs []string) {
+	for _, dir := range dirs {
+		_ = os.RemoveAll(dir)
+	}
+}
+
+// certDirFetcher removes the certificate directories created for a pull once
+// the fetcher is closed
+type certDirFetcher struct {
+	*layerfetcher.LayerFetcher
+	certDirs []string
+}
+
+func (f certDirFetcher) Close() error {
+	defer removeCertDirs(f.certDirs)
+	return f.LayerFetcher.Close()
+}
@@ -12,7 +12,7 @@ function test() {
   export DIR=${package}
   . <(/ci/shared/helpers/extract-default-params-for-task.bash /ci/shared/tasks/run-bin-test/linux.yml)
 
-  export GOFLAGS="-buildvcs=false"
+  export GOFLAGS="-buildvcs=false ${EXTRA_GOFLAGS:-}"
   /ci/shared/tasks/run-bin-test/task.bash "${sub_package}"
 }
 
@@ -21,5 +21,8 @@ if [[ -n "${1:-}" ]]; then
   test "${1}" "${2:-}"
 else
   test "."
+  # Signature policies are only enforced with this build tag, see
+  # fetcher/signaturepolicy
+  EXTRA_GOFLAGS="-tags=containers_image_openpgp" test "."
 fi
 popd > /dev/null
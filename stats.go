@@ -1,9 +1,16 @@
 package groot
 
-func (g *Groot) Stats(handle string) (VolumeStats, error) {
+import "context"
+
+func (g *Groot) Stats(ctx context.Context, handle string) (VolumeStats, error) {
 	g.Logger = g.Logger.Session("stats")
 	g.Logger.Debug("starting")
 	defer g.Logger.Debug("ending")
 
-	return g.Driver.Stats(g.Logger, handle)
+	if err := ctx.Err(); err != nil {
+		return VolumeStats{}, err
+	}
+
+	stats, err := g.Driver.Stats(g.Logger, handle)
+	return stats, driverFailure(err)
 }
@@ -1,6 +1,8 @@
 package groot_test
 
 import (
+	"context"
+
 	"code.cloudfoundry.org/groot"
 	"code.cloudfoundry.org/groot/grootfakes"
 	"code.cloudfoundry.org/lager/v3/lagertest"
@@ -38,7 +40,7 @@ var _ = Describe("Stats", func() {
 	})
 
 	It("calls driver.Stats() with the expected args", func() {
-		stats, err := g.Stats("image")
+		stats, err := g.Stats(context.Background(), "image")
 		Expect(err).NotTo(HaveOccurred())
 		Expect(stats).To(Equal(expectedStats))
 
@@ -53,8 +55,19 @@ var _ = Describe("Stats", func() {
 		})
 
 		It("returns the error", func() {
-			_, err := g.Stats("image")
+			_, err := g.Stats(context.Background(), "image")
 			Expect(err).To(MatchError("failed"))
 		})
 	})
+
+	Context("when the context is cancelled", func() {
+		It("returns the error without calling the driver", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			_, err := g.Stats(ctx, "image")
+			Expect(err).To(MatchError(context.Canceled))
+			Expect(driver.StatsCallCount()).To(Equal(0))
+		})
+	})
 })
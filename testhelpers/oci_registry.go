@@ -0,0 +1,104 @@
+package testhelpers
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+
+	"github.com/onsi/gomega/ghttp"
+	digestpkg "github.com/opencontainers/go-digest"
+	imgspec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// NewOCIRegistry starts a plain HTTP registry that serves the image in the
+// OCI layout at layoutPath, under any repository name. Manifests can be
+// requested by the tags in the layout index or by digest.
+func NewOCIRegistry(layoutPath string) *ghttp.Server {
+	server := ghttp.NewServer()
+
+	blobRegexp := regexp.MustCompile(`^/v2/.+/blobs/(.+)$`)
+	server.RouteToHandler("GET", blobRegexp, func(w http.ResponseWriter, req *http.Request) {
+		serveOCIBlob(w, layoutPath, digestpkg.Digest(blobRegexp.FindStringSubmatch(req.URL.Path)[1]), "application/octet-stream")
+	})
+
+	manifestRegexp := regexp.MustCompile(`^/v2/.+/manifests/(.+)$`)
+	server.RouteToHandler("GET", manifestRegexp, func(w http.ResponseWriter, req *http.Request) {
+		descriptor, ok := findOCIManifest(layoutPath, manifestRegexp.FindStringSubmatch(req.URL.Path)[1])
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		serveOCIBlob(w, layoutPath, descriptor.Digest, descriptor.MediaType)
+	})
+
+	server.RouteToHandler("GET", "/v2/", ghttp.RespondWith(http.StatusOK, "{}"))
+
+	return server
+}
+
+func findOCIManifest(layoutPath, reference string) (imgspec.Descriptor, bool) {
+	contents, err := os.ReadFile(filepath.Join(layoutPath, "index.json"))
+	if err != nil {
+		return imgspec.Descriptor{}, false
+	}
+
+	var index imgspec.Index
+	if err := json.Unmarshal(contents, &index); err != nil {
+		return imgspec.Descriptor{}, false
+	}
+
+	for _, descriptor := range index.Manifests {
+		if descriptor.Annotations[imgspec.AnnotationRefName] == reference {
+			return descriptor, true
+		}
+	}
+
+	digest := digestpkg.Digest(reference)
+	if digest.Validate() != nil {
+		return imgspec.Descriptor{}, false
+	}
+
+	contents, err = os.ReadFile(ociBlobPath(layoutPath, digest))
+	if err != nil {
+		return imgspec.Descriptor{}, false
+	}
+
+	var manifest struct {
+		MediaType string `json:"mediaType"`
+	}
+	if err := json.Unmarshal(contents, &manifest); err != nil {
+		return imgspec.Descriptor{}, false
+	}
+
+	// OCI manifests do not have to carry their media type
+	if manifest.MediaType == "" {
+		manifest.MediaType = imgspec.MediaTypeImageManifest
+	}
+
+	return imgspec.Descriptor{MediaType: manifest.MediaType, Digest: digest}, true
+}
+
+func serveOCIBlob(w http.ResponseWriter, layoutPath string, digest digestpkg.Digest, mediaType string) {
+	if digest.Validate() != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	contents, err := os.ReadFile(ociBlobPath(layoutPath, digest))
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", mediaType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(contents)))
+	w.Header().Set("Docker-Content-Digest", digest.String())
+	_, _ = w.Write(contents)
+}
+
+func ociBlobPath(layoutPath string, digest digestpkg.Digest) string {
+	return filepath.Join(layoutPath, "blobs", digest.Algorithm().String(), digest.Encoded())
+}
@@ -104,7 +104,7 @@ func (r *FakeRegistry) serveHTTP(rw http.ResponseWriter, req *http.Request) {
 func (r *FakeRegistry) serveManifest(rw http.ResponseWriter, req *http.Request) {
 	if r.failNextManifestRequests > 0 {
 		r.failNextManifestRequests--
-		rw.WriteHeader(http.StatusTeapot)
+		rw.WriteHeader(http.StatusServiceUnavailable)
 		_, _ = rw.Write([]byte("null"))
 		return
 	}
@@ -115,7 +115,7 @@ func (r *FakeRegistry) serveManifest(rw http.ResponseWriter, req *http.Request)
 func (r *FakeRegistry) serveBlob(rw http.ResponseWriter, req *http.Request) {
 	if r.failNextBlobRequests > 0 {
 		r.failNextBlobRequests--
-		rw.WriteHeader(http.StatusTeapot)
+		rw.WriteHeader(http.StatusServiceUnavailable)
 		_, _ = rw.Write([]byte("null"))
 		return
 	}
@@ -0,0 +1,74 @@
+package groot
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// tracingShutdownTimeout bounds how long groot waits for the remaining spans
+// to be exported before it exits
+const tracingShutdownTimeout = 5 * time.Second
+
+// tracingConfig enables OpenTelemetry tracing. Spans are exported over
+// OTLP/HTTP to Endpoint, such as http://localhost:4318, to which /v1/traces
+// is appended when it has no path. Headers are sent with every export, for
+// example to authenticate with the collector.
+type tracingConfig struct {
+	Endpoint string            `yaml:"endpoint"`
+	Headers  map[string]string `yaml:"headers"`
+}
+
+// tracerProvider returns nil when tracing is disabled
+func (c tracingConfig) tracerProvider(logger lager.Logger, version string) (*sdktrace.TracerProvider, error) {
+	if c.Endpoint == "" {
+		return nil, nil
+	}
+
+	endpoint, err := url.Parse(c.Endpoint)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, invalidArgument(errors.Errorf("invalid tracing.endpoint %q, expected an http or https URL", c.Endpoint))
+	}
+	if endpoint.Path == "" || endpoint.Path == "/" {
+		endpoint.Path = "/v1/traces"
+	}
+
+	exporter, err := otlptracehttp.New(context.Background(),
+		otlptracehttp.WithEndpointURL(endpoint.String()),
+		otlptracehttp.WithHeaders(c.Headers),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating trace exporter")
+	}
+
+	// Failing to export spans should not fail groot
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		logger.Error("tracing-failed", err)
+	}))
+
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(
+			attribute.String("service.name", "groot"),
+			attribute.String("service.version", version),
+		)),
+	), nil
+}
+
+// shutdownTracing exports the remaining spans
+func shutdownTracing(logger lager.Logger, tracerProvider *sdktrace.TracerProvider) {
+	ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+	defer cancel()
+
+	if err := tracerProvider.Shutdown(ctx); err != nil {
+		logger.Error("exporting-spans-failed", err)
+	}
+}
@@ -0,0 +1,44 @@
+// Package tracing records the OpenTelemetry spans of groot. Spans are created
+// with the global TracerProvider, which records nothing until one is
+// registered with otel.SetTracerProvider, as groot does when tracing is
+// configured.
+package tracing // import "code.cloudfoundry.org/groot/tracing"
+
+import (
+	"context"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "code.cloudfoundry.org/groot"
+
+// Start starts a span that is a child of the span in ctx, if any
+func Start(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// End ends the span, marking it as failed when err is not nil
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// ContextFromEnvironment returns ctx with the W3C trace context in the
+// TRACEPARENT and TRACESTATE environment variables, which Garden sets so that
+// the spans of groot are part of its traces. ctx is returned as is when
+// TRACEPARENT is not set or is invalid.
+func ContextFromEnvironment(ctx context.Context) context.Context {
+	carrier := propagation.MapCarrier{
+		"traceparent": os.Getenv("TRACEPARENT"),
+		"tracestate":  os.Getenv("TRACESTATE"),
+	}
+	return propagation.TraceContext{}.Extract(ctx, carrier)
+}
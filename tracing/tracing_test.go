@@ -0,0 +1,94 @@
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"code.cloudfoundry.org/groot/tracing"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+var _ = Describe("Tracing", func() {
+	var exporter *tracetest.InMemoryExporter
+
+	BeforeEach(func() {
+		exporter = tracetest.NewInMemoryExporter()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	})
+
+	AfterEach(func() {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+	})
+
+	Describe("Start and End", func() {
+		It("records spans as children of the span in the context", func() {
+			ctx, parent := tracing.Start(context.Background(), "parent")
+			_, child := tracing.Start(ctx, "child", attribute.String("key", "value"))
+			tracing.End(child, nil)
+			tracing.End(parent, nil)
+
+			spans := exporter.GetSpans()
+			Expect(spans).To(HaveLen(2))
+			Expect(spans[0].Name).To(Equal("child"))
+			Expect(spans[0].Parent.SpanID()).To(Equal(spans[1].SpanContext.SpanID()))
+			Expect(spans[0].Attributes).To(ContainElement(attribute.String("key", "value")))
+			Expect(spans[0].Status.Code).To(Equal(codes.Unset))
+		})
+
+		It("marks spans that end with an error as failed", func() {
+			_, span := tracing.Start(context.Background(), "failing")
+			tracing.End(span, errors.New("failed"))
+
+			spans := exporter.GetSpans()
+			Expect(spans).To(HaveLen(1))
+			Expect(spans[0].Status.Code).To(Equal(codes.Error))
+			Expect(spans[0].Status.Description).To(Equal("failed"))
+			Expect(spans[0].Events).To(HaveLen(1))
+		})
+	})
+
+	Describe("ContextFromEnvironment", func() {
+		AfterEach(func() {
+			Expect(os.Unsetenv("TRACEPARENT")).To(Succeed())
+			Expect(os.Unsetenv("TRACESTATE")).To(Succeed())
+		})
+
+		It("continues the trace in TRACEPARENT", func() {
+			Expect(os.Setenv("TRACEPARENT", traceparent)).To(Succeed())
+			Expect(os.Setenv("TRACESTATE", "garden=1")).To(Succeed())
+
+			spanContext := trace.SpanContextFromContext(tracing.ContextFromEnvironment(context.Background()))
+			Expect(spanContext.IsRemote()).To(BeTrue())
+			Expect(spanContext.TraceID().String()).To(Equal("4bf92f3577b34da6a3ce929d0e0e4736"))
+			Expect(spanContext.SpanID().String()).To(Equal("00f067aa0ba902b7"))
+			Expect(spanContext.TraceState().Get("garden")).To(Equal("1"))
+
+			_, span := tracing.Start(tracing.ContextFromEnvironment(context.Background()), "child")
+			tracing.End(span, nil)
+			Expect(exporter.GetSpans()[0].Parent).To(Equal(spanContext))
+		})
+
+		It("returns the context as is when TRACEPARENT is not set", func() {
+			ctx := tracing.ContextFromEnvironment(context.Background())
+			Expect(trace.SpanContextFromContext(ctx).IsValid()).To(BeFalse())
+		})
+
+		It("ignores invalid trace contexts", func() {
+			Expect(os.Setenv("TRACEPARENT", "not-a-traceparent")).To(Succeed())
+
+			ctx := tracing.ContextFromEnvironment(context.Background())
+			Expect(trace.SpanContextFromContext(ctx).IsValid()).To(BeFalse())
+		})
+	})
+})